@@ -2,35 +2,155 @@ package application
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/SamMebarek/orders-api/idgen"
+	"github.com/SamMebarek/orders-api/pricing"
+	"github.com/SamMebarek/orders-api/repository/idempotency"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/SamMebarek/orders-api/webhook"
 	"github.com/redis/go-redis/v9"
 )
 
 // App représente l'application avec le routeur, le client Redis, et la configuration.
 type App struct {
-	router http.Handler  // Gestionnaire HTTP pour router les requêtes.
-	rdb    *redis.Client // Client pour interagir avec la base de données Redis.
-	config Config        // Configuration de l'application.
+	router http.Handler        // Gestionnaire HTTP pour router les requêtes.
+	rdb    *redis.Client       // Client pour interagir avec la base de données Redis, nil hors du mode Redis.
+	events *redis.Client       // Client Redis réservé aux flux d'événements, nil hors du mode Redis.
+	db     *sql.DB             // Connexion à la base de données SQL, nil hors des modes SQL.
+	repo   order.Repository    // Dépôt utilisé pour stocker les commandes.
+	idem   idempotency.Store   // Stockage des réponses aux requêtes avec Idempotency-Key.
+	ids    idgen.Generator     // Générateur des IDs de commandes.
+	hooks  *webhook.RedisStore // Abonnements aux webhooks, nil hors du mode Redis.
+	prices pricing.Rules       // Règles de prix des commandes.
+	done   chan struct{}       // Fermé à l'arrêt du serveur, pour terminer les flux d'événements.
+	config Config              // Configuration de l'application.
 }
 
 // New crée et initialise une nouvelle instance de l'application.
-func New(config Config) *App {
-	// Initialisation de l'application avec un client Redis et la configuration.
+// Aucune connexion n'est établie ici : elles sont vérifiées au démarrage par Start.
+func New(config Config) (*App, error) {
+	// Initialisation de l'application avec la configuration.
 	app := &App{
-		rdb: redis.NewClient(&redis.Options{
-			Addr: config.RedisAddress, // Adresse du serveur Redis depuis la configuration.
-		}),
 		config: config,
+		done:   make(chan struct{}),
+	}
+
+	// Chargement des règles de prix. Un fichier invalide empêche le démarrage plutôt que de facturer des montants faux.
+	prices, err := pricing.LoadRules(config.PricingFile)
+	if err != nil {
+		return nil, err
+	}
+	app.prices = prices
+
+	// Sélection du dépôt de commandes en fonction de la configuration.
+	switch config.Storage {
+	case StorageMemory:
+		app.repo = &order.MemoryRepo{}
+	case StoragePostgres:
+		db, err := sql.Open("pgx", config.PostgresURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open postgres: %w", err)
+		}
+		app.db = db
+		app.repo = &order.PostgresRepo{
+			DB: db,
+		}
+	case StorageSQLite:
+		db, err := order.OpenSQLite(config.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		app.db = db
+		app.repo = &order.SQLiteRepo{
+			DB: db,
+		}
+	case StorageRedis:
+		app.rdb = redis.NewClient(&redis.Options{
+			Addr: config.RedisAddress, // Adresse du serveur Redis depuis la configuration.
+		})
+		// Chaque flux d'événements attend les suivants en occupant une connexion : ils ont leur propre client,
+		// avec une connexion par flux permis, pour ne pas priver les autres requêtes de connexions.
+		app.events = redis.NewClient(&redis.Options{
+			Addr:     config.RedisAddress,
+			PoolSize: config.EventsMaxStreams,
+		})
+		app.repo = &order.RedisRepo{
+			Client:       app.rdb,
+			StreamMaxLen: config.EventsMaxLen, // Taille du stream des événements.
+			EventsClient: app.events,
+		}
+	default:
+		// Une valeur mal orthographiée empêche le démarrage plutôt que de stocker les commandes ailleurs.
+		return nil, fmt.Errorf("unknown storage %q", config.Storage)
+	}
+
+	// Les réponses idempotentes sont partagées via Redis lorsqu'il est utilisé,
+	// sinon elles ne sont conservées que par cette instance.
+	// Les webhooks reposent sur le stream des événements, publié uniquement par le stockage Redis.
+	if app.rdb != nil {
+		app.idem = &idempotency.RedisStore{
+			Client: app.rdb,
+		}
+		app.hooks = &webhook.RedisStore{
+			Client: app.rdb,
+		}
+	} else {
+		app.idem = &idempotency.MemoryStore{}
+	}
+
+	// Sélection du générateur d'IDs de commandes.
+	switch idGenerator(config) {
+	case IDGeneratorRedis:
+		if app.rdb == nil {
+			return nil, fmt.Errorf("id generator %q requires redis storage", config.IDGenerator)
+		}
+		app.ids = &idgen.RedisCounter{
+			Client: app.rdb,
+			Key:    "orders:next_id",
+		}
+	case IDGeneratorSnowflake:
+		// Choisi explicitement, Snowflake exige un numéro de nœud : sans lui, deux instances pourraient générer
+		// les mêmes IDs dans la même milliseconde.
+		if config.IDGenerator == IDGeneratorSnowflake && config.NodeID == nil {
+			return nil, fmt.Errorf("id generator %q requires NODE_ID", config.IDGenerator)
+		}
+		node := uint16(0)
+		if config.NodeID != nil {
+			node = *config.NodeID
+		}
+		ids, err := idgen.NewSnowflake(node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create id generator: %w", err)
+		}
+		app.ids = ids
+	default:
+		return nil, fmt.Errorf("unknown id generator %q", config.IDGenerator)
 	}
 
 	// Chargement des routes pour le serveur HTTP.
 	app.loadRoutes()
 
 	// Retourne l'instance de l'application initialisée.
-	return app
+	return app, nil
+}
+
+// idGenerator retourne le générateur d'IDs à utiliser. Sans choix explicite, Snowflake est utilisé avec NODE_ID,
+// sinon le compteur partagé avec le stockage Redis, et Snowflake sur le nœud 0 avec les autres stockages,
+// où une seule instance est attendue.
+func idGenerator(config Config) string {
+	switch {
+	case config.IDGenerator != "":
+		return config.IDGenerator
+	case config.NodeID == nil && config.Storage == StorageRedis:
+		return IDGeneratorRedis
+	default:
+		return IDGeneratorSnowflake
+	}
 }
 
 // Start lance le serveur HTTP de l'application et gère les connexions entrantes.
@@ -41,18 +161,64 @@ func (a *App) Start(ctx context.Context) error {
 		Handler: a.router,
 	}
 
-	// Vérification de la connexion à Redis.
-	err := a.rdb.Ping(ctx).Err()
-	if err != nil {
-		return fmt.Errorf("failed to connect to redis: %w", err)
+	// Les flux d'événements ne se terminent jamais d'eux-mêmes : ils sont fermés dès le début de l'arrêt,
+	// pour que Shutdown n'attende pas leur délai maximal.
+	server.RegisterOnShutdown(func() {
+		close(a.done)
+	})
+
+	// Vérification de la connexion à Redis, si elle est utilisée.
+	if a.rdb != nil {
+		err := a.rdb.Ping(ctx).Err()
+		if err != nil {
+			return fmt.Errorf("failed to connect to redis: %w", err)
+		}
+
+		// Fermeture des connexions Redis lors de l'arrêt de l'application.
+		defer func() {
+			if err := a.rdb.Close(); err != nil {
+				fmt.Println("failed to close redis", err)
+			}
+			if err := a.events.Close(); err != nil {
+				fmt.Println("failed to close redis", err)
+			}
+		}()
 	}
 
-	// Fermeture de la connexion Redis lors de l'arrêt de l'application.
-	defer func() {
-		if err := a.rdb.Close(); err != nil {
-			fmt.Println("failed to close redis", err)
+	// Vérification de la connexion à la base SQL, si elle est utilisée.
+	if a.db != nil {
+		err := a.db.PingContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
 		}
-	}()
+
+		// Fermeture de la connexion SQL lors de l'arrêt de l'application.
+		defer func() {
+			if err := a.db.Close(); err != nil {
+				fmt.Println("failed to close database", err)
+			}
+		}()
+	}
+
+	// Application des migrations de schéma avant d'accepter des requêtes.
+	if migrator, ok := a.repo.(order.Migrator); ok {
+		if err := migrator.Migrate(ctx); err != nil {
+			return fmt.Errorf("failed to migrate: %w", err)
+		}
+	}
+
+	// Purge périodique des commandes supprimées, arrêtée avec le contexte.
+	go a.purgeDeleted(ctx)
+
+	// Envoi des webhooks, arrêté avec le contexte.
+	if a.hooks != nil {
+		go a.deliverWebhooks(ctx)
+	}
+
+	// Checkpoints périodiques du journal WAL SQLite, arrêtés avec le contexte.
+	if repo, ok := a.repo.(*order.SQLiteRepo); ok {
+		go a.checkpointSQLite(ctx, repo)
+	}
 
 	fmt.Println("Starting server")
 
@@ -61,7 +227,7 @@ func (a *App) Start(ctx context.Context) error {
 
 	// Démarrage du serveur dans une goroutine.
 	go func() {
-		err = server.ListenAndServe()
+		err := server.ListenAndServe()
 		if err != nil {
 			ch <- fmt.Errorf("failed to start server: %w", err)
 		}
@@ -70,7 +236,7 @@ func (a *App) Start(ctx context.Context) error {
 
 	// Attente d'une erreur du serveur ou d'une interruption du contexte.
 	select {
-	case err = <-ch:
+	case err := <-ch:
 		return err
 	case <-ctx.Done():
 		// Création d'un contexte avec un délai pour la fermeture gracieuse du serveur.
@@ -79,6 +245,60 @@ func (a *App) Start(ctx context.Context) error {
 
 		return server.Shutdown(timeout)
 	}
+}
+
+// checkpointSQLite effectue un checkpoint du journal WAL à intervalle régulier jusqu'à l'annulation du contexte.
+func (a *App) checkpointSQLite(ctx context.Context, repo *order.SQLiteRepo) {
+	ticker := time.NewTicker(a.config.SQLiteCheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := repo.Checkpoint(ctx); err != nil {
+				fmt.Println("failed to checkpoint sqlite:", err)
+			}
+		}
+	}
+}
 
-	return nil
+// deliverWebhooks envoie les événements aux abonnés jusqu'à l'annulation du contexte.
+// Chaque instance est un consommateur du stream, nommé d'après le nom de sa machine.
+func (a *App) deliverWebhooks(ctx context.Context) {
+	consumer, err := os.Hostname()
+	if err != nil {
+		consumer = "orders-api"
+	}
+
+	worker := &webhook.Worker{
+		Store:       a.hooks,
+		HTTP:        &http.Client{Timeout: a.config.WebhookTimeout},
+		Consumer:    fmt.Sprintf("%s-%d", consumer, os.Getpid()),
+		MaxAttempts: a.config.WebhookMaxAttempts,
+		RetryDelay:  a.config.WebhookRetryDelay,
+	}
+	worker.Run(ctx)
+}
+
+// purgeDeleted supprime définitivement, à intervalle régulier, les commandes supprimées depuis plus longtemps
+// que le délai de rétention, jusqu'à l'annulation du contexte.
+func (a *App) purgeDeleted(ctx context.Context) {
+	ticker := time.NewTicker(a.config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.repo.PurgeDeleted(ctx, time.Now().Add(-a.config.DeletedRetention))
+			if err != nil {
+				fmt.Println("failed to purge deleted orders:", err)
+			} else if n > 0 {
+				fmt.Println("purged deleted orders:", n)
+			}
+		}
+	}
 }
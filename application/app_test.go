@@ -0,0 +1,67 @@
+package application
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/idgen"
+)
+
+// clearEnv retire les variables d'environnement lues par LoadConfig pour les choix de stockage et d'IDs,
+// puis les restaure à la fin du test.
+func clearEnv(t *testing.T) {
+	for _, name := range []string{"STORAGE", "ID_GENERATOR", "NODE_ID", "PRICING_FILE"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestNewDefaultConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want idgen.Generator
+	}{
+		{"redis", nil, &idgen.RedisCounter{}},
+		{"memory", map[string]string{"STORAGE": StorageMemory}, &idgen.Snowflake{}},
+		{"sqlite", map[string]string{"STORAGE": StorageSQLite, "SQLITE_PATH": ":memory:"}, &idgen.Snowflake{}},
+		{"node id", map[string]string{"NODE_ID": "3"}, &idgen.Snowflake{}},
+		{"explicit redis counter", map[string]string{"ID_GENERATOR": IDGeneratorRedis, "NODE_ID": "3"}, &idgen.RedisCounter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			app, err := New(LoadConfig())
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			t.Cleanup(func() {
+				if app.rdb != nil {
+					app.rdb.Close()
+				}
+				if app.db != nil {
+					app.db.Close()
+				}
+			})
+
+			if reflect.TypeOf(app.ids) != reflect.TypeOf(tt.want) {
+				t.Errorf("id generator = %T, want %T", app.ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSnowflakeRequiresNodeID(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("STORAGE", StorageMemory)
+	t.Setenv("ID_GENERATOR", IDGeneratorSnowflake)
+
+	if _, err := New(LoadConfig()); err == nil {
+		t.Error("New() with an explicit snowflake generator and no NODE_ID succeeded, want an error")
+	}
+}
@@ -1,23 +1,80 @@
 package application
 
 import (
+	"crypto/rand"
+	"fmt"
 	"os"
 	"strconv"
+	"time"
+
+	"github.com/SamMebarek/orders-api/validation"
 )
 
 // Config contient la configuration nécessaire pour l'application.
 type Config struct {
-	RedisAddress string // Adresse du serveur Redis.
-	ServerPort   uint16 // Port pour le serveur HTTP.
+	Storage                  string           // Système de stockage des commandes ("redis", "postgres", "sqlite" ou "memory").
+	RedisAddress             string           // Adresse du serveur Redis.
+	PostgresURL              string           // URL de connexion à PostgreSQL.
+	SQLitePath               string           // Chemin du fichier de base SQLite.
+	SQLiteCheckpointInterval time.Duration    // Intervalle entre deux checkpoints du journal WAL SQLite.
+	ServerPort               uint16           // Port pour le serveur HTTP.
+	IDGenerator              string           // Générateur des IDs de commandes ("snowflake" ou "redis"), vide pour le choisir selon NodeID et Storage.
+	NodeID                   *uint16          // Numéro de nœud de cette instance pour le générateur Snowflake, nil s'il n'est pas défini.
+	CursorSecret             []byte           // Clé de signature des cursors de pagination.
+	IdempotencyTTL           time.Duration    // Durée de conservation des réponses aux requêtes avec Idempotency-Key.
+	IdempotencyLockTTL       time.Duration    // Durée maximale de réservation d'une Idempotency-Key pendant le traitement.
+	Validation               validation.Rules // Règles de validation des commandes reçues.
+	AdminToken               string           // Jeton des administrateurs, vide pour désactiver les opérations d'administration.
+	DeletedRetention         time.Duration    // Durée de conservation des commandes supprimées avant leur purge.
+	PurgeInterval            time.Duration    // Intervalle entre deux purges des commandes supprimées.
+	EventsMaxLen             int64            // Longueur maximale du stream Redis des événements, 0 pour ne pas le tronquer.
+	EventsMaxStreams         int              // Nombre maximal de flux d'événements (SSE) ouverts en même temps.
+	WebhookMaxAttempts       int              // Nombre maximal de tentatives d'envoi d'un webhook.
+	WebhookRetryDelay        time.Duration    // Délai avant la deuxième tentative d'envoi d'un webhook, doublé ensuite.
+	WebhookTimeout           time.Duration    // Délai maximal d'une requête d'envoi d'un webhook.
+	PricingFile              string           // Fichier JSON des règles de prix (taxes, remises, livraison), vide pour n'en appliquer aucune.
 }
 
+// Systèmes de stockage disponibles pour les commandes.
+const (
+	StorageRedis    = "redis"    // Stockage dans Redis.
+	StoragePostgres = "postgres" // Stockage dans PostgreSQL.
+	StorageSQLite   = "sqlite"   // Stockage dans un fichier SQLite local.
+	StorageMemory   = "memory"   // Stockage en mémoire, pour le développement local et les tests.
+)
+
+// Générateurs d'IDs de commandes disponibles.
+const (
+	IDGeneratorSnowflake = "snowflake" // IDs triés par date, uniques tant que chaque instance a son propre NodeID.
+	IDGeneratorRedis     = "redis"     // IDs séquentiels partagés via un compteur Redis.
+)
+
 // LoadConfig charge la configuration de l'application.
 // Elle lit les variables d'environnement et définit les valeurs par défaut si nécessaire.
 func LoadConfig() Config {
 	// Configuration par défaut.
 	cfg := Config{
-		RedisAddress: "localhost:6379", // Valeur par défaut pour Redis.
-		ServerPort:   3000,             // Valeur par défaut pour le port du serveur.
+		Storage:                  StorageRedis,                       // Valeur par défaut pour le stockage.
+		RedisAddress:             "localhost:6379",                   // Valeur par défaut pour Redis.
+		PostgresURL:              "postgres://localhost:5432/orders", // Valeur par défaut pour PostgreSQL.
+		SQLitePath:               "orders.db",                        // Valeur par défaut pour le fichier SQLite.
+		SQLiteCheckpointInterval: time.Minute,                        // Valeur par défaut pour l'intervalle de checkpoint.
+		ServerPort:               3000,                               // Valeur par défaut pour le port du serveur.
+		IdempotencyTTL:           24 * time.Hour,                     // Valeur par défaut pour la conservation des réponses.
+		IdempotencyLockTTL:       time.Minute,                        // Valeur par défaut pour la réservation des clés.
+		Validation:               validation.DefaultRules(),          // Valeurs par défaut pour la validation.
+		DeletedRetention:         30 * 24 * time.Hour,                // Valeur par défaut pour la conservation des commandes supprimées.
+		PurgeInterval:            time.Hour,                          // Valeur par défaut pour l'intervalle de purge.
+		EventsMaxLen:             1000000,                            // Valeur par défaut pour la taille du stream des événements.
+		EventsMaxStreams:         100,                                // Valeur par défaut pour le nombre de flux d'événements.
+		WebhookMaxAttempts:       10,                                 // Valeur par défaut pour les tentatives d'envoi.
+		WebhookRetryDelay:        30 * time.Second,                   // Valeur par défaut pour le délai entre tentatives.
+		WebhookTimeout:           10 * time.Second,                   // Valeur par défaut pour la durée d'un envoi.
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour le stockage, si elle existe.
+	if storage, exists := os.LookupEnv("STORAGE"); exists {
+		cfg.Storage = storage
 	}
 
 	// Recherche et utilisation de la variable d'environnement pour l'adresse Redis, si elle existe.
@@ -25,6 +82,24 @@ func LoadConfig() Config {
 		cfg.RedisAddress = redisAddr
 	}
 
+	// Recherche et utilisation de la variable d'environnement pour l'URL PostgreSQL, si elle existe.
+	if postgresURL, exists := os.LookupEnv("POSTGRES_URL"); exists {
+		cfg.PostgresURL = postgresURL
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour le fichier SQLite, si elle existe.
+	if sqlitePath, exists := os.LookupEnv("SQLITE_PATH"); exists {
+		cfg.SQLitePath = sqlitePath
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour l'intervalle de checkpoint, si elle existe.
+	if interval, exists := os.LookupEnv("SQLITE_CHECKPOINT_INTERVAL"); exists {
+		// Conversion de la valeur de la variable d'environnement en une durée (par exemple "30s").
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			cfg.SQLiteCheckpointInterval = d
+		}
+	}
+
 	// Recherche et utilisation de la variable d'environnement pour le port du serveur, si elle existe.
 	if serverPort, exists := os.LookupEnv("SERVER_PORT"); exists {
 		// Conversion de la valeur de la variable d'environnement en un nombre.
@@ -33,6 +108,113 @@ func LoadConfig() Config {
 		}
 	}
 
+	// Recherche et utilisation de la variable d'environnement pour le générateur d'IDs, si elle existe.
+	if idGenerator, exists := os.LookupEnv("ID_GENERATOR"); exists {
+		cfg.IDGenerator = idGenerator
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour le numéro de nœud, si elle existe.
+	if nodeID, exists := os.LookupEnv("NODE_ID"); exists {
+		if id, err := strconv.ParseUint(nodeID, 10, 16); err == nil {
+			n := uint16(id)
+			cfg.NodeID = &n
+		}
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour la conservation des réponses idempotentes, si elle existe.
+	if ttl, exists := os.LookupEnv("IDEMPOTENCY_TTL"); exists {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			cfg.IdempotencyTTL = d
+		}
+	}
+
+	// Recherche et utilisation des variables d'environnement pour les règles de validation, si elles existent.
+	if maxLineItems, exists := os.LookupEnv("MAX_LINE_ITEMS"); exists {
+		if n, err := strconv.ParseUint(maxLineItems, 10, 31); err == nil {
+			cfg.Validation.MaxLineItems = int(n)
+		}
+	}
+	if maxQuantity, exists := os.LookupEnv("MAX_QUANTITY"); exists {
+		if n, err := strconv.ParseUint(maxQuantity, 10, 32); err == nil {
+			cfg.Validation.MaxQuantity = uint(n)
+		}
+	}
+	if requireCustomer, exists := os.LookupEnv("REQUIRE_CUSTOMER"); exists {
+		if b, err := strconv.ParseBool(requireCustomer); err == nil {
+			cfg.Validation.RequireCustomer = b
+		}
+	}
+	if requireShippingAddress, exists := os.LookupEnv("REQUIRE_SHIPPING_ADDRESS"); exists {
+		if b, err := strconv.ParseBool(requireShippingAddress); err == nil {
+			cfg.Validation.RequireShippingAddress = b
+		}
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour le jeton des administrateurs, si elle existe.
+	if adminToken, exists := os.LookupEnv("ADMIN_TOKEN"); exists {
+		cfg.AdminToken = adminToken
+	}
+
+	// Recherche et utilisation des variables d'environnement pour la purge des commandes supprimées, si elles existent.
+	if retention, exists := os.LookupEnv("DELETED_RETENTION"); exists {
+		if d, err := time.ParseDuration(retention); err == nil && d > 0 {
+			cfg.DeletedRetention = d
+		}
+	}
+	if interval, exists := os.LookupEnv("PURGE_INTERVAL"); exists {
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			cfg.PurgeInterval = d
+		}
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour la taille du stream des événements, si elle existe.
+	if maxLen, exists := os.LookupEnv("EVENTS_MAX_LEN"); exists {
+		if n, err := strconv.ParseInt(maxLen, 10, 64); err == nil && n >= 0 {
+			cfg.EventsMaxLen = n
+		}
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour le nombre de flux d'événements, si elle existe.
+	if maxStreams, exists := os.LookupEnv("EVENTS_MAX_STREAMS"); exists {
+		if n, err := strconv.ParseUint(maxStreams, 10, 31); err == nil && n > 0 {
+			cfg.EventsMaxStreams = int(n)
+		}
+	}
+
+	// Recherche et utilisation des variables d'environnement pour l'envoi des webhooks, si elles existent.
+	if maxAttempts, exists := os.LookupEnv("WEBHOOK_MAX_ATTEMPTS"); exists {
+		if n, err := strconv.ParseUint(maxAttempts, 10, 31); err == nil && n > 0 {
+			cfg.WebhookMaxAttempts = int(n)
+		}
+	}
+	if delay, exists := os.LookupEnv("WEBHOOK_RETRY_DELAY"); exists {
+		if d, err := time.ParseDuration(delay); err == nil && d > 0 {
+			cfg.WebhookRetryDelay = d
+		}
+	}
+	if timeout, exists := os.LookupEnv("WEBHOOK_TIMEOUT"); exists {
+		if d, err := time.ParseDuration(timeout); err == nil && d > 0 {
+			cfg.WebhookTimeout = d
+		}
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour le fichier des règles de prix, si elle existe.
+	if pricingFile, exists := os.LookupEnv("PRICING_FILE"); exists {
+		cfg.PricingFile = pricingFile
+	}
+
+	// Recherche et utilisation de la variable d'environnement pour la clé des cursors, si elle existe.
+	// Sinon, une clé aléatoire est générée : les cursors ne sont alors valides que sur cette instance
+	// et jusqu'à son redémarrage.
+	if cursorSecret, exists := os.LookupEnv("CURSOR_SECRET"); exists && cursorSecret != "" {
+		cfg.CursorSecret = []byte(cursorSecret)
+	} else {
+		cfg.CursorSecret = make([]byte, 32)
+		if _, err := rand.Read(cfg.CursorSecret); err != nil {
+			panic(fmt.Sprintf("failed to generate cursor secret: %v", err))
+		}
+	}
+
 	// Retourne la configuration chargée.
 	return cfg
 }
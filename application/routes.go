@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/SamMebarek/orders-api/handler"
-	"github.com/SamMebarek/orders-api/repository/order"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -14,9 +13,14 @@ func (a *App) loadRoutes() {
 	// Initialisation d'un nouveau routeur avec chi.
 	router := chi.NewRouter()
 
-	// Utilisation d'un middleware pour logger automatiquement les requêtes.
+	// Attribution d'un ID à chaque requête (ou reprise de l'en-tête X-Request-Id),
+	// puis utilisation d'un middleware pour logger automatiquement les requêtes.
+	router.Use(middleware.RequestID)
 	router.Use(middleware.Logger)
 
+	// Ajout de l'auteur et de l'ID de chaque requête à son contexte, pour l'historique des commandes.
+	router.Use(handler.Audit(a.config.AdminToken))
+
 	// Définition d'une route racine simple qui répond avec un statut HTTP 200 OK.
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -26,6 +30,9 @@ func (a *App) loadRoutes() {
 	// 'loadOrderRoutes' est appelée pour définir les routes spécifiques aux commandes.
 	router.Route("/orders", a.loadOrderRoutes)
 
+	// Configuration des routes pour la gestion des abonnements aux webhooks.
+	router.Route("/webhooks", a.loadWebhookRoutes)
+
 	// Enregistrement du routeur configuré dans l'application.
 	a.router = router
 }
@@ -34,17 +41,64 @@ func (a *App) loadRoutes() {
 // Cette méthode est utilisée pour associer les chemins d'accès aux méthodes du gestionnaire de commandes.
 func (a *App) loadOrderRoutes(router chi.Router) {
 	// Création d'un gestionnaire pour les commandes.
-	// Ce gestionnaire utilise Redis pour stocker et récupérer les données des commandes.
+	// Ce gestionnaire utilise le dépôt choisi par la configuration pour stocker et récupérer les commandes.
 	orderHandler := &handler.Order{
-		Repo: &order.RedisRepo{
-			Client: a.rdb, // Le client Redis est fourni par l'application.
-		},
+		Repo:         a.repo,                // Le dépôt est fourni par l'application.
+		IDs:          a.ids,                 // Générateur des IDs de nouvelles commandes.
+		CursorSecret: a.config.CursorSecret, // Clé de signature des cursors de pagination.
+		Rules:        a.config.Validation,   // Règles de validation des commandes reçues.
+		Pricing:      a.prices,              // Règles de prix des commandes.
+		AdminToken:   a.config.AdminToken,   // Jeton des administrateurs.
+		Done:         a.done,                // Fermé à l'arrêt du serveur.
+
+		MaxEventStreams: a.config.EventsMaxStreams, // Nombre maximal de flux d'événements ouverts.
+	}
+
+	// Création du middleware d'idempotence pour la création de commandes.
+	idempotent := &handler.Idempotency{
+		Store:   a.idem,
+		TTL:     a.config.IdempotencyTTL,
+		LockTTL: a.config.IdempotencyLockTTL,
 	}
 
 	// Association des routes avec les méthodes spécifiques du gestionnaire de commandes.
-	router.Post("/", orderHandler.Create)           // Route pour créer une nouvelle commande.
-	router.Get("/", orderHandler.List)              // Route pour lister toutes les commandes.
-	router.Get("/{id}", orderHandler.GetByID)       // Route pour obtenir une commande par son ID.
-	router.Put("/{id}", orderHandler.UpdateByID)    // Route pour mettre à jour une commande par ID.
-	router.Delete("/{id}", orderHandler.DeleteByID) // Route pour supprimer une commande par ID.
+	router.With(idempotent.Middleware).Post("/", orderHandler.Create)           // Route pour créer une nouvelle commande.
+	router.Get("/", orderHandler.List)                                          // Route pour lister toutes les commandes.
+	router.Get("/events", orderHandler.Events)                                  // Route pour le flux des événements (SSE).
+	router.Get("/{id}", orderHandler.GetByID)                                   // Route pour obtenir une commande par son ID.
+	router.Put("/{id}", orderHandler.UpdateByID)                                // Route pour mettre à jour une commande par ID.
+	router.Patch("/{id}", orderHandler.PatchByID)                               // Route pour modifier une commande par un patch JSON.
+	router.Delete("/{id}", orderHandler.DeleteByID)                             // Route pour supprimer une commande par ID.
+	router.Post("/{id}/cancel", orderHandler.CancelByID)                        // Route pour annuler une commande par ID.
+	router.Post("/{id}/restore", orderHandler.RestoreByID)                      // Route pour restaurer une commande supprimée.
+	router.Post("/{id}/line_items", orderHandler.AddLineItem)                   // Route pour ajouter un article.
+	router.Patch("/{id}/line_items/{itemID}", orderHandler.UpdateLineItem)      // Route pour modifier la quantité d'un article.
+	router.Delete("/{id}/line_items/{itemID}", orderHandler.RemoveLineItem)     // Route pour retirer un article.
+	router.Post("/{id}/shipments", orderHandler.CreateShipment)                 // Route pour enregistrer un colis expédié.
+	router.Get("/{id}/returns", orderHandler.ListReturns)                       // Route pour lister les retours d'une commande.
+	router.Post("/{id}/returns", orderHandler.CreateReturn)                     // Route pour demander un retour.
+	router.Post("/{id}/returns/{returnID}/approve", orderHandler.ApproveReturn) // Route pour accepter un retour.
+	router.Post("/{id}/returns/{returnID}/reject", orderHandler.RejectReturn)   // Route pour refuser un retour.
+	router.Post("/{id}/returns/{returnID}/receive", orderHandler.ReceiveReturn) // Route pour la réception d'un retour.
+	router.Post("/{id}/returns/{returnID}/refund", orderHandler.RefundReturn)   // Route pour rembourser un retour.
+	router.Get("/{id}/history", orderHandler.History)                           // Route pour l'historique d'une commande.
+}
+
+// loadWebhookRoutes définit les routes de gestion des abonnements aux webhooks, réservées aux administrateurs.
+func (a *App) loadWebhookRoutes(router chi.Router) {
+	webhookHandler := &handler.Webhook{
+		AdminToken: a.config.AdminToken, // Jeton des administrateurs.
+	}
+	// Les webhooks ne sont disponibles qu'avec le stockage Redis.
+	if a.hooks != nil {
+		webhookHandler.Store = a.hooks
+	}
+
+	router.Use(webhookHandler.Guard)
+	router.Post("/", webhookHandler.Create)                      // Route pour créer un abonnement.
+	router.Get("/", webhookHandler.List)                         // Route pour lister les abonnements.
+	router.Get("/{id}", webhookHandler.GetByID)                  // Route pour obtenir un abonnement par ID.
+	router.Delete("/{id}", webhookHandler.DeleteByID)            // Route pour supprimer un abonnement par ID.
+	router.Get("/{id}/deliveries", webhookHandler.Deliveries)    // Route pour le journal des envois.
+	router.Get("/{id}/dead-letters", webhookHandler.DeadLetters) // Route pour les envois abandonnés.
 }
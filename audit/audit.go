@@ -0,0 +1,147 @@
+// Package audit décrit l'historique des modifications des commandes :
+// qui a changé quoi, quand, et dans quelle requête.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+)
+
+// Actions enregistrées dans l'historique.
+const (
+	ActionCreate  = "create"  // Création de la commande.
+	ActionUpdate  = "update"  // Modification de la commande.
+	ActionDelete  = "delete"  // Suppression de la commande.
+	ActionRestore = "restore" // Restauration d'une commande supprimée.
+)
+
+// Auteurs enregistrés dans l'historique. Seul le jeton d'administration authentifie une requête :
+// l'auteur d'une requête sans ce jeton n'est pas vérifié et est enregistré comme AnonymousActor.
+const (
+	SystemActor    = "system"    // Modification faite hors d'une requête, par exemple par une tâche de fond.
+	AdminActor     = "admin"     // Requête authentifiée par le jeton d'administration.
+	AnonymousActor = "anonymous" // Requête non authentifiée.
+)
+
+// Entry est une entrée de l'historique d'une commande.
+type Entry struct {
+	Action       string    `json:"action"`                  // Type de modification.
+	Version      uint64    `json:"version"`                 // Version de la commande après la modification.
+	Actor        string    `json:"actor"`                   // Auteur vérifié de la modification : system, admin ou anonymous.
+	ClaimedActor string    `json:"claimed_actor,omitempty"` // Auteur déclaré par la requête (en-tête X-Actor), non vérifié.
+	RequestID    string    `json:"request_id,omitempty"`    // ID de la requête à l'origine de la modification.
+	At           time.Time `json:"at"`                      // Date de la modification.
+	Changes      []Change  `json:"changes"`                 // Champs modifiés.
+}
+
+// Change décrit la modification d'un champ de la commande, avec ses valeurs JSON avant et après.
+type Change struct {
+	Field  string          `json:"field"`  // Nom JSON du champ.
+	Before json.RawMessage `json:"before"` // Valeur avant la modification, null à la création.
+	After  json.RawMessage `json:"after"`  // Valeur après la modification.
+}
+
+// NewEntry crée l'entrée d'historique du passage de before à after.
+// before est nil à la création de la commande. L'auteur et l'ID de requête sont lus dans le contexte.
+func NewEntry(ctx context.Context, before *model.Order, after model.Order) (Entry, error) {
+	changes, err := diff(before, after)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return Entry{
+		Action:       action(before, after),
+		Version:      after.Version,
+		Actor:        Actor(ctx),
+		ClaimedActor: ClaimedActor(ctx),
+		RequestID:    RequestID(ctx),
+		At:           time.Now().UTC(),
+		Changes:      changes,
+	}, nil
+}
+
+// action déduit le type de modification des deux états de la commande.
+func action(before *model.Order, after model.Order) string {
+	switch {
+	case before == nil:
+		return ActionCreate
+	case before.DeletedAt == nil && after.DeletedAt != nil:
+		return ActionDelete
+	case before.DeletedAt != nil && after.DeletedAt == nil:
+		return ActionRestore
+	default:
+		return ActionUpdate
+	}
+}
+
+// diff compare les champs JSON de premier niveau des deux états de la commande.
+// La version n'est pas comparée : elle change à chaque modification et figure déjà dans l'entrée.
+func diff(before *model.Order, after model.Order) ([]Change, error) {
+	old := map[string]json.RawMessage{}
+	if before != nil {
+		var err error
+		if old, err = fields(*before); err != nil {
+			return nil, err
+		}
+	}
+
+	cur, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(cur))
+	for name := range cur {
+		names = append(names, name)
+	}
+	for name := range old {
+		if _, exists := cur[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []Change{}
+	for _, name := range names {
+		if name == "version" {
+			continue
+		}
+
+		// Un champ absent équivaut à null.
+		b, a := old[name], cur[name]
+		if b == nil {
+			b = json.RawMessage("null")
+		}
+		if a == nil {
+			a = json.RawMessage("null")
+		}
+		if bytes.Equal(b, a) {
+			continue
+		}
+
+		changes = append(changes, Change{Field: name, Before: b, After: a})
+	}
+
+	return changes, nil
+}
+
+// fields retourne les champs JSON de premier niveau d'une commande.
+func fields(order model.Order) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+
+	return m, nil
+}
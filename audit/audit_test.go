@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// testOrder retourne une commande en attente de version 1.
+func testOrder() model.Order {
+	return model.Order{
+		OrderID:    1,
+		Version:    1,
+		Status:     model.StatusPending,
+		CustomerID: uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		Notes:      "Sonner deux fois",
+	}
+}
+
+// changesByField indexe les modifications par champ, avec leurs valeurs JSON compactes.
+func changesByField(t *testing.T, changes []Change) map[string][2]string {
+	t.Helper()
+	m := make(map[string][2]string, len(changes))
+	for _, c := range changes {
+		if !json.Valid(c.Before) || !json.Valid(c.After) {
+			t.Fatalf("change of %s has invalid JSON: %s -> %s", c.Field, c.Before, c.After)
+		}
+		m[c.Field] = [2]string{string(c.Before), string(c.After)}
+	}
+	return m
+}
+
+func TestNewEntryDiff(t *testing.T) {
+	before := testOrder()
+	after := before
+	after.Version = 2
+	after.Status = model.StatusPaid
+	after.Notes = ""
+
+	entry, err := NewEntry(context.Background(), &before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != ActionUpdate || entry.Version != 2 {
+		t.Errorf("entry = %s version %d, want update version 2", entry.Action, entry.Version)
+	}
+
+	// Seuls les champs modifiés figurent, la version exceptée ; un champ omis vaut null.
+	want := map[string][2]string{
+		"status": {`"pending"`, `"paid"`},
+		"notes":  {`"Sonner deux fois"`, `null`},
+	}
+	got := changesByField(t, entry.Changes)
+	if len(got) != len(want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	for field, values := range want {
+		if got[field] != values {
+			t.Errorf("change of %s = %v, want %v", field, got[field], values)
+		}
+	}
+	if entry.Changes[0].Field > entry.Changes[len(entry.Changes)-1].Field {
+		t.Errorf("changes are not sorted by field: %v", got)
+	}
+}
+
+func TestNewEntryCreate(t *testing.T) {
+	o := testOrder()
+
+	entry, err := NewEntry(context.Background(), nil, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != ActionCreate {
+		t.Errorf("action = %s, want %s", entry.Action, ActionCreate)
+	}
+
+	// À la création, chaque champ passe de null à sa valeur.
+	got := changesByField(t, entry.Changes)
+	if got["order_id"] != [2]string{"null", "1"} || got["status"] != [2]string{"null", `"pending"`} {
+		t.Errorf("changes = %v, want order_id and status from null", got)
+	}
+	if _, exists := got["version"]; exists {
+		t.Errorf("changes include the version: %v", got)
+	}
+}
+
+func TestNewEntryAction(t *testing.T) {
+	deleted := time.Now().UTC()
+	live := testOrder()
+	gone := live
+	gone.DeletedAt = &deleted
+
+	tests := []struct {
+		name          string
+		before, after model.Order
+		want          string
+	}{
+		{"update", live, live, ActionUpdate},
+		{"delete", live, gone, ActionDelete},
+		{"restore", gone, live, ActionRestore},
+	}
+
+	for _, tt := range tests {
+		entry, err := NewEntry(context.Background(), &tt.before, tt.after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Action != tt.want {
+			t.Errorf("%s: action = %s, want %s", tt.name, entry.Action, tt.want)
+		}
+	}
+}
+
+func TestNewEntryContext(t *testing.T) {
+	o := testOrder()
+
+	// Hors d'une requête, la modification est attribuée au système.
+	entry, err := NewEntry(context.Background(), nil, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Actor != SystemActor || entry.ClaimedActor != "" || entry.RequestID != "" {
+		t.Errorf("entry without context = %q %q %q, want %q and no claimed actor or request id",
+			entry.Actor, entry.ClaimedActor, entry.RequestID, SystemActor)
+	}
+
+	ctx := WithRequestID(WithClaimedActor(WithActor(context.Background(), AnonymousActor), "jeanne"), "req-1")
+	if entry, err = NewEntry(ctx, nil, o); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Actor != AnonymousActor || entry.ClaimedActor != "jeanne" || entry.RequestID != "req-1" {
+		t.Errorf("entry = %q %q %q, want %q jeanne req-1", entry.Actor, entry.ClaimedActor, entry.RequestID, AnonymousActor)
+	}
+}
@@ -0,0 +1,48 @@
+package audit
+
+import "context"
+
+// contextKey est le type des clés de contexte du package, distinct de celles des autres packages.
+type contextKey int
+
+// Clés des informations d'audit dans le contexte.
+const (
+	actorKey contextKey = iota
+	claimedActorKey
+	requestIDKey
+)
+
+// WithActor retourne un contexte portant l'auteur des modifications.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor retourne l'auteur porté par le contexte, ou SystemActor s'il n'y en a pas.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+// WithClaimedActor retourne un contexte portant l'auteur déclaré par la requête, qui n'a pas été vérifié.
+func WithClaimedActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, claimedActorKey, actor)
+}
+
+// ClaimedActor retourne l'auteur déclaré porté par le contexte, ou une chaîne vide.
+func ClaimedActor(ctx context.Context) string {
+	actor, _ := ctx.Value(claimedActorKey).(string)
+	return actor
+}
+
+// WithRequestID retourne un contexte portant l'ID de la requête à l'origine des modifications.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID retourne l'ID de requête porté par le contexte, ou une chaîne vide.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
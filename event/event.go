@@ -0,0 +1,133 @@
+// Package event décrit les événements publiés à chaque modification d'une commande.
+//
+// Chaque création ou modification produit exactement un événement, sérialisé en JSON :
+//
+//	{
+//	  "id": "5f0c6c8e-8a7e-4d43-9f0e-3f7f1b0c2a11",   // ID unique de l'événement (UUID)
+//	  "type": "OrderShipped",                          // Type de l'événement, voir les constantes Type
+//	  "occurred_at": "2024-01-02T10:00:00Z",           // Date de la modification (RFC 3339, UTC)
+//	  "order_id": 501720752433659904,                   // ID de la commande
+//	  "version": 3,                                     // Version de la commande après la modification
+//	  "previous_status": "paid",                        // Statut précédent, pour les changements de statut
+//	  "order": { ... }                                  // Commande après la modification, comme dans l'API
+//	}
+//
+// Les consommateurs doivent ignorer les champs et les types d'événements inconnus.
+package event
+
+import (
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// Type est le type d'un événement.
+type Type string
+
+// Types d'événements publiés.
+const (
+	OrderCreated          Type = "OrderCreated"          // Commande créée.
+	OrderUpdated          Type = "OrderUpdated"          // Commande modifiée sans changement de statut.
+	OrderStatusChanged    Type = "OrderStatusChanged"    // Statut changé, hors des statuts ayant leur propre type.
+	OrderShipped          Type = "OrderShipped"          // Commande expédiée.
+	OrderPartiallyShipped Type = "OrderPartiallyShipped" // Premier colis d'une commande qui en attend d'autres.
+	OrderShipmentAdded    Type = "OrderShipmentAdded"    // Nouveau colis d'une commande restée partiellement expédiée.
+	OrderReturnRequested  Type = "OrderReturnRequested"  // Demande de retour d'articles.
+	OrderRefundIssued     Type = "OrderRefundIssued"     // Retour remboursé, la commande passant à refunded si tout est remboursé.
+	OrderCompleted        Type = "OrderCompleted"        // Commande finalisée.
+	OrderCancelled        Type = "OrderCancelled"        // Commande annulée.
+	OrderDeleted          Type = "OrderDeleted"          // Commande supprimée (suppression logique).
+	OrderRestored         Type = "OrderRestored"         // Commande supprimée puis restaurée.
+)
+
+// Types retourne tous les types d'événements publiés.
+func Types() []Type {
+	return []Type{
+		OrderCreated, OrderUpdated, OrderStatusChanged, OrderShipped, OrderPartiallyShipped, OrderShipmentAdded,
+		OrderReturnRequested, OrderRefundIssued, OrderCompleted, OrderCancelled, OrderDeleted, OrderRestored,
+	}
+}
+
+// Valid indique si t est un type d'événement connu.
+func (t Type) Valid() bool {
+	for _, known := range Types() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event est un événement publié lors de la modification d'une commande.
+type Event struct {
+	ID             string       `json:"id"`                        // ID unique de l'événement.
+	Type           Type         `json:"type"`                      // Type de l'événement.
+	OccurredAt     time.Time    `json:"occurred_at"`               // Date de la modification.
+	OrderID        uint64       `json:"order_id"`                  // ID de la commande.
+	Version        uint64       `json:"version"`                   // Version de la commande après la modification.
+	PreviousStatus model.Status `json:"previous_status,omitempty"` // Statut précédent, pour les changements de statut.
+	Order          model.Order  `json:"order"`                     // Commande après la modification.
+}
+
+// FromChange crée l'événement du passage de before à after. before est nil à la création de la commande.
+func FromChange(before *model.Order, after model.Order) Event {
+	e := Event{
+		ID:         uuid.NewString(),
+		Type:       changeType(before, after),
+		OccurredAt: time.Now().UTC(),
+		OrderID:    after.OrderID,
+		Version:    after.Version,
+		Order:      after,
+	}
+
+	if before != nil && before.CurrentStatus() != after.CurrentStatus() {
+		e.PreviousStatus = before.CurrentStatus()
+	}
+
+	return e
+}
+
+// changeType déduit le type d'événement des deux états de la commande.
+func changeType(before *model.Order, after model.Order) Type {
+	switch {
+	case before == nil:
+		return OrderCreated
+	case before.DeletedAt == nil && after.DeletedAt != nil:
+		return OrderDeleted
+	case before.DeletedAt != nil && after.DeletedAt == nil:
+		return OrderRestored
+	case len(after.Returns) > len(before.Returns):
+		return OrderReturnRequested
+	case refunds(after) > refunds(*before):
+		return OrderRefundIssued
+	case before.CurrentStatus() == after.CurrentStatus() && len(after.Shipments) > len(before.Shipments):
+		return OrderShipmentAdded
+	case before.CurrentStatus() == after.CurrentStatus():
+		return OrderUpdated
+	}
+
+	switch after.CurrentStatus() {
+	case model.StatusPartiallyShipped:
+		return OrderPartiallyShipped
+	case model.StatusShipped:
+		return OrderShipped
+	case model.StatusCompleted:
+		return OrderCompleted
+	case model.StatusCancelled:
+		return OrderCancelled
+	default:
+		return OrderStatusChanged
+	}
+}
+
+// refunds retourne le nombre de retours remboursés de la commande.
+func refunds(o model.Order) int {
+	n := 0
+	for _, ret := range o.Returns {
+		if ret.Refund != nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+func TestFromChange(t *testing.T) {
+	now := time.Now().UTC()
+	order := func(status model.Status, change func(o *model.Order)) model.Order {
+		o := model.Order{OrderID: 7, Version: 2, Status: status}
+		if change != nil {
+			change(&o)
+		}
+		return o
+	}
+	shipped := func(n int) func(o *model.Order) {
+		return func(o *model.Order) {
+			for i := 0; i < n; i++ {
+				o.Shipments = append(o.Shipments, model.Shipment{ShipmentID: uuid.New()})
+			}
+		}
+	}
+	returnID := uuid.New()
+	returned := func(refunded bool) func(o *model.Order) {
+		return func(o *model.Order) {
+			ret := model.Return{ReturnID: returnID}
+			if refunded {
+				ret.Refund = &model.Refund{Amount: model.Money{Amount: 100, Currency: "EUR"}}
+			}
+			o.Returns = append(o.Returns, ret)
+		}
+	}
+	deleted := func(o *model.Order) { o.DeletedAt = &now }
+
+	tests := []struct {
+		name     string
+		before   *model.Order
+		after    model.Order
+		want     Type
+		previous model.Status
+	}{
+		{"created", nil, order(model.StatusPending, nil), OrderCreated, ""},
+		{"updated", ptr(order(model.StatusPending, nil)), order(model.StatusPending, nil), OrderUpdated, ""},
+		{"paid", ptr(order(model.StatusPending, nil)), order(model.StatusPaid, nil), OrderStatusChanged, model.StatusPending},
+		{"partially shipped", ptr(order(model.StatusPaid, nil)), order(model.StatusPartiallyShipped, shipped(1)),
+			OrderPartiallyShipped, model.StatusPaid},
+		{"shipment added", ptr(order(model.StatusPartiallyShipped, shipped(1))),
+			order(model.StatusPartiallyShipped, shipped(2)), OrderShipmentAdded, ""},
+		{"shipped", ptr(order(model.StatusPartiallyShipped, shipped(1))), order(model.StatusShipped, shipped(2)),
+			OrderShipped, model.StatusPartiallyShipped},
+		{"completed", ptr(order(model.StatusDelivered, nil)), order(model.StatusCompleted, nil),
+			OrderCompleted, model.StatusDelivered},
+		{"cancelled", ptr(order(model.StatusPending, nil)), order(model.StatusCancelled, nil),
+			OrderCancelled, model.StatusPending},
+		{"return requested", ptr(order(model.StatusDelivered, nil)), order(model.StatusDelivered, returned(false)),
+			OrderReturnRequested, ""},
+		{"refund issued", ptr(order(model.StatusDelivered, returned(false))), order(model.StatusRefunded, returned(true)),
+			OrderRefundIssued, model.StatusDelivered},
+		// Un remboursement partiel ne change pas le statut.
+		{"partial refund", ptr(order(model.StatusDelivered, returned(false))), order(model.StatusDelivered, returned(true)),
+			OrderRefundIssued, ""},
+		{"deleted", ptr(order(model.StatusPending, nil)), order(model.StatusPending, deleted), OrderDeleted, ""},
+		{"restored", ptr(order(model.StatusPending, deleted)), order(model.StatusPending, nil), OrderRestored, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := FromChange(tt.before, tt.after)
+			if e.Type != tt.want {
+				t.Errorf("FromChange() type = %s, want %s", e.Type, tt.want)
+			}
+			if e.PreviousStatus != tt.previous {
+				t.Errorf("FromChange() previous status = %q, want %q", e.PreviousStatus, tt.previous)
+			}
+			if e.ID == "" || e.OrderID != 7 || e.Version != 2 || e.Order.Status != tt.after.Status {
+				t.Errorf("FromChange() = %+v, want a new event for version 2 of order 7", e)
+			}
+			if !e.Type.Valid() {
+				t.Errorf("FromChange() type %s is not valid", e.Type)
+			}
+		})
+	}
+}
+
+// ptr retourne un pointeur vers une copie de o.
+func ptr(o model.Order) *model.Order {
+	return &o
+}
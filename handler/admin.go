@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// isAdmin indique si la requête porte le jeton d'administration dans l'en-tête Authorization (schéma Bearer).
+// Sans jeton configuré, aucune requête n'est administrateur.
+func isAdmin(r *http.Request, adminToken string) bool {
+	if adminToken == "" {
+		return false
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// isAdmin indique si la requête porte le jeton d'administration des commandes.
+func (h *Order) isAdmin(r *http.Request) bool {
+	return isAdmin(r, h.AdminToken)
+}
+
+// includeDeleted lit le paramètre 'include_deleted', réservé aux administrateurs.
+func (h *Order) includeDeleted(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidParameter("include_deleted", "include_deleted must be a boolean")
+	}
+	if include && !h.isAdmin(r) {
+		return false, newProblem(http.StatusForbidden, CodeForbidden, "include_deleted requires an admin token")
+	}
+
+	return include, nil
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SamMebarek/orders-api/audit"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// actorHeader est l'en-tête dans lequel le client déclare l'auteur d'une modification, sans que ce soit vérifié.
+const actorHeader = "X-Actor"
+
+// Audit retourne un middleware qui ajoute au contexte de la requête son auteur et son ID, fourni par
+// middleware.RequestID. Les dépôts les enregistrent dans l'historique des commandes modifiées.
+// L'auteur est audit.AdminActor si la requête porte le jeton adminToken, audit.AnonymousActor sinon :
+// l'en-tête X-Actor n'est conservé que comme auteur déclaré.
+func Audit(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor := audit.AnonymousActor
+			if isAdmin(r, adminToken) {
+				actor = audit.AdminActor
+			}
+
+			ctx := audit.WithActor(r.Context(), actor)
+			if claimed := strings.TrimSpace(r.Header.Get(actorHeader)); claimed != "" {
+				ctx = audit.WithClaimedActor(ctx, claimed)
+			}
+			ctx = audit.WithRequestID(ctx, middleware.GetReqID(ctx))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// History est une méthode HTTP pour obtenir l'historique des modifications d'une commande.
+func (h *Order) History(w http.ResponseWriter, r *http.Request) {
+	// Seuls certains dépôts conservent l'historique. Sinon, renvoie une erreur 501 (Not Implemented).
+	reader, ok := h.Repo.(order.HistoryReader)
+	if !ok {
+		writeError(w, r, newProblem(http.StatusNotImplemented, CodeNotSupported,
+			"order history is not available with this storage"))
+		return
+	}
+
+	// Extraction de l'ID de commande de l'URL.
+	idParam := chi.URLParam(r, "id")
+
+	// Conversion de l'ID en type uint64. Si échec, renvoie une erreur 400 (Bad Request).
+	const base = 10
+	const bitSize = 64
+	orderID, err := strconv.ParseUint(idParam, base, bitSize)
+	if err != nil {
+		writeError(w, r, invalidParameter("id", "order id must be an unsigned integer"))
+		return
+	}
+
+	// Récupération de l'option 'include_deleted', réservée aux administrateurs.
+	includeDeleted, err := h.includeDeleted(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Vérification de l'existence de la commande. Si elle n'existe pas, renvoie une erreur 404 (Not Found).
+	if _, err := h.Repo.FindByID(r.Context(), orderID, order.FindOptions{IncludeDeleted: includeDeleted}); err != nil {
+		writeError(w, r, fmt.Errorf("failed to find by id: %w", err))
+		return
+	}
+
+	entries, err := reader.History(r.Context(), orderID)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to get history: %w", err))
+		return
+	}
+
+	// Envoi de l'historique, de la plus ancienne modification à la plus récente.
+	var response struct {
+		Items []audit.Entry `json:"items"` // Entrées de l'historique.
+	}
+	response.Items = entries
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Println("failed to marshal:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
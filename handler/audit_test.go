@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/audit"
+)
+
+func TestHistory(t *testing.T) {
+	srv := testServer(t)
+
+	res := call(t, srv, http.MethodPost, "/orders", orderBody, "X-Request-Id", "req-create", "X-Actor", "jeanne")
+	if res.status != http.StatusCreated {
+		t.Fatalf("POST /orders = %d %s, want 201", res.status, res.body)
+	}
+	o := create(t, srv)
+	path := fmt.Sprintf("/orders/%d", o.OrderID)
+
+	// Seul le jeton d'administration authentifie l'auteur ; X-Actor est conservé comme auteur déclaré.
+	headers := append([]string{"X-Request-Id", "req-pay", "X-Actor", "paiements"}, admin...)
+	if res := call(t, srv, http.MethodPut, path, `{"status": "paid"}`, headers...); res.status != http.StatusOK {
+		t.Fatalf("PUT = %d %s, want 200", res.status, res.body)
+	}
+
+	res = call(t, srv, http.MethodGet, path+"/history", "")
+	if res.status != http.StatusOK {
+		t.Fatalf("GET history = %d %s, want 200", res.status, res.body)
+	}
+	var history struct {
+		Items []audit.Entry `json:"items"`
+	}
+	res.decode(t, &history)
+	if len(history.Items) != 2 {
+		t.Fatalf("history has %d entries, want 2: %s", len(history.Items), res.body)
+	}
+
+	created, paid := history.Items[0], history.Items[1]
+	if created.Action != audit.ActionCreate || created.Version != 1 || created.Actor != audit.AnonymousActor ||
+		created.ClaimedActor != "" || created.RequestID == "" {
+		t.Errorf("creation entry = %+v, want an anonymous create with a generated request id", created)
+	}
+	if paid.Action != audit.ActionUpdate || paid.Version != 2 || paid.Actor != audit.AdminActor ||
+		paid.ClaimedActor != "paiements" || paid.RequestID != "req-pay" {
+		t.Errorf("update entry = %+v, want an admin update claimed by paiements in request req-pay", paid)
+	}
+
+	// La modification du statut porte ses valeurs avant et après.
+	var status *audit.Change
+	for i, c := range paid.Changes {
+		if c.Field == "status" {
+			status = &paid.Changes[i]
+		}
+	}
+	if status == nil {
+		t.Fatalf("update changes = %+v, want a status change", paid.Changes)
+	}
+	var before, after string
+	if err := json.Unmarshal(status.Before, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(status.After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if before != "pending" || after != "paid" {
+		t.Errorf("status change = %s -> %s, want pending -> paid", before, after)
+	}
+
+	// L'ID de requête fourni par le client est repris dans l'historique de sa commande.
+	var first struct {
+		Items []audit.Entry `json:"items"`
+	}
+	call(t, srv, http.MethodGet, "/orders/1/history", "").decode(t, &first)
+	if len(first.Items) != 1 || first.Items[0].RequestID != "req-create" || first.Items[0].ClaimedActor != "jeanne" {
+		t.Errorf("history of order 1 = %+v, want request req-create claimed by jeanne", first.Items)
+	}
+
+	call(t, srv, http.MethodGet, "/orders/42/history", "").expectProblem(t, http.StatusNotFound, CodeOrderNotFound)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/SamMebarek/orders-api/repository/order"
+)
+
+// errInvalidCursor est retournée lorsqu'un cursor de pagination est mal formé ou a été modifié.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorPayloadSize est la taille du contenu d'un cursor : date de création (ns) puis ID de commande.
+const cursorPayloadSize = 16
+
+// encodeCursor transforme un cursor de pagination en jeton opaque.
+// Le jeton est signé avec HMAC-SHA256 afin que le client ne puisse pas le fabriquer ou le modifier.
+// La signature couvre aussi les filtres de page : le jeton n'est valide que pour la même recherche.
+func (h *Order) encodeCursor(c order.Cursor, page order.FindAllPage) string {
+	payload := make([]byte, cursorPayloadSize)
+	binary.BigEndian.PutUint64(payload[:8], uint64(c.CreatedAt.UnixNano()))
+	binary.BigEndian.PutUint64(payload[8:], c.OrderID)
+
+	return base64.RawURLEncoding.EncodeToString(append(payload, h.signCursor(payload, page)...))
+}
+
+// decodeCursor vérifie la signature d'un jeton pour les filtres de page et retourne le cursor qu'il contient.
+// Un jeton obtenu avec d'autres filtres est refusé.
+func (h *Order) decodeCursor(token string, page order.FindAllPage) (*order.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) != cursorPayloadSize+sha256.Size {
+		return nil, errInvalidCursor
+	}
+
+	payload, mac := data[:cursorPayloadSize], data[cursorPayloadSize:]
+	if !hmac.Equal(mac, h.signCursor(payload, page)) {
+		return nil, errInvalidCursor
+	}
+
+	return &order.Cursor{
+		CreatedAt: time.Unix(0, int64(binary.BigEndian.Uint64(payload[:8]))).UTC(),
+		OrderID:   binary.BigEndian.Uint64(payload[8:]),
+	}, nil
+}
+
+// signCursor calcule la signature du contenu d'un cursor et des filtres de la page.
+// La taille de page n'en fait pas partie : le client peut la changer d'une page à l'autre.
+func (h *Order) signCursor(payload []byte, page order.FindAllPage) []byte {
+	filters := make([]byte, 0, 64)
+	filters = append(filters, page.CustomerID[:]...)
+	filters = binary.BigEndian.AppendUint64(filters, uint64(unixNano(page.CreatedAfter)))
+	filters = binary.BigEndian.AppendUint64(filters, uint64(unixNano(page.CreatedBefore)))
+	if page.IncludeDeleted {
+		filters = append(filters, 1)
+	} else {
+		filters = append(filters, 0)
+	}
+	filters = append(filters, page.Status...)
+
+	mac := hmac.New(sha256.New, h.CursorSecret)
+	mac.Write(payload)
+	mac.Write(filters)
+	return mac.Sum(nil)
+}
+
+// unixNano retourne la date t en nanosecondes depuis l'époque Unix, ou 0 pour la date zéro.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
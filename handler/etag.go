@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SamMebarek/orders-api/model"
+)
+
+// etag retourne l'ETag d'une commande, dérivé de sa version.
+func etag(o model.Order) string {
+	return fmt.Sprintf(`"%d"`, o.Version)
+}
+
+// ifMatch indique si l'en-tête If-Match de la requête est satisfait par l'ETag donné.
+// Une requête sans If-Match est toujours satisfaite.
+func ifMatch(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
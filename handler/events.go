@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/google/uuid"
+)
+
+// Paramètres des flux d'événements.
+const (
+	eventsBatchSize   = 100              // Nombre maximal d'événements lus à la fois.
+	eventsPoll        = time.Second      // Attente maximale d'un événement, qui rythme aussi la détection de l'arrêt.
+	eventsKeepAlive   = 15 * time.Second // Intervalle des commentaires envoyés pour garder la connexion ouverte.
+	eventsRetryMillis = 3000             // Délai de reconnexion conseillé aux clients, en millisecondes.
+	eventsRetryAfter  = "5"              // Délai conseillé, en secondes, lorsque trop de flux sont ouverts.
+)
+
+// Events est une méthode HTTP qui envoie en continu les événements des commandes au format Server-Sent Events.
+// Chaque message a pour "id" la position de l'événement dans le journal : un client reconnecté avec l'en-tête
+// Last-Event-ID reprend après le dernier événement reçu. Le flux se termine à la fermeture de h.Done.
+// Au-delà de h.MaxEventStreams flux ouverts, renvoie une erreur 503 (Service Unavailable).
+func (h *Order) Events(w http.ResponseWriter, r *http.Request) {
+	// Seuls certains dépôts publient les événements. Sinon, renvoie une erreur 501 (Not Implemented).
+	reader, ok := h.Repo.(order.EventReader)
+	if !ok {
+		writeError(w, r, newProblem(http.StatusNotImplemented, CodeNotSupported,
+			"order events are not available with this storage"))
+		return
+	}
+
+	// Récupération du filtre optionnel 'customer_id'. Si invalide, renvoie une erreur 400 (Bad Request).
+	var customerID uuid.UUID
+	if customerIDStr := r.URL.Query().Get("customer_id"); customerIDStr != "" {
+		var err error
+		customerID, err = uuid.Parse(customerIDStr)
+		if err != nil {
+			writeError(w, r, invalidParameter("customer_id", "customer_id must be a UUID"))
+			return
+		}
+	}
+
+	// Récupération du filtre optionnel 'status', appliqué au statut de la commande après l'événement.
+	status := model.Status(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		writeError(w, r, invalidParameter("status", fmt.Sprintf("unknown status %q", status)))
+		return
+	}
+
+	// Position de départ : après le dernier événement reçu par le client, ou après le dernier événement publié.
+	after := r.Header.Get("Last-Event-ID")
+	if after != "" {
+		if !validEventID(after) {
+			writeError(w, r, invalidParameter("Last-Event-ID", "Last-Event-ID must be an event id"))
+			return
+		}
+	} else {
+		var err error
+		after, err = reader.LastEventID(r.Context())
+		if err != nil {
+			writeError(w, r, fmt.Errorf("failed to get last event id: %w", err))
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, fmt.Errorf("response writer does not support flushing"))
+		return
+	}
+
+	// Chaque flux occupe une connexion Redis pendant ses attentes : leur nombre est limité.
+	if open := h.eventStreams.Add(1); h.MaxEventStreams > 0 && open > int64(h.MaxEventStreams) {
+		h.eventStreams.Add(-1)
+		w.Header().Set("Retry-After", eventsRetryAfter)
+		writeError(w, r, newProblem(http.StatusServiceUnavailable, CodeTooManyStreams,
+			fmt.Sprintf("at most %d event streams can be open at the same time", h.MaxEventStreams)))
+		return
+	}
+	defer h.eventStreams.Add(-1)
+
+	// Le flux s'arrête à la déconnexion du client ou à l'arrêt du serveur.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.Done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Envoi des en-têtes du flux et du délai de reconnexion conseillé.
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetryMillis)
+	flusher.Flush()
+
+	lastWrite := time.Now()
+	for ctx.Err() == nil {
+		events, err := reader.ReadEvents(ctx, after, eventsBatchSize, eventsPoll)
+		if err != nil {
+			// Le client se reconnectera avec le dernier ID reçu.
+			if ctx.Err() == nil {
+				fmt.Println("failed to read events:", err)
+			}
+			return
+		}
+
+		for _, e := range events {
+			after = e.ID
+			if customerID != uuid.Nil && e.Event.Order.CustomerID != customerID {
+				continue
+			}
+			if status != "" && e.Event.Order.CurrentStatus() != status {
+				continue
+			}
+
+			data, err := json.Marshal(e.Event)
+			if err != nil {
+				fmt.Println("failed to marshal:", err)
+				return
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Event.Type, data)
+			lastWrite = time.Now()
+		}
+
+		// Un commentaire est envoyé régulièrement pour que les proxys ne ferment pas une connexion inactive.
+		if time.Since(lastWrite) >= eventsKeepAlive {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			lastWrite = time.Now()
+		}
+		flusher.Flush()
+	}
+}
+
+// validEventID indique si id a la forme d'une position dans le journal des événements ("<millisecondes>-<séquence>").
+func validEventID(id string) bool {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found {
+		return false
+	}
+	_, errMS := strconv.ParseUint(ms, 10, 64)
+	_, errSeq := strconv.ParseUint(seq, 10, 64)
+	return errMS == nil && errSeq == nil
+}
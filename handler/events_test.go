@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/SamMebarek/orders-api/validation"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// eventsServer démarre un serveur des routes de création, de modification et du flux des événements
+// des commandes, sur un RedisRepo (miniredis) qui publie les événements. Au plus maxStreams flux sont ouverts.
+func eventsServer(t *testing.T, maxStreams int) *httptest.Server {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	events := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	done := make(chan struct{})
+
+	h := &Order{
+		Repo:            &order.RedisRepo{Client: client, EventsClient: events},
+		IDs:             &sequence{},
+		Rules:           validation.DefaultRules(),
+		Done:            done,
+		MaxEventStreams: maxStreams,
+	}
+
+	router := chi.NewRouter()
+	router.Route("/orders", func(router chi.Router) {
+		router.Post("/", h.Create)
+		router.Get("/events", h.Events)
+		router.Put("/{id}", h.UpdateByID)
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(func() {
+		close(done)
+		srv.Close()
+		client.Close()
+		events.Close()
+	})
+	return srv
+}
+
+// message est un message Server-Sent Events reçu.
+type message struct {
+	id    string
+	event event.Event
+}
+
+// stream est un flux d'événements ouvert sur le serveur de test.
+type stream struct {
+	res    *http.Response
+	lines  *bufio.Scanner
+	cancel context.CancelFunc
+}
+
+// openStream ouvre le flux des événements de query, en envoyant l'en-tête Last-Event-ID lastID s'il n'est pas vide.
+func openStream(t *testing.T, srv *httptest.Server, query, lastID string) *stream {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	s := &stream{res: res, lines: bufio.NewScanner(res.Body), cancel: cancel}
+	t.Cleanup(s.close)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /orders/events%s = %d, want 200", query, res.StatusCode)
+	}
+	return s
+}
+
+// close ferme le flux.
+func (s *stream) close() {
+	s.cancel()
+	s.res.Body.Close()
+}
+
+// next lit les n messages suivants du flux, en ignorant les commentaires et le délai de reconnexion.
+func (s *stream) next(t *testing.T, n int) []message {
+	t.Helper()
+
+	var msgs []message
+	var current message
+	for len(msgs) < n && s.lines.Scan() {
+		name, value, _ := strings.Cut(s.lines.Text(), ": ")
+		switch name {
+		case "id":
+			current.id = value
+		case "event":
+			current.event.Type = event.Type(value)
+		case "data":
+			if err := json.Unmarshal([]byte(value), &current.event); err != nil {
+				t.Fatalf("failed to decode event %s: %v", value, err)
+			}
+		case "":
+			if current.id != "" {
+				msgs = append(msgs, current)
+			}
+			current = message{}
+		}
+	}
+	if len(msgs) < n {
+		t.Fatalf("stream ended after %d messages, want %d: %v", len(msgs), n, s.lines.Err())
+	}
+	return msgs
+}
+
+// createFor crée une commande du client customer et retourne son ID.
+func createFor(t *testing.T, srv *httptest.Server, customer string) uint64 {
+	t.Helper()
+
+	body := strings.Replace(orderBody, "11111111-1111-1111-1111-111111111111", customer, 1)
+	res := call(t, srv, http.MethodPost, "/orders", body)
+	if res.status != http.StatusCreated {
+		t.Fatalf("POST /orders = %d %s, want 201", res.status, res.body)
+	}
+	var o struct {
+		OrderID uint64 `json:"order_id"`
+	}
+	res.decode(t, &o)
+	return o.OrderID
+}
+
+// types retourne le type et la commande de chaque message, par exemple "OrderCreated/1".
+func types(msgs []message) []string {
+	list := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		list = append(list, fmt.Sprintf("%s/%d", m.event.Type, m.event.OrderID))
+	}
+	return list
+}
+
+func TestEventsResume(t *testing.T) {
+	srv := eventsServer(t, 0)
+
+	// Sans Last-Event-ID, le flux commence après le dernier événement publié.
+	createFor(t, srv, "11111111-1111-1111-1111-111111111111")
+	live := openStream(t, srv, "", "")
+	second := createFor(t, srv, "11111111-1111-1111-1111-111111111111")
+	if res := call(t, srv, http.MethodPut, fmt.Sprintf("/orders/%d", second), `{"status": "paid"}`); res.status != http.StatusOK {
+		t.Fatalf("PUT = %d %s, want 200", res.status, res.body)
+	}
+	msgs := live.next(t, 2)
+	want := fmt.Sprintf("[OrderCreated/%d OrderStatusChanged/%d]", second, second)
+	if got := fmt.Sprint(types(msgs)); got != want {
+		t.Fatalf("live events = %s, want %s", got, want)
+	}
+	live.close()
+
+	// Reconnecté avec l'ID du premier message reçu, le client reçoit les suivants, sans le premier.
+	resumed := openStream(t, srv, "", msgs[0].id)
+	got := resumed.next(t, 1)
+	if got[0].id != msgs[1].id || got[0].event.Type != event.OrderStatusChanged {
+		t.Errorf("resumed event = %s %s, want %s %s", got[0].id, got[0].event.Type, msgs[1].id, event.OrderStatusChanged)
+	}
+
+	// Depuis le début du stream, tous les événements sont reçus dans l'ordre de publication.
+	all := openStream(t, srv, "", "0-0")
+	want = fmt.Sprintf("[OrderCreated/1 OrderCreated/%d OrderStatusChanged/%d]", second, second)
+	if got := fmt.Sprint(types(all.next(t, 3))); got != want {
+		t.Errorf("events from the start = %s, want %s", got, want)
+	}
+}
+
+func TestEventsFilters(t *testing.T) {
+	srv := eventsServer(t, 0)
+
+	const other = "99999999-9999-9999-9999-999999999999"
+	first := createFor(t, srv, "11111111-1111-1111-1111-111111111111")
+	second := createFor(t, srv, other)
+	for _, id := range []uint64{first, second} {
+		if res := call(t, srv, http.MethodPut, fmt.Sprintf("/orders/%d", id), `{"status": "paid"}`); res.status != http.StatusOK {
+			t.Fatalf("PUT = %d %s, want 200", res.status, res.body)
+		}
+	}
+	// Un dernier événement, que tous les flux reçoivent, marque la fin des événements attendus.
+	last := createFor(t, srv, other)
+	if res := call(t, srv, http.MethodPut, fmt.Sprintf("/orders/%d", last), `{"status": "paid"}`); res.status != http.StatusOK {
+		t.Fatalf("PUT = %d %s, want 200", res.status, res.body)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"customer", "?customer_id=" + other, []string{
+			fmt.Sprintf("OrderCreated/%d", second), fmt.Sprintf("OrderStatusChanged/%d", second),
+			fmt.Sprintf("OrderCreated/%d", last), fmt.Sprintf("OrderStatusChanged/%d", last),
+		}},
+		{"status", "?status=paid", []string{
+			fmt.Sprintf("OrderStatusChanged/%d", first), fmt.Sprintf("OrderStatusChanged/%d", second),
+			fmt.Sprintf("OrderStatusChanged/%d", last),
+		}},
+		{"customer and status", "?status=paid&customer_id=" + other, []string{
+			fmt.Sprintf("OrderStatusChanged/%d", second), fmt.Sprintf("OrderStatusChanged/%d", last),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openStream(t, srv, tt.query, "0-0")
+			if got := types(s.next(t, len(tt.want))); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventsInvalid(t *testing.T) {
+	srv := eventsServer(t, 0)
+
+	call(t, srv, http.MethodGet, "/orders/events?customer_id=abc", "").
+		expectProblem(t, http.StatusBadRequest, CodeInvalidParameter)
+	call(t, srv, http.MethodGet, "/orders/events?status=lost", "").
+		expectProblem(t, http.StatusBadRequest, CodeInvalidParameter)
+	call(t, srv, http.MethodGet, "/orders/events", "", "Last-Event-ID", "yesterday").
+		expectProblem(t, http.StatusBadRequest, CodeInvalidParameter)
+}
+
+func TestEventsMaxStreams(t *testing.T) {
+	srv := eventsServer(t, 1)
+
+	s := openStream(t, srv, "", "")
+	res := call(t, srv, http.MethodGet, "/orders/events", "")
+	res.expectProblem(t, http.StatusServiceUnavailable, CodeTooManyStreams)
+	if res.header.Get("Retry-After") == "" {
+		t.Error("Retry-After is missing")
+	}
+
+	// Un flux fermé libère sa place, au plus tard à la fin de son attente en cours.
+	s.close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		again := openStreamStatus(t, srv)
+		if again == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /orders/events = %d after closing the open stream, want 200", again)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// openStreamStatus ouvre le flux des événements, le referme aussitôt et retourne le statut de la réponse.
+func openStreamStatus(t *testing.T, srv *httptest.Server) int {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
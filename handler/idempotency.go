@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SamMebarek/orders-api/repository/idempotency"
+)
+
+// Idempotency rejoue la réponse d'origine lorsqu'une requête est renvoyée avec le même en-tête Idempotency-Key.
+type Idempotency struct {
+	Store   idempotency.Store // Stockage des réponses enregistrées.
+	TTL     time.Duration     // Durée de conservation des réponses.
+	LockTTL time.Duration     // Durée maximale de réservation d'une clé pendant le traitement de la requête.
+}
+
+// replayedHeaders liste les en-têtes de réponse enregistrés et rejoués.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Middleware applique l'idempotence aux requêtes portant un en-tête Idempotency-Key.
+// Une même clé avec un contenu différent renvoie une erreur 422 (Unprocessable Entity),
+// et une clé dont la requête d'origine est encore en cours renvoie une erreur 409 (Conflict).
+func (h *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Sans clé, la requête est traitée normalement.
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Lecture du corps pour calculer l'empreinte de la requête, puis restauration pour le gestionnaire.
+		// Un corps de plus de MaxBodySize octets n'est pas lu en entier et renvoie une erreur 413.
+		body, err := readBody(w, r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+		hash.Write(body)
+		sum := hex.EncodeToString(hash.Sum(nil))
+
+		// Réservation de la clé, ou récupération de la réponse d'origine.
+		stored, err := h.Store.Begin(r.Context(), key, sum, h.LockTTL)
+		if err != nil {
+			writeError(w, r, fmt.Errorf("failed to begin idempotent request: %w", err))
+			return
+		}
+
+		// Rejoue la réponse d'origine.
+		if stored != nil {
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		// Traite la requête en enregistrant sa réponse.
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// La réponse est enregistrée même si le client s'est déconnecté entre-temps.
+		ctx := context.WithoutCancel(r.Context())
+
+		// Une erreur serveur n'est pas enregistrée : la clé est libérée pour permettre une nouvelle tentative.
+		if rec.status >= http.StatusInternalServerError {
+			if err := h.Store.Release(ctx, key); err != nil {
+				fmt.Println("failed to release idempotency key:", err)
+			}
+			return
+		}
+
+		res := idempotency.Response{
+			Status: rec.status,
+			Header: http.Header{},
+			Body:   rec.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				res.Header.Set(name, value)
+			}
+		}
+
+		if err := h.Store.Complete(ctx, key, sum, res, h.TTL); err != nil {
+			fmt.Println("failed to store idempotent response:", err)
+		}
+	})
+}
+
+// responseRecorder transmet la réponse au client tout en conservant son statut et son corps.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader conserve le statut avant de le transmettre.
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write conserve le corps avant de le transmettre.
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SamMebarek/orders-api/repository/idempotency"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyStore ouvre un stockage vide d'une implémentation de idempotency.Store.
+type idempotencyStore struct {
+	name string
+	open func(t *testing.T) idempotency.Store
+}
+
+// idempotencyStores retourne les implémentations de idempotency.Store à tester : mémoire et Redis (miniredis).
+func idempotencyStores() []idempotencyStore {
+	return []idempotencyStore{
+		{"memory", func(t *testing.T) idempotency.Store {
+			return &idempotency.MemoryStore{}
+		}},
+		{"redis", func(t *testing.T) idempotency.Store {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			return &idempotency.RedisStore{Client: client}
+		}},
+	}
+}
+
+// idempotent retourne le middleware d'idempotence sur store, appliqué à next.
+func idempotent(store idempotency.Store, next http.HandlerFunc) http.Handler {
+	h := &Idempotency{Store: store, TTL: time.Hour, LockTTL: time.Minute}
+	return h.Middleware(next)
+}
+
+// post envoie une requête POST /orders de corps body au gestionnaire h, avec la clé d'idempotence key.
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// expectRecorded vérifie que la réponse enregistrée par rec est une erreur RFC 7807 de statut status et de code code.
+func expectRecorded(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	response{status: rec.Code, header: rec.Header(), body: rec.Body.Bytes()}.expectProblem(t, status, code)
+}
+
+// TestIdempotency vérifie le middleware sur chaque implémentation de idempotency.Store.
+func TestIdempotency(t *testing.T) {
+	for _, s := range idempotencyStores() {
+		t.Run(s.name, func(t *testing.T) {
+			t.Run("replay", func(t *testing.T) { testIdempotencyReplay(t, s.open(t)) })
+			t.Run("mismatch", func(t *testing.T) { testIdempotencyMismatch(t, s.open(t)) })
+			t.Run("concurrent", func(t *testing.T) { testIdempotencyConcurrent(t, s.open(t)) })
+			t.Run("release on failure", func(t *testing.T) { testIdempotencyRelease(t, s.open(t)) })
+		})
+	}
+}
+
+func testIdempotencyReplay(t *testing.T, store idempotency.Store) {
+	var calls atomic.Int32
+	h := idempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Not-Replayed", "true")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"order_id": %d}`, n)
+	})
+
+	first := post(h, "key-1", `{"a": 1}`)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first response = %d, replayed %q", first.Code, first.Header().Get("Idempotent-Replayed"))
+	}
+
+	// La même requête rejoue la réponse d'origine sans appeler le gestionnaire.
+	replay := post(h, "key-1", `{"a": 1}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replayed response = %d %s, want %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if got := replay.Header().Get("Idempotent-Replayed"); got != "true" {
+		t.Errorf("Idempotent-Replayed = %q, want true", got)
+	}
+	if got := replay.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("replayed ETag = %s, want \"1\"", got)
+	}
+	if got := replay.Header().Get("X-Not-Replayed"); got != "" {
+		t.Errorf("replayed X-Not-Replayed = %q, want it absent", got)
+	}
+
+	// Une autre clé, ou l'absence de clé, traite la requête.
+	post(h, "key-2", `{"a": 1}`)
+	post(h, "", `{"a": 1}`)
+	post(h, "", `{"a": 1}`)
+	if n := calls.Load(); n != 4 {
+		t.Errorf("handler called %d times, want 4", n)
+	}
+}
+
+func testIdempotencyMismatch(t *testing.T, store idempotency.Store) {
+	var calls atomic.Int32
+	h := idempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	post(h, "key", `{"a": 1}`)
+	expectRecorded(t, post(h, "key", `{"a": 2}`), http.StatusUnprocessableEntity, CodeIdempotencyMismatch)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
+
+func testIdempotencyConcurrent(t *testing.T, store idempotency.Store) {
+	var calls atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := idempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	// La première requête est en cours de traitement pendant que la seconde arrive.
+	var wg sync.WaitGroup
+	var first *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = post(h, "key", `{"a": 1}`)
+	}()
+	<-entered
+
+	expectRecorded(t, post(h, "key", `{"a": 1}`), http.StatusConflict, CodeRequestInProgress)
+
+	close(release)
+	wg.Wait()
+	if first.Code != http.StatusCreated {
+		t.Errorf("first response = %d, want 201", first.Code)
+	}
+
+	// Une fois la première terminée, sa réponse est rejouée.
+	if replay := post(h, "key", `{"a": 1}`); replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("response after completion = %d, replayed %q", replay.Code, replay.Header().Get("Idempotent-Replayed"))
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
+
+func testIdempotencyRelease(t *testing.T, store idempotency.Store) {
+	var calls atomic.Int32
+	h := idempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	// Une erreur serveur n'est pas enregistrée : la même requête est traitée de nouveau.
+	if res := post(h, "key", `{"a": 1}`); res.Code != http.StatusInternalServerError {
+		t.Fatalf("first response = %d, want 500", res.Code)
+	}
+	if res := post(h, "key", `{"a": 1}`); res.Code != http.StatusCreated || res.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry = %d, replayed %q, want 201 not replayed", res.Code, res.Header().Get("Idempotent-Replayed"))
+	}
+
+	// Une erreur du client est une réponse comme une autre : elle est rejouée.
+	h = idempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeError(w, r, newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "invalid"))
+	})
+	post(h, "other", `{}`)
+	if res := post(h, "other", `{}`); res.Code != http.StatusUnprocessableEntity || res.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replayed client error = %d, replayed %q", res.Code, res.Header().Get("Idempotent-Replayed"))
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("handler called %d times, want 3", n)
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	var calls atomic.Int32
+	h := idempotent(&idempotency.MemoryStore{}, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	// Un corps trop grand est refusé avant d'être haché, sans réserver la clé.
+	large := `{"notes": "` + strings.Repeat("a", MaxBodySize) + `"}`
+	expectRecorded(t, post(h, "key", large), http.StatusRequestEntityTooLarge, CodeBodyTooLarge)
+	if rec := post(h, "key", `{"a": 1}`); rec.Code != http.StatusCreated {
+		t.Errorf("request after a refused body = %d, want 201", rec.Code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
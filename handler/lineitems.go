@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// AddLineItem est une méthode HTTP qui ajoute un article à une commande en attente.
+func (h *Order) AddLineItem(w http.ResponseWriter, r *http.Request) {
+	// Décodage de l'article du corps de la requête. Si échec, renvoie une erreur 400 (Bad Request).
+	var item model.LineItem
+	if err := decodeJSON(r, &item); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.editLineItems(w, r, func(o *model.Order) error {
+		return o.AddLineItem(item)
+	})
+}
+
+// UpdateLineItem est une méthode HTTP qui modifie la quantité d'un article d'une commande en attente.
+func (h *Order) UpdateLineItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := itemIDParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Structure pour décoder le corps de la requête JSON.
+	var body struct {
+		Quantity uint `json:"quantity"` // Nouvelle quantité de l'article.
+	}
+
+	// Décodage du corps de la requête. Si échec, renvoie une erreur 400 (Bad Request).
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.editLineItems(w, r, func(o *model.Order) error {
+		return o.SetQuantity(itemID, body.Quantity)
+	})
+}
+
+// RemoveLineItem est une méthode HTTP qui retire un article d'une commande en attente.
+// Une commande doit garder au moins un article : pour l'abandonner, elle doit être annulée.
+func (h *Order) RemoveLineItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := itemIDParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.editLineItems(w, r, func(o *model.Order) error {
+		return o.RemoveLineItem(itemID)
+	})
+}
+
+// editLineItems applique la modification edit aux articles de la commande désignée par l'URL,
+// puis la valide et recalcule son prix avant de l'enregistrer, comme UpdateByID, avec sa nouvelle version.
+// Une commande expédiée renvoie une erreur 400 (Bad Request) ; une commande modifiée invalide,
+// par exemple sans article, une erreur 422 (Unprocessable Entity).
+func (h *Order) editLineItems(w http.ResponseWriter, r *http.Request, edit func(o *model.Order) error) {
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		if err := edit(o); err != nil {
+			return err
+		}
+		if err := h.Rules.Order(*o); err != nil {
+			return err
+		}
+		return h.Pricing.Apply(o)
+	})
+}
+
+// itemIDParam retourne l'ID de l'article désigné par l'URL, ou une erreur 400 (Bad Request) s'il est invalide.
+func itemIDParam(r *http.Request) (uuid.UUID, error) {
+	itemID, err := uuid.Parse(chi.URLParam(r, "itemID"))
+	if err != nil {
+		return uuid.Nil, invalidParameter("item_id", "item id must be a UUID")
+	}
+	return itemID, nil
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/pricing"
+)
+
+func TestEditLineItemsReprices(t *testing.T) {
+	// Livraison de 5,00 €, offerte à partir de 30,00 € d'achats.
+	srv := testServerWithPricing(t, pricing.Rules{
+		Shipping: map[model.Currency]pricing.ShippingRule{"EUR": {Fee: 500, FreeFrom: 3000}},
+	})
+	o := create(t, srv)
+	if o.Total.Amount != 2500 {
+		t.Fatalf("created order total = %v, want 2500 EUR", o.Total)
+	}
+	path := fmt.Sprintf("/orders/%d/line_items", o.OrderID)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		subtotal int64
+		total    int64
+	}{
+		// Trois articles atteignent le seuil de la livraison offerte.
+		{"add", http.MethodPost, path,
+			`{"item_id": "33333333-3333-3333-3333-333333333333", "quantity": 1, "price": {"amount": 1000, "currency": "EUR"}}`,
+			3000, 3000},
+		{"update quantity", http.MethodPatch, path + "/" + orderItem, `{"quantity": 1}`, 2000, 2500},
+		{"remove", http.MethodDelete, path + "/33333333-3333-3333-3333-333333333333", "", 1000, 1500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := call(t, srv, tt.method, tt.path, tt.body)
+			if res.status != http.StatusOK {
+				t.Fatalf("%s %s = %d %s, want 200", tt.method, tt.path, res.status, res.body)
+			}
+			var edited model.Order
+			res.decode(t, &edited)
+			if edited.Subtotal.Amount != tt.subtotal || edited.Total.Amount != tt.total {
+				t.Errorf("totals = %v, %v, want %d and %d", edited.Subtotal, edited.Total, tt.subtotal, tt.total)
+			}
+			if edited.Version != o.Version+1 {
+				t.Errorf("version = %d, want %d", edited.Version, o.Version+1)
+			}
+			o = edited
+		})
+	}
+}
+
+func TestEditLineItemsInvalid(t *testing.T) {
+	srv := testServer(t)
+	o := create(t, srv)
+	path := fmt.Sprintf("/orders/%d/line_items", o.OrderID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"duplicate item", http.MethodPost, path,
+			`{"item_id": "` + orderItem + `", "quantity": 1, "price": {"amount": 1000, "currency": "EUR"}}`,
+			http.StatusConflict, CodeDuplicateItem},
+		{"other currency", http.MethodPost, path,
+			`{"item_id": "33333333-3333-3333-3333-333333333333", "quantity": 1, "price": {"amount": 1000, "currency": "USD"}}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"zero quantity", http.MethodPatch, path + "/" + orderItem, `{"quantity": 0}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"unknown item", http.MethodPatch, path + "/33333333-3333-3333-3333-333333333333", `{"quantity": 1}`,
+			http.StatusNotFound, CodeLineItemNotFound},
+		{"invalid item id", http.MethodDelete, path + "/abc", "", http.StatusBadRequest, CodeInvalidParameter},
+		// Une commande garde au moins un article.
+		{"last item", http.MethodDelete, path + "/" + orderItem, "", http.StatusUnprocessableEntity, CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call(t, srv, tt.method, tt.path, tt.body).expectProblem(t, tt.status, tt.code)
+		})
+	}
+}
+
+func TestEditLineItemsAfterShipping(t *testing.T) {
+	srv := testServer(t)
+	o := create(t, srv)
+	path := fmt.Sprintf("/orders/%d/line_items", o.OrderID)
+	add := `{"item_id": "33333333-3333-3333-3333-333333333333", "quantity": 1, "price": {"amount": 1000, "currency": "EUR"}}`
+
+	// Une commande payée n'est plus modifiable.
+	if res := call(t, srv, http.MethodPut, fmt.Sprintf("/orders/%d", o.OrderID), `{"status": "paid"}`); res.status != http.StatusOK {
+		t.Fatalf("PUT paid = %d %s, want 200", res.status, res.body)
+	}
+	call(t, srv, http.MethodPost, path, add).expectProblem(t, http.StatusBadRequest, CodeNotEditable)
+
+	// Dès le premier colis, l'erreur indique que la commande est expédiée.
+	if res := ship(t, srv, o, orderItem, 1); res.status != http.StatusOK {
+		t.Fatalf("POST shipments = %d %s, want 200", res.status, res.body)
+	}
+	call(t, srv, http.MethodPost, path, add).expectProblem(t, http.StatusBadRequest, CodeAlreadyShipped)
+	call(t, srv, http.MethodPatch, path+"/"+orderItem, `{"quantity": 1}`).
+		expectProblem(t, http.StatusBadRequest, CodeAlreadyShipped)
+	call(t, srv, http.MethodDelete, path+"/"+orderItem, "").expectProblem(t, http.StatusBadRequest, CodeAlreadyShipped)
+
+	var found model.Order
+	call(t, srv, http.MethodGet, fmt.Sprintf("/orders/%d", o.OrderID), "").decode(t, &found)
+	if len(found.LineItems) != 1 || found.LineItems[0].Quantity != 2 {
+		t.Errorf("line items = %+v, want the 2 ordered items unchanged", found.LineItems)
+	}
+}
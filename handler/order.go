@@ -4,32 +4,52 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/SamMebarek/orders-api/audit"
+	"github.com/SamMebarek/orders-api/idgen"
 	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/pricing"
 	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/SamMebarek/orders-api/validation"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+// Order regroupe les gestionnaires HTTP des commandes.
 type Order struct {
-	Repo *order.RedisRepo // Référence à un dépôt Redis pour les opérations sur les commandes.
+	Repo         order.Repository // Dépôt utilisé pour les opérations sur les commandes.
+	IDs          idgen.Generator  // Générateur des IDs de nouvelles commandes.
+	CursorSecret []byte           // Clé de signature des cursors de pagination.
+	Rules        validation.Rules // Règles de validation des commandes reçues.
+	Pricing      pricing.Rules    // Règles de prix : taxes, remises et frais de livraison.
+	AdminToken   string           // Jeton des administrateurs, vide pour désactiver les opérations d'administration.
+	Done         <-chan struct{}  // Fermé à l'arrêt du serveur, pour terminer les flux d'événements.
+
+	MaxEventStreams int          // Nombre maximal de flux d'événements ouverts en même temps, 0 pour ne pas limiter.
+	eventStreams    atomic.Int64 // Nombre de flux d'événements ouverts.
 }
 
 // Create est une méthode HTTP pour créer une nouvelle commande.
 func (h *Order) Create(w http.ResponseWriter, r *http.Request) {
 	// Définition d'un struct pour décoder le corps de la requête JSON.
 	var body struct {
-		CustomerID uuid.UUID        `json:"customer_id"` // ID du client pour la commande.
-		LineItems  []model.LineItem `json:"line_items"`  // Articles de la commande.
+		CustomerID   uuid.UUID        `json:"customer_id"`   // ID du client pour la commande.
+		LineItems    []model.LineItem `json:"line_items"`    // Articles de la commande.
+		DiscountCode string           `json:"discount_code"` // Code promotionnel optionnel.
+		Notes        string           `json:"notes"`         // Notes libres optionnelles.
+
+		ShippingAddress *model.Address `json:"shipping_address"` // Adresse de livraison.
+		BillingAddress  *model.Address `json:"billing_address"`  // Adresse de facturation optionnelle.
 	}
 
 	// Décodage du corps de la requête JSON. Si cela échoue, renvoie une erreur 400 (Bad Request).
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
@@ -37,76 +57,176 @@ func (h *Order) Create(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC()
 
 	// Création d'une nouvelle commande avec les données fournies.
-	order := model.Order{
-		OrderID:    rand.Uint64(),   // ID de commande généré aléatoirement.
-		CustomerID: body.CustomerID, // ID du client issu du corps de la requête.
-		LineItems:  body.LineItems,  // Articles de la commande issus du corps de la requête.
-		CreatedAt:  &now,            // Date de création fixée à l'heure actuelle.
+	o := model.Order{
+		Version:      1,                             // Première version de la commande.
+		Status:       model.StatusPending,           // Toute commande commence en attente.
+		CustomerID:   body.CustomerID,               // ID du client issu du corps de la requête.
+		LineItems:    body.LineItems,                // Articles de la commande issus du corps de la requête.
+		DiscountCode: body.DiscountCode,             // Code promotionnel issu du corps de la requête.
+		Notes:        strings.TrimSpace(body.Notes), // Notes issues du corps de la requête.
+
+		ShippingAddress: normalizeAddress(body.ShippingAddress), // Adresse de livraison issue du corps de la requête.
+		BillingAddress:  normalizeAddress(body.BillingAddress),  // Adresse de facturation issue du corps de la requête.
+		CreatedAt:       &now,                                   // Date de création fixée à l'heure actuelle.
+	}
+
+	// Validation de la commande. Toutes les erreurs sont renvoyées avec le statut 422 (Unprocessable Entity).
+	if err := h.Rules.Order(o); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Calcul des remises, taxes et frais de livraison, puis des totaux de la commande.
+	// Un code promotionnel inconnu ou inapplicable est renvoyé avec le statut 422 (Unprocessable Entity).
+	if err := h.Pricing.Apply(&o); err != nil {
+		writeError(w, r, err)
+		return
 	}
 
-	// Insertion de la commande dans Redis.
-	err := h.Repo.Insert(r.Context(), order)
+	// Génération de l'ID de la nouvelle commande, unique et croissant dans le temps.
+	orderID, err := h.IDs.NextID(r.Context())
 	if err != nil {
-		fmt.Println("failed to insert:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("failed to generate id: %w", err))
+		return
+	}
+	o.OrderID = orderID
+
+	// Insertion de la commande dans le dépôt. Si l'ID est déjà utilisé, renvoie une erreur 409 (Conflict).
+	if err := h.Repo.Insert(r.Context(), o); err != nil {
+		if errors.Is(err, order.ErrAlreadyExists) {
+			fmt.Println("failed to insert: order id collision:", o.OrderID)
+		}
+		writeError(w, r, err)
 		return
 	}
 
 	// Sérialisation de la commande en JSON pour la réponse.
-	res, err := json.Marshal(order)
+	res, err := json.Marshal(o)
 	if err != nil {
-		fmt.Println("failed to marshal:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("failed to marshal: %w", err))
 		return
 	}
 
-	// Envoi de la réponse avec le statut 201 (Created) et les données de la commande.
+	// Envoi de la réponse avec le statut 201 (Created), l'ETag et les données de la commande.
+	w.Header().Set("ETag", etag(o))
 	w.WriteHeader(http.StatusCreated)
 	w.Write(res)
 }
 
+// normalizeAddress retourne l'adresse a normalisée, ou nil si elle est absente.
+func normalizeAddress(a *model.Address) *model.Address {
+	if a == nil {
+		return nil
+	}
+	normalized := a.Normalize()
+	return &normalized
+}
+
 // List est une méthode HTTP pour lister les commandes.
 func (h *Order) List(w http.ResponseWriter, r *http.Request) {
-	// Récupération du paramètre 'cursor' de l'URL, utilisé pour la pagination.
-	cursorStr := r.URL.Query().Get("cursor")
-	if cursorStr == "" {
-		cursorStr = "0"
+	// Récupération du paramètre 'limit', nombre de commandes par page, plafonné par le serveur.
+	// S'il est invalide ou nul, renvoie une erreur 400 (Bad Request).
+	const defaultLimit = 20
+	const maxLimit = 100
+	limit := uint64(defaultLimit)
+	var err error
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		const decimal = 10
+		const bitSize = 64
+		limit, err = strconv.ParseUint(limitStr, decimal, bitSize)
+		if err != nil || limit == 0 {
+			writeError(w, r, invalidParameter("limit", "limit must be a positive integer"))
+			return
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
 	}
 
-	// Conversion du 'cursor' en type uint64. Si échec, renvoie une erreur 400 (Bad Request).
-	const decimal = 10
-	const bitSize = 64
-	cursor, err := strconv.ParseUint(cursorStr, decimal, bitSize)
+	// Récupération du filtre optionnel 'customer_id'. Si invalide, renvoie une erreur 400 (Bad Request).
+	var customerID uuid.UUID
+	if customerIDStr := r.URL.Query().Get("customer_id"); customerIDStr != "" {
+		customerID, err = uuid.Parse(customerIDStr)
+		if err != nil {
+			writeError(w, r, invalidParameter("customer_id", "customer_id must be a UUID"))
+			return
+		}
+	}
+
+	// Récupération du filtre optionnel 'status'. Si inconnu, renvoie une erreur 400 (Bad Request).
+	status := model.Status(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		writeError(w, r, invalidParameter("status", fmt.Sprintf("unknown status %q", status)))
+		return
+	}
+
+	// Récupération des bornes optionnelles de date de création, au format RFC 3339.
+	// Si l'une d'elles est invalide, renvoie une erreur 400 (Bad Request).
+	var createdAfter, createdBefore time.Time
+	if createdAfterStr := r.URL.Query().Get("created_after"); createdAfterStr != "" {
+		createdAfter, err = time.Parse(time.RFC3339, createdAfterStr)
+		if err != nil {
+			writeError(w, r, invalidParameter("created_after", "created_after must be an RFC 3339 date"))
+			return
+		}
+	}
+	if createdBeforeStr := r.URL.Query().Get("created_before"); createdBeforeStr != "" {
+		createdBefore, err = time.Parse(time.RFC3339, createdBeforeStr)
+		if err != nil {
+			writeError(w, r, invalidParameter("created_before", "created_before must be an RFC 3339 date"))
+			return
+		}
+	}
+
+	// Récupération de l'option 'include_deleted', réservée aux administrateurs.
+	includeDeleted, err := h.includeDeleted(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	// Définition de la taille de la page pour la liste des commandes.
-	const size = 50
-	res, err := h.Repo.FindAll(r.Context(), order.FindAllPage{
-		Offset: cursor, // Point de départ pour la pagination.
-		Size:   size,   // Nombre de commandes à retourner.
-	})
+	page := order.FindAllPage{
+		Size:           limit,          // Nombre de commandes à retourner.
+		CustomerID:     customerID,     // Filtre sur le client, uuid.Nil pour toutes les commandes.
+		Status:         status,         // Filtre sur le statut, vide pour tous les statuts.
+		CreatedAfter:   createdAfter,   // Date de création minimale incluse, zéro pour ne pas filtrer.
+		CreatedBefore:  createdBefore,  // Date de création maximale exclue, zéro pour ne pas filtrer.
+		IncludeDeleted: includeDeleted, // Inclut les commandes supprimées.
+	}
+
+	// Récupération du paramètre 'cursor' de l'URL, jeton opaque utilisé pour la pagination.
+	// S'il est mal formé, a été modifié ou a été obtenu avec d'autres filtres, renvoie une erreur 400 (Bad Request).
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		page.After, err = h.decodeCursor(cursorStr, page)
+		if err != nil {
+			writeError(w, r, invalidParameter("cursor", err.Error()))
+			return
+		}
+	}
+
+	// Recherche de la page de commandes, de la plus récente à la plus ancienne.
+	res, err := h.Repo.FindAll(r.Context(), page)
 	if err != nil {
-		fmt.Println("failed to find all:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("failed to find all: %w", err))
 		return
 	}
 
-	// Préparation de la réponse contenant les commandes et le prochain 'cursor'.
+	// Préparation de la réponse contenant les commandes, le prochain 'cursor' et le nombre total de commandes.
 	var response struct {
 		Items []model.Order `json:"items"`          // Liste des commandes.
-		Next  uint64        `json:"next,omitempty"` // Cursor pour la pagination.
+		Next  string        `json:"next,omitempty"` // Cursor de la page suivante, absent sur la dernière page.
+		Total uint64        `json:"total"`          // Nombre total de commandes satisfaisant les filtres.
 	}
 	response.Items = res.Orders
-	response.Next = res.Cursor
+	response.Total = res.Total
+	if res.Next != nil {
+		response.Next = h.encodeCursor(*res.Next, page)
+	}
 
 	// Sérialisation et envoi de la réponse.
 	data, err := json.Marshal(response)
 	if err != nil {
-		fmt.Println("failed to marshal:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("failed to marshal: %w", err))
 		return
 	}
 
@@ -123,22 +243,26 @@ func (h *Order) GetByID(w http.ResponseWriter, r *http.Request) {
 	const bitSize = 64
 	orderID, err := strconv.ParseUint(idParam, base, bitSize)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, invalidParameter("id", "order id must be an unsigned integer"))
 		return
 	}
 
-	// Recherche de la commande par son ID dans Redis.
-	o, err := h.Repo.FindByID(r.Context(), orderID)
-	if errors.Is(err, order.ErrNotExist) {
-		w.WriteHeader(http.StatusNotFound)
+	// Récupération de l'option 'include_deleted', réservée aux administrateurs.
+	includeDeleted, err := h.includeDeleted(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
-	} else if err != nil {
-		fmt.Println("failed to find by id:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	// Recherche de la commande par son ID dans Redis.
+	o, err := h.Repo.FindByID(r.Context(), orderID, order.FindOptions{IncludeDeleted: includeDeleted})
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to find by id: %w", err))
 		return
 	}
 
-	// Envoi de la commande en réponse si trouvée.
+	// Envoi de la commande et de son ETag en réponse si trouvée.
+	w.Header().Set("ETag", etag(o))
 	if err := json.NewEncoder(w).Encode(o); err != nil {
 		fmt.Println("failed to marshal:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -150,15 +274,51 @@ func (h *Order) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *Order) UpdateByID(w http.ResponseWriter, r *http.Request) {
 	// Structure pour décoder le corps de la requête JSON.
 	var body struct {
-		Status string `json:"status"` // Nouveau statut de la commande.
+		Status model.Status `json:"status"` // Nouveau statut de la commande.
 	}
 
 	// Décodage du corps de la requête. Si échec, renvoie une erreur 400 (Bad Request).
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Mise à jour du statut de la commande selon la table des transitions du modèle.
+	// Un statut inconnu ou une transition non permise, par exemple finaliser une commande non expédiée,
+	// renvoie une erreur 400 (Bad Request).
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		return o.Transition(body.Status, time.Now().UTC())
+	})
+}
+
+// CancelByID annule une commande spécifiée par son ID, avec un motif et une note facultative.
+// Seule une commande pas encore expédiée peut être annulée ; elle reste consultable et listée.
+func (h *Order) CancelByID(w http.ResponseWriter, r *http.Request) {
+	// Structure pour décoder le corps de la requête JSON.
+	var body model.Cancellation
+
+	// Décodage du corps de la requête. Si échec, renvoie une erreur 400 (Bad Request).
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	// Validation du motif et de la note. Les erreurs sont renvoyées avec le statut 422 (Unprocessable Entity).
+	if err := h.Rules.Cancellation(body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Annulation de la commande. Une commande déjà expédiée renvoie une erreur 400 (Bad Request).
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		return o.Cancel(body, time.Now().UTC())
+	})
+}
+
+// update applique une modification à la commande désignée par l'ID de l'URL puis l'enregistre.
+// La commande n'est enregistrée que si elle n'a pas changé depuis sa lecture, et renvoyée avec son nouvel ETag.
+// opts précise si une commande supprimée peut être modifiée.
+func (h *Order) update(w http.ResponseWriter, r *http.Request, opts order.FindOptions, change func(o *model.Order) error) {
 	// Extraction de l'ID de la commande à partir de l'URL.
 	idParam := chi.URLParam(r, "id")
 
@@ -167,62 +327,46 @@ func (h *Order) UpdateByID(w http.ResponseWriter, r *http.Request) {
 	const bitSize = 64
 	orderID, err := strconv.ParseUint(idParam, base, bitSize)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, invalidParameter("id", "order id must be an unsigned integer"))
 		return
 	}
 
 	// Recherche de la commande par son ID.
-	theOrder, err := h.Repo.FindByID(r.Context(), orderID)
-	if errors.Is(err, order.ErrNotExist) {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	} else if err != nil {
-		fmt.Println("failed to find by id:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	theOrder, err := h.Repo.FindByID(r.Context(), orderID, opts)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to find by id: %w", err))
 		return
 	}
 
-	// Mise à jour du statut de la commande en fonction du corps de la requête.
-	const completedStatus = "completed"
-	const shippedStatus = "shipped"
-	now := time.Now().UTC()
-
-	switch body.Status {
-	case shippedStatus:
-		// Si l'état demandé est "expédié", vérifie si la commande a déjà été expédiée.
-		if theOrder.ShippedAt != nil {
-			// Si la commande a déjà une date d'expédition, renvoie une erreur 400 (Bad Request).
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		// Sinon, met à jour la date d'expédition de la commande avec l'heure actuelle.
-		theOrder.ShippedAt = &now
-
-	case completedStatus:
-		// Si l'état demandé est "complété", vérifie si la commande est déjà complétée ou pas encore expédiée.
-		if theOrder.CompletedAt != nil || theOrder.ShippedAt == nil {
-			// Si la commande est déjà complétée ou pas encore expédiée, renvoie une erreur 400 (Bad Request).
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		// Sinon, met à jour la date de complétion de la commande avec l'heure actuelle.
-		theOrder.CompletedAt = &now
+	// Vérification de l'en-tête If-Match. Si la version ne correspond pas, renvoie une erreur 412 (Precondition Failed).
+	if !ifMatch(r, etag(theOrder)) {
+		writeError(w, r, newProblem(http.StatusPreconditionFailed, CodePreconditionFailed,
+			fmt.Sprintf("current order version is %d", theOrder.Version)))
+		return
+	}
 
-	default:
-		// Si l'état demandé n'est ni "expédié" ni "complété", renvoie une erreur 400 (Bad Request).
-		w.WriteHeader(http.StatusBadRequest)
+	// Application de la modification demandée.
+	if err := change(&theOrder); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Mise à jour de la commande dans Redis.
+	// Mise à jour de la commande dans le dépôt, à condition qu'elle n'ait pas été modifiée depuis sa lecture.
+	// En cas de modification concurrente, renvoie une erreur 412 (Precondition Failed) si le client a fourni
+	// If-Match, sinon une erreur 409 (Conflict).
 	err = h.Repo.Update(r.Context(), theOrder)
-	if err != nil {
-		fmt.Println("failed to insert:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if errors.Is(err, order.ErrVersionConflict) && r.Header.Get("If-Match") != "" {
+		writeError(w, r, newProblem(http.StatusPreconditionFailed, CodePreconditionFailed, err.Error()))
+		return
+	} else if err != nil {
+		writeError(w, r, fmt.Errorf("failed to update: %w", err))
 		return
 	}
+	// La commande a été enregistrée avec la version suivante.
+	theOrder.Version++
 
-	// Envoi de la commande mise à jour en réponse.
+	// Envoi de la commande mise à jour et de son nouvel ETag en réponse.
+	w.Header().Set("ETag", etag(theOrder))
 	if err := json.NewEncoder(w).Encode(theOrder); err != nil {
 		fmt.Println("failed to marshal:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -231,27 +375,21 @@ func (h *Order) UpdateByID(w http.ResponseWriter, r *http.Request) {
 }
 
 // DeleteByID supprime une commande spécifiée par son ID.
+// La commande est conservée avec sa date et son auteur de suppression, puis purgée après le délai de rétention.
 func (h *Order) DeleteByID(w http.ResponseWriter, r *http.Request) {
-	// Extraction de l'ID de la commande de l'URL.
-	idParam := chi.URLParam(r, "id")
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		return o.Delete(audit.Actor(r.Context()), time.Now().UTC())
+	})
+}
 
-	// Conversion de l'ID en type uint64.
-	const base = 10
-	const bitSize = 64
-	orderID, err := strconv.ParseUint(idParam, base, bitSize)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+// RestoreByID restaure une commande supprimée mais pas encore purgée. Réservé aux administrateurs.
+func (h *Order) RestoreByID(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		writeError(w, r, newProblem(http.StatusForbidden, CodeForbidden, "restoring an order requires an admin token"))
 		return
 	}
 
-	// Suppression de la commande par son ID dans Redis.
-	err = h.Repo.DeleteByID(r.Context(), orderID)
-	if errors.Is(err, order.ErrNotExist) {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	} else if err != nil {
-		fmt.Println("failed to find by id:", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	h.update(w, r, order.FindOptions{IncludeDeleted: true}, func(o *model.Order) error {
+		return o.Restore()
+	})
 }
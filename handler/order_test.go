@@ -0,0 +1,462 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/pricing"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/SamMebarek/orders-api/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// sequence génère des IDs croissants à partir de 1.
+type sequence struct {
+	last atomic.Uint64
+}
+
+// NextID retourne l'ID suivant.
+func (s *sequence) NextID(ctx context.Context) (uint64, error) {
+	return s.last.Add(1), nil
+}
+
+// testAdminToken est le jeton d'administration du serveur de test.
+const testAdminToken = "admin-secret"
+
+// admin est l'en-tête d'autorisation des administrateurs, à passer à call.
+var admin = []string{"Authorization", "Bearer " + testAdminToken}
+
+// testServer démarre un serveur des routes des commandes sur un MemoryRepo vide.
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return testServerWithPricing(t, pricing.Rules{})
+}
+
+// testServerWithPricing démarre un serveur de test comme testServer, qui applique les règles de prix rules.
+func testServerWithPricing(t *testing.T, rules pricing.Rules) *httptest.Server {
+	t.Helper()
+
+	h := &Order{
+		Repo:         &order.MemoryRepo{},
+		IDs:          &sequence{},
+		CursorSecret: []byte("test"),
+		Rules:        validation.DefaultRules(),
+		Pricing:      rules,
+		AdminToken:   testAdminToken,
+	}
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(Audit(testAdminToken))
+	router.Route("/orders", func(router chi.Router) {
+		router.Post("/", h.Create)
+		router.Get("/", h.List)
+		router.Get("/{id}", h.GetByID)
+		router.Get("/{id}/history", h.History)
+		router.Put("/{id}", h.UpdateByID)
+		router.Patch("/{id}", h.PatchByID)
+		router.Delete("/{id}", h.DeleteByID)
+		router.Post("/{id}/cancel", h.CancelByID)
+		router.Post("/{id}/restore", h.RestoreByID)
+		router.Post("/{id}/line_items", h.AddLineItem)
+		router.Patch("/{id}/line_items/{itemID}", h.UpdateLineItem)
+		router.Delete("/{id}/line_items/{itemID}", h.RemoveLineItem)
+		router.Post("/{id}/shipments", h.CreateShipment)
+		router.Get("/{id}/returns", h.ListReturns)
+		router.Post("/{id}/returns", h.CreateReturn)
+		router.Post("/{id}/returns/{returnID}/approve", h.ApproveReturn)
+		router.Post("/{id}/returns/{returnID}/reject", h.RejectReturn)
+		router.Post("/{id}/returns/{returnID}/receive", h.ReceiveReturn)
+		router.Post("/{id}/returns/{returnID}/refund", h.RefundReturn)
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// response est une réponse reçue du serveur de test.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// call envoie une requête au serveur de test. headers alterne noms et valeurs d'en-têtes.
+func call(t *testing.T, srv *httptest.Server, method, path, body string, headers ...string) response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response{status: res.StatusCode, header: res.Header, body: data}
+}
+
+// decode décode le corps JSON de la réponse dans v.
+func (r response) decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("failed to decode %s: %v", r.body, err)
+	}
+}
+
+// expectProblem vérifie que la réponse est une erreur RFC 7807 de statut status et de code code.
+func (r response) expectProblem(t *testing.T, status int, code string) {
+	t.Helper()
+
+	var p struct {
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+	}
+	r.decode(t, &p)
+	if r.status != status || p.Status != status || p.Code != code {
+		t.Errorf("response = %d %s, want %d %s", r.status, r.body, status, code)
+	}
+	if ct := r.header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %s, want application/problem+json", ct)
+	}
+}
+
+// orderBody est le corps de création d'une commande valide.
+const orderBody = `{
+	"customer_id": "11111111-1111-1111-1111-111111111111",
+	"line_items": [{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 2,
+		"price": {"amount": 1000, "currency": "EUR"}}]
+}`
+
+// create crée une commande valide et la retourne.
+func create(t *testing.T, srv *httptest.Server) model.Order {
+	t.Helper()
+
+	res := call(t, srv, http.MethodPost, "/orders", orderBody)
+	if res.status != http.StatusCreated {
+		t.Fatalf("POST /orders = %d %s, want 201", res.status, res.body)
+	}
+	var o model.Order
+	res.decode(t, &o)
+	return o
+}
+
+func TestCreate(t *testing.T) {
+	srv := testServer(t)
+
+	res := call(t, srv, http.MethodPost, "/orders", orderBody)
+	if res.status != http.StatusCreated {
+		t.Fatalf("POST /orders = %d %s, want 201", res.status, res.body)
+	}
+	var o model.Order
+	res.decode(t, &o)
+	if o.OrderID != 1 || o.Version != 1 || o.Status != model.StatusPending || o.CreatedAt == nil {
+		t.Errorf("created order = %+v", o)
+	}
+	if o.Subtotal != (model.Money{Amount: 2000, Currency: "EUR"}) || o.Total != o.Subtotal {
+		t.Errorf("created order totals = %v, %v, want 2000 EUR", o.Subtotal, o.Total)
+	}
+	if tag := res.header.Get("ETag"); tag != `"1"` {
+		t.Errorf("ETag = %s, want \"1\"", tag)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"malformed", `{"customer_id": `, http.StatusBadRequest, CodeInvalidJSON},
+		{"unknown field", `{"customer": "x"}`, http.StatusBadRequest, CodeUnknownField},
+		{"no line items", `{"customer_id": "11111111-1111-1111-1111-111111111111"}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed},
+		// Le simple entier des prix enregistrés sans devise n'est pas accepté dans une requête.
+		{"price without currency", `{"customer_id": "11111111-1111-1111-1111-111111111111", "line_items": [
+			{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 1, "price": 1000}]}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"body too large", `{"notes": "` + strings.Repeat("a", MaxBodySize) + `"}`,
+			http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call(t, srv, http.MethodPost, "/orders", tt.body).expectProblem(t, tt.status, tt.code)
+		})
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	srv := testServer(t)
+	created := create(t, srv)
+
+	res := call(t, srv, http.MethodGet, fmt.Sprintf("/orders/%d", created.OrderID), "")
+	if res.status != http.StatusOK {
+		t.Fatalf("GET = %d %s, want 200", res.status, res.body)
+	}
+	var found model.Order
+	res.decode(t, &found)
+	if found.OrderID != created.OrderID || found.Version != created.Version || found.Total != created.Total {
+		t.Errorf("GET = %+v, want %+v", found, created)
+	}
+	if tag := res.header.Get("ETag"); tag != etag(created) {
+		t.Errorf("ETag = %s, want %s", tag, etag(created))
+	}
+
+	call(t, srv, http.MethodGet, "/orders/42", "").expectProblem(t, http.StatusNotFound, CodeOrderNotFound)
+	call(t, srv, http.MethodGet, "/orders/abc", "").expectProblem(t, http.StatusBadRequest, CodeInvalidParameter)
+}
+
+func TestList(t *testing.T) {
+	srv := testServer(t)
+
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		want = append([]uint64{create(t, srv).OrderID}, want...)
+	}
+
+	// Les pages se suivent par leur cursor, de la commande la plus récente à la plus ancienne.
+	var got []uint64
+	query := url.Values{"limit": {"2"}}
+	for pages := 1; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination does not end")
+		}
+
+		res := call(t, srv, http.MethodGet, "/orders?"+query.Encode(), "")
+		if res.status != http.StatusOK {
+			t.Fatalf("GET /orders = %d %s, want 200", res.status, res.body)
+		}
+		var page struct {
+			Items []model.Order `json:"items"`
+			Next  string        `json:"next"`
+			Total uint64        `json:"total"`
+		}
+		res.decode(t, &page)
+		if page.Total != uint64(len(want)) || len(page.Items) > 2 {
+			t.Errorf("page %d has %d orders of %d, want at most 2 of %d", pages, len(page.Items), page.Total, len(want))
+		}
+		for _, o := range page.Items {
+			got = append(got, o.OrderID)
+		}
+
+		if page.Next == "" {
+			break
+		}
+		query.Set("cursor", page.Next)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orders = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid cursor", "cursor=invalid"},
+		{"tampered cursor", "cursor=" + url.QueryEscape(tamper(t, srv))},
+		{"zero limit", "limit=0"},
+		{"negative limit", "limit=-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call(t, srv, http.MethodGet, "/orders?"+tt.query, "").
+				expectProblem(t, http.StatusBadRequest, CodeInvalidParameter)
+		})
+	}
+}
+
+func TestListCursorFilters(t *testing.T) {
+	srv := testServer(t)
+	for i := 0; i < 3; i++ {
+		create(t, srv)
+	}
+
+	var page struct {
+		Next string `json:"next"`
+	}
+	call(t, srv, http.MethodGet, "/orders?limit=1&status=pending", "").decode(t, &page)
+	if page.Next == "" {
+		t.Fatal("GET /orders?limit=1&status=pending has no next cursor")
+	}
+
+	// Le cursor reste valide avec les mêmes filtres, même pour une autre taille de page.
+	if res := call(t, srv, http.MethodGet, "/orders?limit=2&status=pending&cursor="+page.Next, ""); res.status != http.StatusOK {
+		t.Errorf("GET with the same filters = %d %s, want 200", res.status, res.body)
+	}
+
+	// Avec d'autres filtres, il est refusé au lieu d'être réinterprété.
+	tests := []string{
+		"",
+		"status=paid",
+		"status=pending&customer_id=11111111-1111-1111-1111-111111111111",
+		"status=pending&created_after=2024-01-01T00:00:00Z",
+		"status=pending&created_before=2999-01-01T00:00:00Z",
+	}
+	for _, filters := range tests {
+		call(t, srv, http.MethodGet, "/orders?"+filters+"&cursor="+page.Next, "").
+			expectProblem(t, http.StatusBadRequest, CodeInvalidParameter)
+	}
+}
+
+// tamper retourne le cursor de la première page de deux commandes, dont le dernier caractère a été modifié.
+func tamper(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+
+	var page struct {
+		Next string `json:"next"`
+	}
+	call(t, srv, http.MethodGet, "/orders?limit=1", "").decode(t, &page)
+	if page.Next == "" {
+		t.Fatal("GET /orders?limit=1 has no next cursor")
+	}
+
+	last := page.Next[len(page.Next)-1]
+	if last == 'A' {
+		last = 'B'
+	} else {
+		last = 'A'
+	}
+	return page.Next[:len(page.Next)-1] + string(last)
+}
+
+func TestUpdateByID(t *testing.T) {
+	srv := testServer(t)
+	created := create(t, srv)
+	path := fmt.Sprintf("/orders/%d", created.OrderID)
+
+	// Un If-Match périmé est refusé sans modifier la commande.
+	call(t, srv, http.MethodPut, path, `{"status": "paid"}`, "If-Match", `"2"`).
+		expectProblem(t, http.StatusPreconditionFailed, CodePreconditionFailed)
+
+	res := call(t, srv, http.MethodPut, path, `{"status": "paid"}`, "If-Match", etag(created))
+	if res.status != http.StatusOK {
+		t.Fatalf("PUT = %d %s, want 200", res.status, res.body)
+	}
+	var paid model.Order
+	res.decode(t, &paid)
+	if paid.Status != model.StatusPaid || paid.Version != created.Version+1 {
+		t.Errorf("PUT = %s version %d, want paid version %d", paid.Status, paid.Version, created.Version+1)
+	}
+	if tag := res.header.Get("ETag"); tag != etag(paid) {
+		t.Errorf("ETag = %s, want %s", tag, etag(paid))
+	}
+
+	// L'ETag lu avant la modification ne correspond plus.
+	call(t, srv, http.MethodPut, path, `{"status": "delivered"}`, "If-Match", etag(created)).
+		expectProblem(t, http.StatusPreconditionFailed, CodePreconditionFailed)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown status", `{"status": "lost"}`, http.StatusBadRequest, CodeUnknownStatus},
+		{"invalid transition", `{"status": "pending"}`, http.StatusBadRequest, CodeInvalidTransition},
+		{"shipped", `{"status": "shipped"}`, http.StatusBadRequest, CodeNotRequestable},
+		{"cancelled", `{"status": "cancelled"}`, http.StatusBadRequest, CodeNotRequestable},
+		{"refunded", `{"status": "refunded"}`, http.StatusBadRequest, CodeNotRequestable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call(t, srv, http.MethodPut, path, tt.body).expectProblem(t, tt.status, tt.code)
+		})
+	}
+
+	call(t, srv, http.MethodPut, "/orders/42", `{"status": "paid"}`).
+		expectProblem(t, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestCancelByID(t *testing.T) {
+	srv := testServer(t)
+	created := create(t, srv)
+	path := fmt.Sprintf("/orders/%d/cancel", created.OrderID)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no reason", `{}`},
+		{"unknown reason", `{"reason": "bored"}`},
+		{"other without note", `{"reason": "other", "note": "  "}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call(t, srv, http.MethodPost, path, tt.body).
+				expectProblem(t, http.StatusUnprocessableEntity, CodeValidationFailed)
+		})
+	}
+
+	res := call(t, srv, http.MethodPost, path, `{"reason": "other", "note": "Adresse erronée"}`,
+		"If-Match", etag(created))
+	if res.status != http.StatusOK {
+		t.Fatalf("POST cancel = %d %s, want 200", res.status, res.body)
+	}
+	var cancelled model.Order
+	res.decode(t, &cancelled)
+	if cancelled.Status != model.StatusCancelled || cancelled.CancelledAt == nil || cancelled.Cancellation == nil ||
+		cancelled.Cancellation.Reason != model.CancelOther || cancelled.Version != created.Version+1 {
+		t.Errorf("cancelled order = %+v", cancelled)
+	}
+
+	call(t, srv, http.MethodPost, path, `{"reason": "customer_request"}`).
+		expectProblem(t, http.StatusBadRequest, CodeAlreadyCancelled)
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	srv := testServer(t)
+	created := create(t, srv)
+	path := fmt.Sprintf("/orders/%d", created.OrderID)
+
+	res := call(t, srv, http.MethodDelete, path, "", admin...)
+	if res.status != http.StatusOK {
+		t.Fatalf("DELETE = %d %s, want 200", res.status, res.body)
+	}
+	var deleted model.Order
+	res.decode(t, &deleted)
+	if deleted.DeletedAt == nil || deleted.DeletedBy != "admin" || deleted.Version != created.Version+1 {
+		t.Errorf("deleted order = %+v", deleted)
+	}
+
+	// Une commande supprimée n'est visible qu'avec include_deleted, réservé aux administrateurs.
+	call(t, srv, http.MethodGet, path, "").expectProblem(t, http.StatusNotFound, CodeOrderNotFound)
+	call(t, srv, http.MethodGet, path+"?include_deleted=true", "").expectProblem(t, http.StatusForbidden, CodeForbidden)
+	if res := call(t, srv, http.MethodGet, path+"?include_deleted=true", "", admin...); res.status != http.StatusOK {
+		t.Errorf("GET with include_deleted as admin = %d %s, want 200", res.status, res.body)
+	}
+	call(t, srv, http.MethodDelete, path, "").expectProblem(t, http.StatusNotFound, CodeOrderNotFound)
+
+	// Seul un administrateur restaure une commande.
+	call(t, srv, http.MethodPost, path+"/restore", "").expectProblem(t, http.StatusForbidden, CodeForbidden)
+	res = call(t, srv, http.MethodPost, path+"/restore", "", admin...)
+	if res.status != http.StatusOK {
+		t.Fatalf("POST restore = %d %s, want 200", res.status, res.body)
+	}
+	var restored model.Order
+	res.decode(t, &restored)
+	if restored.DeletedAt != nil || restored.DeletedBy != "" {
+		t.Errorf("restored order = %+v", restored)
+	}
+	call(t, srv, http.MethodPost, path+"/restore", "", admin...).expectProblem(t, http.StatusConflict, CodeNotDeleted)
+}
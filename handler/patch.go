@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/patch"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/SamMebarek/orders-api/validation"
+	"github.com/google/uuid"
+)
+
+// mutableFields liste les champs JSON d'une commande qu'un patch peut modifier.
+// Tout autre champ, dont order_id et created_at, est calculé par le serveur ou fixé à la création.
+var mutableFields = map[string]bool{
+	"status":           true, // Modifié selon la table des transitions, comme avec UpdateByID, sauf vers les états gérés.
+	"customer_id":      true, // Modifiable seulement tant que la commande est en attente.
+	"line_items":       true, // Modifiables seulement tant que la commande est en attente.
+	"discount_code":    true, // Idem.
+	"notes":            true,
+	"shipping_address": true, // Modifiables jusqu'à l'expédition de la commande.
+	"billing_address":  true, // Idem.
+}
+
+// PatchByID est une méthode HTTP qui modifie une commande par un JSON Merge Patch (application/merge-patch+json)
+// ou un JSON Patch (application/json-patch+json), appliqué à sa représentation JSON.
+// Seuls les champs de mutableFields peuvent changer. La commande modifiée est ensuite validée, entièrement ou
+// seulement pour les champs modifiés, son prix recalculé si ses articles ou son code promotionnel ont changé,
+// et son statut modifié selon la table des transitions, avant d'être enregistrée comme avec UpdateByID.
+func (h *Order) PatchByID(w http.ResponseWriter, r *http.Request) {
+	// Un autre type de média renvoie une erreur 415 (Unsupported Media Type), avec les types acceptés.
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType {
+		w.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		writeError(w, r, newProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			fmt.Sprintf("content type must be %s or %s", patch.MergePatchType, patch.JSONPatchType)))
+		return
+	}
+
+	data, err := readBody(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Décodage du patch avant la lecture de la commande. S'il est mal formé, renvoie une erreur 400 (Bad Request).
+	var apply func(doc any) (any, error)
+	if mediaType == patch.MergePatchType {
+		p, err := patch.Decode(data)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		apply = func(doc any) (any, error) {
+			return patch.Merge(doc, p), nil
+		}
+	} else {
+		ops, err := patch.DecodeOperations(data)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		apply = func(doc any) (any, error) {
+			return patch.Apply(doc, ops)
+		}
+	}
+
+	// Un patch qui ne s'applique pas, par exemple un test qui échoue, renvoie une erreur 409 (Conflict) ;
+	// la modification d'un champ non modifiable ou une commande modifiée invalide, une erreur 422.
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		return h.patch(o, apply)
+	})
+}
+
+// patch applique la fonction apply à la représentation JSON de la commande o, puis reporte sur o
+// les champs modifiés, en respectant les mêmes règles que les autres méthodes de modification.
+func (h *Order) patch(o *model.Order, apply func(doc any) (any, error)) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	// Le document est décodé deux fois : apply peut modifier celui qu'il reçoit.
+	before, err := patch.Decode(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode order: %w", err)
+	}
+	doc, err := patch.Decode(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode order: %w", err)
+	}
+	doc, err = apply(doc)
+	if err != nil {
+		return err
+	}
+
+	original := before.(map[string]any)
+	patched, ok := doc.(map[string]any)
+	if !ok {
+		return validation.Errors{{Field: "order", Code: validation.CodeInvalid, Message: "order must be a JSON object"}}
+	}
+
+	// Tout champ modifié hors de mutableFields est refusé.
+	changed := func(field string) bool {
+		return !patch.Equal(original[field], patched[field])
+	}
+	names := make([]string, 0, len(patched))
+	for name := range patched {
+		names = append(names, name)
+	}
+	for name := range original {
+		if _, exists := patched[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs validation.Errors
+	for _, name := range names {
+		if !mutableFields[name] && changed(name) {
+			errs = append(errs, validation.FieldError{Field: name, Code: validation.CodeImmutable,
+				Message: name + " cannot be changed"})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// Décodage des champs modifiables de la commande modifiée. Un champ inconnu, par exemple dans une adresse,
+	// renvoie une erreur 400 (Bad Request) comme à la création.
+	mutable := make(map[string]any, len(mutableFields))
+	for name, value := range patched {
+		if mutableFields[name] {
+			mutable[name] = value
+		}
+	}
+	data, err = json.Marshal(mutable)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	var fields struct {
+		Status       model.Status     `json:"status"`
+		CustomerID   uuid.UUID        `json:"customer_id"`
+		LineItems    []model.LineItem `json:"line_items"`
+		DiscountCode string           `json:"discount_code"`
+		Notes        string           `json:"notes"`
+
+		ShippingAddress *model.Address `json:"shipping_address"`
+		BillingAddress  *model.Address `json:"billing_address"`
+	}
+	if err := decodeFrom(bytes.NewReader(data), &fields); err != nil {
+		return err
+	}
+
+	// Les articles et le code promotionnel ne changent que sur une commande en attente, qui est alors réévaluée.
+	reprice := changed("line_items") || changed("discount_code")
+	if reprice {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		o.LineItems = fields.LineItems
+		o.DiscountCode = fields.DiscountCode
+	}
+	// Le client ne change lui aussi que sur une commande en attente : une commande payée lui est acquise.
+	if changed("customer_id") {
+		if err := o.Editable(); err != nil {
+			return err
+		}
+		o.CustomerID = fields.CustomerID
+	}
+	if changed("notes") {
+		o.Notes = strings.TrimSpace(fields.Notes)
+	}
+
+	// Les adresses ne changent plus une fois la commande expédiée.
+	readdress := changed("shipping_address") || changed("billing_address")
+	if readdress {
+		if err := o.AddressesEditable(); err != nil {
+			return err
+		}
+		o.ShippingAddress = normalizeAddress(fields.ShippingAddress)
+		o.BillingAddress = normalizeAddress(fields.BillingAddress)
+	}
+
+	// La commande n'est validée entièrement que si ses articles, son code promotionnel ou ses adresses changent ;
+	// sinon, seuls les champs modifiés sont vérifiés, les autres l'ont été à son enregistrement.
+	if reprice || readdress {
+		err = h.Rules.Order(*o)
+	} else {
+		var checked []string
+		for _, name := range []string{"customer_id", "notes"} {
+			if changed(name) {
+				checked = append(checked, name)
+			}
+		}
+		err = h.Rules.Fields(*o, checked...)
+	}
+	if err != nil {
+		return err
+	}
+	if reprice {
+		if err := h.Pricing.Apply(o); err != nil {
+			return err
+		}
+	}
+
+	// Le statut change en dernier, selon la table des transitions du modèle. Comme avec UpdateByID, les états
+	// atteints par une autre opération, dont cancelled et refunded, répondent ErrNotRequestable.
+	if changed("status") {
+		return o.Transition(fields.Status, time.Now().UTC())
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/patch"
+	"github.com/SamMebarek/orders-api/validation"
+)
+
+// patchOrder envoie le patch body de type contentType à la commande o.
+func patchOrder(t *testing.T, srv *httptest.Server, o model.Order, contentType, body string) response {
+	t.Helper()
+	return call(t, srv, http.MethodPatch, fmt.Sprintf("/orders/%d", o.OrderID), body, "Content-Type", contentType)
+}
+
+// get retourne la commande o telle qu'elle est enregistrée.
+func get(t *testing.T, srv *httptest.Server, o model.Order) model.Order {
+	t.Helper()
+
+	var found model.Order
+	call(t, srv, http.MethodGet, fmt.Sprintf("/orders/%d", o.OrderID), "").decode(t, &found)
+	return found
+}
+
+// fieldCodes retourne, pour chaque champ en erreur de la réponse, son code.
+func (r response) fieldCodes(t *testing.T) map[string]string {
+	t.Helper()
+
+	var p Problem
+	r.decode(t, &p)
+	codes := make(map[string]string, len(p.Errors))
+	for _, f := range p.Errors {
+		codes[f.Field] = f.Code
+	}
+	return codes
+}
+
+func TestPatchByID(t *testing.T) {
+	srv := testServer(t)
+	created := create(t, srv)
+
+	// Merge patch des notes : seule la version change avec elles.
+	res := patchOrder(t, srv, created, patch.MergePatchType, `{"notes": "  Sonner deux fois  "}`)
+	if res.status != http.StatusOK {
+		t.Fatalf("PATCH = %d %s, want 200", res.status, res.body)
+	}
+	var patched model.Order
+	res.decode(t, &patched)
+	if patched.Notes != "Sonner deux fois" || patched.Version != created.Version+1 || patched.Total != created.Total {
+		t.Errorf("patched order = %+v", patched)
+	}
+
+	// JSON Patch ajoutant un article à la fin : le prix est recalculé.
+	res = patchOrder(t, srv, patched, patch.JSONPatchType, `[
+		{"op": "test", "path": "/line_items/0/quantity", "value": 2},
+		{"op": "add", "path": "/line_items/-", "value": {"item_id": "33333333-3333-3333-3333-333333333333",
+			"quantity": 1, "price": {"amount": 500, "currency": "EUR"}}}
+	]`)
+	if res.status != http.StatusOK {
+		t.Fatalf("PATCH = %d %s, want 200", res.status, res.body)
+	}
+	res.decode(t, &patched)
+	if len(patched.LineItems) != 2 || patched.Subtotal.Amount != 2500 || patched.Total.Amount != 2500 {
+		t.Errorf("patched order = %d items, subtotal %v, total %v, want 2 items and 2500",
+			len(patched.LineItems), patched.Subtotal, patched.Total)
+	}
+	if tag := res.header.Get("ETag"); tag != etag(patched) {
+		t.Errorf("ETag = %s, want %s", tag, etag(patched))
+	}
+
+	// Un test qui échoue annule tout le patch.
+	patchOrder(t, srv, patched, patch.JSONPatchType, `[
+		{"op": "replace", "path": "/notes", "value": "changé"},
+		{"op": "test", "path": "/line_items/0/quantity", "value": 3}
+	]`).expectProblem(t, http.StatusConflict, CodePatchTestFailed)
+	if found := get(t, srv, patched); found.Notes != patched.Notes || found.Version != patched.Version {
+		t.Errorf("order after a failed test = %+v, want it unchanged", found)
+	}
+}
+
+func TestPatchByIDErrors(t *testing.T) {
+	srv := testServer(t)
+	o := create(t, srv)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"unsupported media type", "application/json", `{"notes": "x"}`, http.StatusUnsupportedMediaType, CodeUnsupportedMedia},
+		{"malformed merge patch", patch.MergePatchType, `{"notes": `, http.StatusBadRequest, CodeInvalidPatch},
+		{"json patch not an array", patch.JSONPatchType, `{"op": "add"}`, http.StatusBadRequest, CodeInvalidPatch},
+		{"unknown op", patch.JSONPatchType, `[{"op": "increment", "path": "/version"}]`, http.StatusBadRequest, CodeInvalidPatch},
+		{"missing path", patch.JSONPatchType, `[{"op": "remove", "path": "/line_items/5"}]`, http.StatusConflict, CodePatchConflict},
+		{"whole document", patch.MergePatchType, `[]`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"unknown address field", patch.MergePatchType, `{"shipping_address": {"street": "x"}}`, http.StatusBadRequest, CodeUnknownField},
+		{"requested cancel", patch.MergePatchType, `{"status": "cancelled"}`, http.StatusBadRequest, CodeNotRequestable},
+		{"no line items", patch.MergePatchType, `{"line_items": []}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"body too large", patch.MergePatchType, `{"notes": "` + strings.Repeat("a", MaxBodySize) + `"}`,
+			http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patchOrder(t, srv, o, tt.contentType, tt.body).expectProblem(t, tt.status, tt.code)
+		})
+	}
+
+	res := patchOrder(t, srv, o, patch.MergePatchType, `{"notes": "x"}`)
+	if accept := res.header.Get("Accept-Patch"); res.status != http.StatusOK || accept != "" {
+		t.Errorf("PATCH = %d, Accept-Patch %q", res.status, accept)
+	}
+	res = patchOrder(t, srv, o, "text/plain", `x`)
+	if accept := res.header.Get("Accept-Patch"); accept != patch.MergePatchType+", "+patch.JSONPatchType {
+		t.Errorf("Accept-Patch = %q", accept)
+	}
+}
+
+func TestPatchByIDImmutableFields(t *testing.T) {
+	srv := testServer(t)
+	o := create(t, srv)
+
+	// Chaque champ hors de mutableFields est signalé, sans modifier la commande.
+	res := patchOrder(t, srv, o, patch.JSONPatchType, `[
+		{"op": "replace", "path": "/order_id", "value": 42},
+		{"op": "replace", "path": "/total", "value": {"amount": 1, "currency": "EUR"}},
+		{"op": "remove", "path": "/created_at"},
+		{"op": "add", "path": "/notes", "value": "ok"}
+	]`)
+	res.expectProblem(t, http.StatusUnprocessableEntity, CodeValidationFailed)
+	want := map[string]string{
+		"order_id":   validation.CodeImmutable,
+		"total":      validation.CodeImmutable,
+		"created_at": validation.CodeImmutable,
+	}
+	if got := res.fieldCodes(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("field errors = %v, want %v", got, want)
+	}
+	if found := get(t, srv, o); found.Version != o.Version || found.Notes != "" {
+		t.Errorf("order after a refused patch = %+v, want it unchanged", found)
+	}
+
+	// Un champ immuable réécrit à l'identique n'est pas une modification.
+	res = patchOrder(t, srv, o, patch.JSONPatchType,
+		fmt.Sprintf(`[{"op": "replace", "path": "/order_id", "value": %d}]`, o.OrderID))
+	if res.status != http.StatusOK {
+		t.Errorf("PATCH with an unchanged order_id = %d %s, want 200", res.status, res.body)
+	}
+}
+
+func TestPatchByIDEditableStates(t *testing.T) {
+	srv := testServer(t)
+	const address = `{"name": "Ada", "line1": "1 rue de Rivoli", "city": "Paris", "postal_code": "75001", "country": "FR"}`
+
+	// Une commande payée garde ses articles et son client, mais ses notes et adresses restent modifiables.
+	paid := create(t, srv)
+	res := call(t, srv, http.MethodPut, fmt.Sprintf("/orders/%d", paid.OrderID), `{"status": "paid"}`)
+	if res.status != http.StatusOK {
+		t.Fatalf("PUT paid = %d %s, want 200", res.status, res.body)
+	}
+	for _, body := range []string{
+		`{"line_items": [{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 1, "price": {"amount": 1000, "currency": "EUR"}}]}`,
+		`{"discount_code": "WELCOME"}`,
+		`{"customer_id": "99999999-9999-9999-9999-999999999999"}`,
+	} {
+		patchOrder(t, srv, paid, patch.MergePatchType, body).expectProblem(t, http.StatusBadRequest, CodeNotEditable)
+	}
+	for _, body := range []string{`{"notes": "Fragile"}`, `{"shipping_address": ` + address + `}`} {
+		if res := patchOrder(t, srv, paid, patch.MergePatchType, body); res.status != http.StatusOK {
+			t.Errorf("PATCH %s of a paid order = %d %s, want 200", body, res.status, res.body)
+		}
+	}
+
+	// Une commande expédiée ne change plus d'articles ni d'adresses.
+	shipped := deliver(t, srv, orderBody)
+	for _, body := range []string{
+		`{"line_items": [{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 1, "price": {"amount": 1000, "currency": "EUR"}}]}`,
+		`{"shipping_address": ` + address + `}`,
+		`{"billing_address": ` + address + `}`,
+	} {
+		patchOrder(t, srv, shipped, patch.MergePatchType, body).expectProblem(t, http.StatusBadRequest, CodeAlreadyShipped)
+	}
+}
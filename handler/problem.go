@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/patch"
+	"github.com/SamMebarek/orders-api/repository/idempotency"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/SamMebarek/orders-api/validation"
+	"github.com/SamMebarek/orders-api/webhook"
+)
+
+// Problem est une réponse d'erreur au format RFC 7807 (application/problem+json).
+// Code est un identifiant stable de l'erreur, destiné aux clients.
+type Problem struct {
+	Type     string       `json:"type"`             // URI identifiant le type d'erreur, dérivée de Code.
+	Title    string       `json:"title"`            // Résumé du type d'erreur.
+	Status   int          `json:"status"`           // Code de statut HTTP.
+	Detail   string       `json:"detail,omitempty"` // Explication propre à cette occurrence.
+	Instance string       `json:"instance"`         // Chemin de la requête concernée.
+	Code     string       `json:"code"`             // Code d'erreur stable.
+	Errors   []FieldError `json:"errors,omitempty"` // Erreurs par champ.
+}
+
+// FieldError décrit une erreur portant sur un champ du corps ou un paramètre de la requête.
+type FieldError struct {
+	Field   string `json:"field"`   // Chemin du champ, par exemple "line_items[0].quantity".
+	Code    string `json:"code"`    // Code d'erreur stable.
+	Message string `json:"message"` // Explication lisible.
+}
+
+// Error permet de retourner un Problem comme une erreur.
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// Codes d'erreur stables retournés aux clients.
+const (
+	CodeInvalidJSON         = "invalid_json"
+	CodeUnknownField        = "unknown_field"
+	CodeValidationFailed    = "validation_failed"
+	CodeInvalidParameter    = "invalid_parameter"
+	CodeOrderNotFound       = "order_not_found"
+	CodeWebhookNotFound     = "webhook_not_found"
+	CodeOrderAlreadyExists  = "order_already_exists"
+	CodeVersionConflict     = "version_conflict"
+	CodePreconditionFailed  = "precondition_failed"
+	CodeForbidden           = "forbidden"
+	CodeNotDeleted          = "not_deleted"
+	CodeUnknownStatus       = "unknown_status"
+	CodeInvalidTransition   = "invalid_transition"
+	CodeAlreadyShipped      = "already_shipped"
+	CodeAlreadyCompleted    = "already_completed"
+	CodeNotShipped          = "not_shipped"
+	CodeAlreadyCancelled    = "already_cancelled"
+	CodeNotRequestable      = "status_not_requestable"
+	CodeLineItemNotFound    = "line_item_not_found"
+	CodeDuplicateItem       = "duplicate_item"
+	CodeNotEditable         = "not_editable"
+	CodeInvalidShipment     = "invalid_shipment"
+	CodeNotReturnable       = "not_returnable"
+	CodeReturnNotFound      = "return_not_found"
+	CodeInvalidReturnState  = "invalid_return_transition"
+	CodeInvalidReturn       = "invalid_return"
+	CodeInvalidRefund       = "invalid_refund"
+	CodeRefundExceedsPaid   = "refund_exceeds_paid"
+	CodeRefundExceedsReturn = "refund_exceeds_return"
+	CodeIdempotencyMismatch = "idempotency_key_mismatch"
+	CodeRequestInProgress   = "request_in_progress"
+	CodeInvalidPatch        = "invalid_patch"
+	CodePatchConflict       = "patch_conflict"
+	CodePatchTestFailed     = "patch_test_failed"
+	CodeUnsupportedMedia    = "unsupported_media_type"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeNotSupported        = "not_supported"
+	CodeTooManyStreams      = "too_many_streams"
+	CodeInternal            = "internal_error"
+)
+
+// problemTitles associe à chaque code le résumé de son type d'erreur.
+var problemTitles = map[string]string{
+	CodeInvalidJSON:         "Malformed request body",
+	CodeUnknownField:        "Unknown request field",
+	CodeValidationFailed:    "Validation failed",
+	CodeInvalidParameter:    "Invalid request parameter",
+	CodeOrderNotFound:       "Order not found",
+	CodeWebhookNotFound:     "Webhook subscription not found",
+	CodeOrderAlreadyExists:  "Order already exists",
+	CodeVersionConflict:     "Order modified concurrently",
+	CodePreconditionFailed:  "Precondition failed",
+	CodeForbidden:           "Forbidden",
+	CodeNotDeleted:          "Order not deleted",
+	CodeUnknownStatus:       "Unknown order status",
+	CodeInvalidTransition:   "Invalid status transition",
+	CodeAlreadyShipped:      "Order already shipped",
+	CodeAlreadyCompleted:    "Order already completed",
+	CodeNotShipped:          "Order not shipped",
+	CodeAlreadyCancelled:    "Order already cancelled",
+	CodeNotRequestable:      "Status not requestable",
+	CodeLineItemNotFound:    "Line item not found",
+	CodeDuplicateItem:       "Item already in order",
+	CodeNotEditable:         "Order not editable",
+	CodeInvalidShipment:     "Invalid shipment",
+	CodeNotReturnable:       "Order not returnable",
+	CodeReturnNotFound:      "Return not found",
+	CodeInvalidReturnState:  "Invalid return status transition",
+	CodeInvalidReturn:       "Invalid return",
+	CodeInvalidRefund:       "Invalid refund",
+	CodeRefundExceedsPaid:   "Refund exceeds amount paid",
+	CodeRefundExceedsReturn: "Refund exceeds returned items",
+	CodeIdempotencyMismatch: "Idempotency key reused",
+	CodeRequestInProgress:   "Request in progress",
+	CodeInvalidPatch:        "Malformed patch document",
+	CodePatchConflict:       "Patch does not apply",
+	CodePatchTestFailed:     "Patch test failed",
+	CodeUnsupportedMedia:    "Unsupported media type",
+	CodeBodyTooLarge:        "Request body too large",
+	CodeNotSupported:        "Not supported",
+	CodeTooManyStreams:      "Too many event streams",
+	CodeInternal:            "Internal server error",
+}
+
+// newProblem crée un Problem pour un statut et un code donnés.
+func newProblem(status int, code, detail string, fields ...FieldError) *Problem {
+	return &Problem{
+		Type:   "urn:orders-api:problem:" + code,
+		Title:  problemTitles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: fields,
+	}
+}
+
+// invalidParameter crée un Problem pour un paramètre de requête invalide.
+func invalidParameter(name, detail string) *Problem {
+	return newProblem(http.StatusBadRequest, CodeInvalidParameter, detail, FieldError{
+		Field:   name,
+		Code:    CodeInvalidParameter,
+		Message: detail,
+	})
+}
+
+// domainProblems associe les erreurs du domaine et des dépôts à leur statut HTTP et leur code.
+var domainProblems = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{order.ErrNotExist, http.StatusNotFound, CodeOrderNotFound},
+	{order.ErrAlreadyExists, http.StatusConflict, CodeOrderAlreadyExists},
+	{order.ErrVersionConflict, http.StatusConflict, CodeVersionConflict},
+	{model.ErrAlreadyShipped, http.StatusBadRequest, CodeAlreadyShipped},
+	{model.ErrAlreadyCompleted, http.StatusBadRequest, CodeAlreadyCompleted},
+	{model.ErrNotShipped, http.StatusBadRequest, CodeNotShipped},
+	{model.ErrAlreadyCancelled, http.StatusBadRequest, CodeAlreadyCancelled},
+	{model.ErrInvalidTransition, http.StatusBadRequest, CodeInvalidTransition},
+	{model.ErrNotRequestable, http.StatusBadRequest, CodeNotRequestable},
+	{model.ErrUnknownStatus, http.StatusBadRequest, CodeUnknownStatus},
+	{model.ErrUnknownItem, http.StatusUnprocessableEntity, CodeInvalidShipment},
+	{model.ErrLineItemNotFound, http.StatusNotFound, CodeLineItemNotFound},
+	{model.ErrDuplicateItem, http.StatusConflict, CodeDuplicateItem},
+	{model.ErrNotEditable, http.StatusBadRequest, CodeNotEditable},
+	{model.ErrOverShipment, http.StatusUnprocessableEntity, CodeInvalidShipment},
+	{model.ErrNotReturnable, http.StatusBadRequest, CodeNotReturnable},
+	{model.ErrReturnNotFound, http.StatusNotFound, CodeReturnNotFound},
+	{model.ErrInvalidReturnTransition, http.StatusConflict, CodeInvalidReturnState},
+	{model.ErrOverReturn, http.StatusUnprocessableEntity, CodeInvalidReturn},
+	{model.ErrInvalidRefund, http.StatusUnprocessableEntity, CodeInvalidRefund},
+	{model.ErrRefundExceedsPaid, http.StatusUnprocessableEntity, CodeRefundExceedsPaid},
+	{model.ErrRefundExceedsReturn, http.StatusUnprocessableEntity, CodeRefundExceedsReturn},
+	{model.ErrNotDeleted, http.StatusConflict, CodeNotDeleted},
+	{model.ErrAlreadyDeleted, http.StatusNotFound, CodeOrderNotFound},
+	{webhook.ErrNotExist, http.StatusNotFound, CodeWebhookNotFound},
+	{patch.ErrInvalidPatch, http.StatusBadRequest, CodeInvalidPatch},
+	{patch.ErrTestFailed, http.StatusConflict, CodePatchTestFailed},
+	{patch.ErrConflict, http.StatusConflict, CodePatchConflict},
+	{idempotency.ErrMismatch, http.StatusUnprocessableEntity, CodeIdempotencyMismatch},
+	{idempotency.ErrInProgress, http.StatusConflict, CodeRequestInProgress},
+}
+
+// writeError envoie l'erreur au format problem+json.
+// Les erreurs inconnues sont journalisées et renvoyées comme une erreur 500 sans détail.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var p *Problem
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		p = validationProblem(invalid)
+	} else if !errors.As(err, &p) {
+		for _, d := range domainProblems {
+			if errors.Is(err, d.err) {
+				p = newProblem(d.status, d.code, d.err.Error())
+				break
+			}
+		}
+
+		// Une transition refusée précise les états de départ et d'arrivée.
+		var transition *model.TransitionError
+		if p != nil && errors.As(err, &transition) {
+			p.Detail = transition.Error()
+		}
+
+		// Un statut réservé précise l'opération qui l'atteint, un patch refusé l'opération et le chemin concernés.
+		if p != nil && (errors.Is(err, model.ErrNotRequestable) || errors.Is(err, patch.ErrInvalidPatch) || errors.Is(err, patch.ErrConflict) ||
+			errors.Is(err, patch.ErrTestFailed)) {
+			p.Detail = err.Error()
+		}
+	}
+	if p == nil {
+		fmt.Println("internal error:", err)
+		p = newProblem(http.StatusInternalServerError, CodeInternal, "")
+	}
+
+	res := *p
+	res.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(res.Status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Println("failed to marshal problem:", err)
+	}
+}
+
+// validationProblem crée un Problem 422 (Unprocessable Entity) listant toutes les erreurs de validation.
+func validationProblem(errs validation.Errors) *Problem {
+	fields := make([]FieldError, 0, len(errs))
+	for _, f := range errs {
+		fields = append(fields, FieldError{Field: f.Field, Code: f.Code, Message: f.Message})
+	}
+
+	return newProblem(http.StatusUnprocessableEntity, CodeValidationFailed,
+		fmt.Sprintf("request has %d invalid field(s)", len(fields)), fields...)
+}
+
+// MaxBodySize est la taille maximale, en octets, du corps d'une requête.
+const MaxBodySize = 1 << 20
+
+// bodyTooLarge crée le Problem 413 (Content Too Large) d'un corps de requête qui dépasse MaxBodySize.
+func bodyTooLarge() *Problem {
+	return newProblem(http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+		fmt.Sprintf("request body must not exceed %d bytes", MaxBodySize))
+}
+
+// readBody lit le corps de la requête, d'au plus MaxBodySize octets. Un corps plus grand n'est pas lu
+// au-delà de la limite et renvoie une erreur 413 (Content Too Large), une erreur de lecture une erreur 400.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, bodyTooLarge()
+	} else if err != nil {
+		return nil, newProblem(http.StatusBadRequest, CodeInvalidJSON, "failed to read request body")
+	}
+	return data, nil
+}
+
+// decodeJSON décode le corps JSON de la requête dans dst. Les champs inconnus sont refusés.
+// Les erreurs sont retournées sous forme de Problem, avec le champ concerné lorsqu'il est connu.
+// Un corps de plus de MaxBodySize octets renvoie une erreur 413 (Content Too Large).
+func decodeJSON(r *http.Request, dst any) error {
+	return decodeFrom(http.MaxBytesReader(nil, r.Body, MaxBodySize), dst)
+}
+
+// decodeFrom décode le document JSON lu dans body dans dst, comme decodeJSON.
+func decodeFrom(body io.Reader, dst any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		return nil
+	}
+
+	// encoding/json ne fournit pas d'erreur typée pour les champs inconnus : le nom est extrait du message.
+	const unknownFieldPrefix = "json: unknown field "
+	if msg := err.Error(); strings.HasPrefix(msg, unknownFieldPrefix) {
+		field := strings.Trim(strings.TrimPrefix(msg, unknownFieldPrefix), `"`)
+		return newProblem(http.StatusBadRequest, CodeUnknownField, "request body has an unknown field", FieldError{
+			Field:   field,
+			Code:    CodeUnknownField,
+			Message: "unknown field",
+		})
+	}
+
+	// Un montant mal formé, par exemple un prix donné comme un simple entier, est un document valide mais refusé.
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return bodyTooLarge()
+	case errors.Is(err, model.ErrInvalidMoney):
+		return newProblem(http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+	case errors.As(err, &typeErr):
+		detail := fmt.Sprintf("unexpected JSON %s", typeErr.Value)
+		return newProblem(http.StatusBadRequest, CodeInvalidJSON, "request body has an invalid field", FieldError{
+			Field:   typeErr.Field,
+			Code:    CodeInvalidJSON,
+			Message: detail,
+		})
+	case errors.Is(err, io.EOF):
+		return newProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is empty")
+	default:
+		return newProblem(http.StatusBadRequest, CodeInvalidJSON, err.Error())
+	}
+}
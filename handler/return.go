@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/pricing"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ListReturns est une méthode HTTP qui liste les retours d'une commande, avec le montant déjà remboursé
+// et le montant qui peut encore l'être.
+func (h *Order) ListReturns(w http.ResponseWriter, r *http.Request) {
+	// Extraction de l'ID de commande de l'URL.
+	idParam := chi.URLParam(r, "id")
+
+	// Conversion de l'ID en type uint64. Si échec, renvoie une erreur 400 (Bad Request).
+	const base = 10
+	const bitSize = 64
+	orderID, err := strconv.ParseUint(idParam, base, bitSize)
+	if err != nil {
+		writeError(w, r, invalidParameter("id", "order id must be an unsigned integer"))
+		return
+	}
+
+	// Recherche de la commande par son ID. Si elle n'existe pas, renvoie une erreur 404 (Not Found).
+	theOrder, err := h.Repo.FindByID(r.Context(), orderID, order.FindOptions{})
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to find by id: %w", err))
+		return
+	}
+
+	var response struct {
+		Items      []model.Return `json:"items"`      // Retours, dans l'ordre de leur demande.
+		Refunded   model.Money    `json:"refunded"`   // Somme des remboursements.
+		Refundable model.Money    `json:"refundable"` // Montant qui peut encore être remboursé.
+	}
+	response.Items = theOrder.Returns
+	if response.Items == nil {
+		response.Items = []model.Return{}
+	}
+	if response.Refunded, err = theOrder.Refunded(); err != nil {
+		writeError(w, r, fmt.Errorf("failed to compute refunds: %w", err))
+		return
+	}
+	if response.Refundable, err = theOrder.Refundable(); err != nil {
+		writeError(w, r, fmt.Errorf("failed to compute refunds: %w", err))
+		return
+	}
+
+	w.Header().Set("ETag", etag(theOrder))
+	writeJSON(w, response)
+}
+
+// CreateReturn est une méthode HTTP qui enregistre une demande de retour d'articles d'une commande livrée
+// ou finalisée. Le retour commence à l'état requested, en attente d'approbation.
+func (h *Order) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	// Structure pour décoder le corps de la requête JSON.
+	var body struct {
+		Reason    model.ReturnReason `json:"reason"`     // Motif du retour.
+		Note      string             `json:"note"`       // Précision libre sur le retour.
+		LineItems []model.ReturnLine `json:"line_items"` // Articles retournés et leurs quantités.
+	}
+
+	// Décodage du corps de la requête. Si échec, renvoie une erreur 400 (Bad Request).
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Création du retour avec les données fournies.
+	ret := model.Return{
+		ReturnID:    uuid.New(),                   // ID du nouveau retour.
+		Reason:      body.Reason,                  // Motif issu du corps de la requête.
+		Note:        strings.TrimSpace(body.Note), // Note issue du corps de la requête.
+		LineItems:   body.LineItems,               // Articles issus du corps de la requête.
+		RequestedAt: time.Now().UTC(),             // Date de la demande fixée à l'heure actuelle.
+	}
+
+	// Ajout du retour à la commande lue. Une commande ni livrée ni finalisée renvoie une erreur 400 (Bad Request) ;
+	// les erreurs de validation, dont une quantité supérieure au reste retournable, sont renvoyées avec le statut 422
+	// (Unprocessable Entity).
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		if err := o.Returnable(); err != nil {
+			return err
+		}
+		if err := h.Rules.Return(*o, ret); err != nil {
+			return err
+		}
+		return o.RequestReturn(ret)
+	})
+}
+
+// ApproveReturn est une méthode HTTP qui accepte un retour en attente de décision, avec une note facultative.
+// Réservé aux administrateurs.
+func (h *Order) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		writeError(w, r, newProblem(http.StatusForbidden, CodeForbidden, "approving a return requires an admin token"))
+		return
+	}
+
+	h.decideReturn(w, r, (*model.Order).ApproveReturn)
+}
+
+// RejectReturn est une méthode HTTP qui refuse un retour en attente de décision, avec une note facultative.
+// Les quantités d'un retour refusé peuvent faire l'objet d'une nouvelle demande. Réservé aux administrateurs.
+func (h *Order) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		writeError(w, r, newProblem(http.StatusForbidden, CodeForbidden, "rejecting a return requires an admin token"))
+		return
+	}
+
+	h.decideReturn(w, r, (*model.Order).RejectReturn)
+}
+
+// decideReturn applique la décision decide au retour désigné par l'URL, avec la note du corps de la requête.
+func (h *Order) decideReturn(w http.ResponseWriter, r *http.Request,
+	decide func(o *model.Order, id uuid.UUID, decision string, at time.Time) error) {
+	returnID, err := returnIDParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Structure pour décoder le corps de la requête JSON, facultatif.
+	var body struct {
+		Note string `json:"note"` // Explication de la décision.
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &body); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	decision := strings.TrimSpace(body.Note)
+	if err := h.Rules.ReturnDecision(decision); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Un retour qui n'est plus en attente de décision renvoie une erreur 409 (Conflict).
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		return decide(o, returnID, decision, time.Now().UTC())
+	})
+}
+
+// ReceiveReturn est une méthode HTTP qui enregistre la réception des articles d'un retour approuvé.
+// Réservé aux administrateurs.
+func (h *Order) ReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		writeError(w, r, newProblem(http.StatusForbidden, CodeForbidden, "receiving a return requires an admin token"))
+		return
+	}
+
+	returnID, err := returnIDParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Un retour qui n'est pas approuvé renvoie une erreur 409 (Conflict).
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		return o.ReceiveReturn(returnID, time.Now().UTC())
+	})
+}
+
+// RefundReturn est une méthode HTTP qui rembourse un retour dont les articles ont été reçus.
+// Sans montant, le remboursement est le prix net payé pour les articles retournés (voir pricing.RefundAmount),
+// plafonné au reste à rembourser. Un montant explicite ne peut pas dépasser celui des articles retournés,
+// frais de livraison compris (voir pricing.MaxRefund). La somme des remboursements ne dépasse jamais
+// le total de la commande. Réservé aux administrateurs.
+func (h *Order) RefundReturn(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		writeError(w, r, newProblem(http.StatusForbidden, CodeForbidden, "refunding a return requires an admin token"))
+		return
+	}
+
+	returnID, err := returnIDParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Structure pour décoder le corps de la requête JSON, facultatif.
+	var body struct {
+		Amount    *model.Money `json:"amount"`    // Montant remboursé, calculé par défaut.
+		Reference string       `json:"reference"` // Référence chez le prestataire de paiement.
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &body); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	// Remboursement du retour, vérifié par rapport à la commande lue. Un retour dont les articles n'ont pas été
+	// reçus renvoie une erreur 409 (Conflict) ; un montant supérieur au reste à rembourser
+	// ou à celui des articles retournés, une erreur 422.
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		ret, err := o.FindReturn(returnID)
+		if err != nil {
+			return err
+		}
+
+		limit, err := pricing.MaxRefund(*o, ret.LineItems)
+		if err != nil {
+			return fmt.Errorf("failed to compute refund: %w", err)
+		}
+
+		refund := model.Refund{Reference: strings.TrimSpace(body.Reference)}
+		if body.Amount != nil {
+			refund.Amount = *body.Amount
+		} else {
+			if refund.Amount, err = pricing.RefundAmount(*o, ret.LineItems); err != nil {
+				return fmt.Errorf("failed to compute refund: %w", err)
+			}
+			refundable, err := o.Refundable()
+			if err != nil {
+				return fmt.Errorf("failed to compute refund: %w", err)
+			}
+			if refund.Amount.Amount > refundable.Amount {
+				refund.Amount = refundable
+			}
+		}
+
+		// Le montant n'est vérifié que pour un retour reçu : sinon, RefundReturn signale l'état du retour.
+		if ret.Status == model.ReturnReceived {
+			if err := h.Rules.Refund(*o, refund); err != nil {
+				return err
+			}
+		}
+		return o.RefundReturn(returnID, refund, limit, time.Now().UTC())
+	})
+}
+
+// returnIDParam retourne l'ID du retour désigné par l'URL, ou une erreur 400 (Bad Request) s'il est invalide.
+func returnIDParam(r *http.Request) (uuid.UUID, error) {
+	returnID, err := uuid.Parse(chi.URLParam(r, "returnID"))
+	if err != nil {
+		return uuid.Nil, invalidParameter("return_id", "return id must be a UUID")
+	}
+	return returnID, nil
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/pricing"
+)
+
+// deliver crée la commande de corps body, l'expédie en un colis puis la marque livrée, et la retourne.
+func deliver(t *testing.T, srv *httptest.Server, body string) model.Order {
+	t.Helper()
+
+	res := call(t, srv, http.MethodPost, "/orders", body)
+	if res.status != http.StatusCreated {
+		t.Fatalf("POST /orders = %d %s, want 201", res.status, res.body)
+	}
+	var o model.Order
+	res.decode(t, &o)
+	path := fmt.Sprintf("/orders/%d", o.OrderID)
+
+	lines := ""
+	for i, item := range o.LineItems {
+		if i > 0 {
+			lines += ", "
+		}
+		lines += fmt.Sprintf(`{"item_id": "%s", "quantity": %d}`, item.ItemID, item.Quantity)
+	}
+	shipment := fmt.Sprintf(`{"carrier": "La Poste", "tracking_number": "6A123", "line_items": [%s]}`, lines)
+	if res := call(t, srv, http.MethodPost, path+"/shipments", shipment); res.status != http.StatusOK {
+		t.Fatalf("POST shipments = %d %s, want 200", res.status, res.body)
+	}
+
+	res = call(t, srv, http.MethodPut, path, `{"status": "delivered"}`)
+	if res.status != http.StatusOK {
+		t.Fatalf("PUT delivered = %d %s, want 200", res.status, res.body)
+	}
+	res.decode(t, &o)
+	return o
+}
+
+// requestReturn demande le retour de quantity articles item de la commande o, et retourne le retour créé.
+func requestReturn(t *testing.T, srv *httptest.Server, o model.Order, item string, quantity uint) model.Return {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"reason": "damaged", "line_items": [{"item_id": "%s", "quantity": %d}]}`, item, quantity)
+	res := call(t, srv, http.MethodPost, fmt.Sprintf("/orders/%d/returns", o.OrderID), body)
+	if res.status != http.StatusOK {
+		t.Fatalf("POST returns = %d %s, want 200", res.status, res.body)
+	}
+	var updated model.Order
+	res.decode(t, &updated)
+	return updated.Returns[len(updated.Returns)-1]
+}
+
+// returnPath retourne le chemin de l'action action sur le retour ret de la commande o.
+func returnPath(o model.Order, ret model.Return, action string) string {
+	return fmt.Sprintf("/orders/%d/returns/%s/%s", o.OrderID, ret.ReturnID, action)
+}
+
+// orderItem est l'article de la commande orderBody.
+const orderItem = "22222222-2222-2222-2222-222222222222"
+
+func TestReturnActionsRequireAdmin(t *testing.T) {
+	srv := testServer(t)
+	o := deliver(t, srv, orderBody)
+
+	// La demande de retour est ouverte à tous.
+	ret := requestReturn(t, srv, o, orderItem, 1)
+
+	// Chaque étape suivante exige le jeton d'administration, sans modifier le retour.
+	for _, action := range []string{"approve", "reject", "receive", "refund"} {
+		t.Run(action, func(t *testing.T) {
+			call(t, srv, http.MethodPost, returnPath(o, ret, action), "").
+				expectProblem(t, http.StatusForbidden, CodeForbidden)
+			call(t, srv, http.MethodPost, returnPath(o, ret, action), "", "Authorization", "Bearer wrong").
+				expectProblem(t, http.StatusForbidden, CodeForbidden)
+		})
+	}
+
+	var list struct {
+		Items []model.Return `json:"items"`
+	}
+	call(t, srv, http.MethodGet, fmt.Sprintf("/orders/%d/returns", o.OrderID), "").decode(t, &list)
+	if len(list.Items) != 1 || list.Items[0].Status != model.ReturnRequested {
+		t.Errorf("returns = %+v, want one requested return", list.Items)
+	}
+
+	for _, action := range []string{"approve", "receive", "refund"} {
+		if res := call(t, srv, http.MethodPost, returnPath(o, ret, action), "", admin...); res.status != http.StatusOK {
+			t.Errorf("POST %s as admin = %d %s, want 200", action, res.status, res.body)
+		}
+	}
+}
+
+// threeItemsBody est le corps de création d'une commande de 3 articles à 10,00 €, sans règles de prix :
+// son total payé est de 30,00 €.
+const threeItemsBody = `{
+	"customer_id": "11111111-1111-1111-1111-111111111111",
+	"line_items": [{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 3,
+		"price": {"amount": 1000, "currency": "EUR"}}]
+}`
+
+// receiveReturn demande le retour de quantity articles de la commande o, puis l'accepte et le reçoit.
+func receiveReturn(t *testing.T, srv *httptest.Server, o model.Order, quantity uint) model.Return {
+	t.Helper()
+
+	ret := requestReturn(t, srv, o, orderItem, quantity)
+	for _, action := range []string{"approve", "receive"} {
+		if res := call(t, srv, http.MethodPost, returnPath(o, ret, action), "", admin...); res.status != http.StatusOK {
+			t.Fatalf("POST %s = %d %s, want 200", action, res.status, res.body)
+		}
+	}
+	return ret
+}
+
+// refund rembourse le retour ret de la commande o avec le corps body, et retourne la commande mise à jour.
+func refund(t *testing.T, srv *httptest.Server, o model.Order, ret model.Return, body string) model.Order {
+	t.Helper()
+
+	res := call(t, srv, http.MethodPost, returnPath(o, ret, "refund"), body, admin...)
+	if res.status != http.StatusOK {
+		t.Fatalf("POST refund %s = %d %s, want 200", body, res.status, res.body)
+	}
+	var updated model.Order
+	res.decode(t, &updated)
+	return updated
+}
+
+// expectRefunds vérifie les montants remboursé et remboursable de la commande o.
+func expectRefunds(t *testing.T, srv *httptest.Server, o model.Order, refunded, refundable int64) {
+	t.Helper()
+
+	var list struct {
+		Refunded   model.Money `json:"refunded"`
+		Refundable model.Money `json:"refundable"`
+	}
+	call(t, srv, http.MethodGet, fmt.Sprintf("/orders/%d/returns", o.OrderID), "").decode(t, &list)
+	if list.Refunded.Amount != refunded || list.Refundable.Amount != refundable {
+		t.Errorf("refunded %d, refundable %d, want %d and %d", list.Refunded.Amount, list.Refundable.Amount,
+			refunded, refundable)
+	}
+}
+
+func TestRefundReturnPartial(t *testing.T) {
+	srv := testServer(t)
+	o := deliver(t, srv, threeItemsBody)
+
+	// Sans montant, un article rembourse son prix payé ; la commande reste livrée.
+	first := receiveReturn(t, srv, o, 1)
+	updated := refund(t, srv, o, first, "")
+	if ret, _ := updated.FindReturn(first.ReturnID); ret.Status != model.ReturnRefunded || ret.Refund == nil ||
+		ret.Refund.Amount != (model.Money{Amount: 1000, Currency: "EUR"}) {
+		t.Errorf("first refund = %+v, want 1000 EUR", ret.Refund)
+	}
+	if updated.Status != model.StatusDelivered {
+		t.Errorf("status after a partial refund = %s, want delivered", updated.Status)
+	}
+	expectRefunds(t, srv, o, 1000, 2000)
+
+	// Un montant supérieur au reste à rembourser est refusé sans modifier le retour.
+	second := receiveReturn(t, srv, o, 2)
+	call(t, srv, http.MethodPost, returnPath(o, second, "refund"), `{"amount": {"amount": 2001, "currency": "EUR"}}`,
+		admin...).expectProblem(t, http.StatusUnprocessableEntity, CodeValidationFailed)
+	expectRefunds(t, srv, o, 1000, 2000)
+
+	// Le dernier remboursement couvre le total : la commande passe au statut refunded.
+	updated = refund(t, srv, o, second, `{"amount": {"amount": 2000, "currency": "EUR"}, "reference": "re_42"}`)
+	if updated.Status != model.StatusRefunded {
+		t.Errorf("status after refunding the total = %s, want refunded", updated.Status)
+	}
+	expectRefunds(t, srv, o, 3000, 0)
+}
+
+func TestRefundReturnExceedsReturn(t *testing.T) {
+	srv := testServer(t)
+	o := deliver(t, srv, threeItemsBody)
+
+	// Un montant supérieur au prix de l'article retourné est refusé, même s'il reste à rembourser.
+	first := receiveReturn(t, srv, o, 1)
+	call(t, srv, http.MethodPost, returnPath(o, first, "refund"), `{"amount": {"amount": 2500, "currency": "EUR"}}`,
+		admin...).expectProblem(t, http.StatusUnprocessableEntity, CodeRefundExceedsReturn)
+	expectRefunds(t, srv, o, 0, 3000)
+
+	// Les retours suivants peuvent donc toujours être remboursés.
+	refund(t, srv, o, first, `{"amount": {"amount": 1000, "currency": "EUR"}}`)
+	second := receiveReturn(t, srv, o, 2)
+	updated := refund(t, srv, o, second, "")
+	if ret, _ := updated.FindReturn(second.ReturnID); ret.Refund == nil || ret.Refund.Amount.Amount != 2000 {
+		t.Errorf("second refund = %+v, want 2000 EUR", ret.Refund)
+	}
+	expectRefunds(t, srv, o, 3000, 0)
+}
+
+func TestRefundReturnShipping(t *testing.T) {
+	srv := testServerWithPricing(t, pricing.Rules{
+		Shipping: map[model.Currency]pricing.ShippingRule{"EUR": {Fee: 500, FreeFrom: 10000}},
+	})
+	o := deliver(t, srv, threeItemsBody)
+
+	// Un montant explicite peut rembourser les frais de livraison avec l'article retourné, sans les dépasser.
+	ret := receiveReturn(t, srv, o, 1)
+	call(t, srv, http.MethodPost, returnPath(o, ret, "refund"), `{"amount": {"amount": 1501, "currency": "EUR"}}`,
+		admin...).expectProblem(t, http.StatusUnprocessableEntity, CodeRefundExceedsReturn)
+	refund(t, srv, o, ret, `{"amount": {"amount": 1500, "currency": "EUR"}}`)
+	expectRefunds(t, srv, o, 1500, 2000)
+}
+
+func TestRefundReturnInvalid(t *testing.T) {
+	srv := testServer(t)
+	o := deliver(t, srv, threeItemsBody)
+	ret := receiveReturn(t, srv, o, 1)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"zero amount", `{"amount": {"amount": 0, "currency": "EUR"}}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"negative amount", `{"amount": {"amount": -100, "currency": "EUR"}}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"other currency", `{"amount": {"amount": 100, "currency": "USD"}}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call(t, srv, http.MethodPost, returnPath(o, ret, "refund"), tt.body, admin...).
+				expectProblem(t, tt.status, tt.code)
+		})
+	}
+	expectRefunds(t, srv, o, 0, 3000)
+}
+
+func TestReturnTransitions(t *testing.T) {
+	srv := testServer(t)
+	o := deliver(t, srv, threeItemsBody)
+
+	// Un retour demandé ne peut être ni reçu ni remboursé avant d'être accepté.
+	requested := requestReturn(t, srv, o, orderItem, 1)
+	for _, action := range []string{"receive", "refund"} {
+		call(t, srv, http.MethodPost, returnPath(o, requested, action), "", admin...).
+			expectProblem(t, http.StatusConflict, CodeInvalidReturnState)
+	}
+
+	// Un retour accepté ne peut pas être remboursé avant la réception des articles, ni refusé.
+	if res := call(t, srv, http.MethodPost, returnPath(o, requested, "approve"), "", admin...); res.status != http.StatusOK {
+		t.Fatalf("POST approve = %d %s, want 200", res.status, res.body)
+	}
+	for _, action := range []string{"refund", "reject", "approve"} {
+		call(t, srv, http.MethodPost, returnPath(o, requested, action), "", admin...).
+			expectProblem(t, http.StatusConflict, CodeInvalidReturnState)
+	}
+
+	// Un retour refusé est définitif.
+	rejected := requestReturn(t, srv, o, orderItem, 1)
+	if res := call(t, srv, http.MethodPost, returnPath(o, rejected, "reject"), `{"note": "Hors délai"}`, admin...); res.status != http.StatusOK {
+		t.Fatalf("POST reject = %d %s, want 200", res.status, res.body)
+	}
+	for _, action := range []string{"approve", "receive", "refund"} {
+		call(t, srv, http.MethodPost, returnPath(o, rejected, action), "", admin...).
+			expectProblem(t, http.StatusConflict, CodeInvalidReturnState)
+	}
+
+	// Un retour remboursé aussi : il ne peut pas être remboursé deux fois.
+	if res := call(t, srv, http.MethodPost, returnPath(o, requested, "receive"), "", admin...); res.status != http.StatusOK {
+		t.Fatalf("POST receive = %d %s, want 200", res.status, res.body)
+	}
+	refund(t, srv, o, requested, "")
+	call(t, srv, http.MethodPost, returnPath(o, requested, "refund"), "", admin...).
+		expectProblem(t, http.StatusConflict, CodeInvalidReturnState)
+	expectRefunds(t, srv, o, 1000, 2000)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/google/uuid"
+)
+
+// CreateShipment est une méthode HTTP qui enregistre un colis expédié pour une commande spécifiée par son ID.
+// Le statut de la commande est déduit des quantités expédiées : partially_shipped, puis shipped au dernier colis.
+// Le colis est enregistré avec la commande, dans la même écriture conditionnée par sa version.
+func (h *Order) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	// Structure pour décoder le corps de la requête JSON.
+	var body struct {
+		Carrier        string               `json:"carrier"`         // Transporteur du colis.
+		TrackingNumber string               `json:"tracking_number"` // Numéro de suivi chez le transporteur.
+		LineItems      []model.ShipmentLine `json:"line_items"`      // Articles du colis et leurs quantités.
+		ShippedAt      *time.Time           `json:"shipped_at"`      // Date de remise au transporteur, maintenant par défaut.
+	}
+
+	// Décodage du corps de la requête. Si échec, renvoie une erreur 400 (Bad Request).
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Création du colis avec les données fournies.
+	now := time.Now().UTC()
+	s := model.Shipment{
+		ShipmentID:     uuid.New(),                             // ID du nouveau colis.
+		Carrier:        strings.TrimSpace(body.Carrier),        // Transporteur issu du corps de la requête.
+		TrackingNumber: strings.TrimSpace(body.TrackingNumber), // Numéro de suivi issu du corps de la requête.
+		LineItems:      body.LineItems,                         // Articles issus du corps de la requête.
+		ShippedAt:      now,                                    // Date d'expédition par défaut.
+		CreatedAt:      now,                                    // Date d'enregistrement fixée à l'heure actuelle.
+	}
+	if body.ShippedAt != nil {
+		s.ShippedAt = body.ShippedAt.UTC()
+	}
+
+	// Ajout du colis à la commande lue. Une commande annulée ou déjà expédiée renvoie une erreur 400 (Bad Request) ;
+	// les erreurs de validation, dont une quantité supérieure au reste à expédier, sont renvoyées avec le statut 422
+	// (Unprocessable Entity).
+	h.update(w, r, order.FindOptions{}, func(o *model.Order) error {
+		if err := o.Shippable(); err != nil {
+			return err
+		}
+		if err := h.Rules.Shipment(*o, s); err != nil {
+			return err
+		}
+		return o.AddShipment(s)
+	})
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+)
+
+// twoItemsBody est le corps de création d'une commande de deux articles : 2 du premier et 1 du second.
+const twoItemsBody = `{
+	"customer_id": "11111111-1111-1111-1111-111111111111",
+	"line_items": [
+		{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 2, "price": {"amount": 1000, "currency": "EUR"}},
+		{"item_id": "33333333-3333-3333-3333-333333333333", "quantity": 1, "price": {"amount": 500, "currency": "EUR"}}
+	]
+}`
+
+// ship envoie un colis de la commande o contenant lines, des paires d'ID d'article et de quantité.
+func ship(t *testing.T, srv *httptest.Server, o model.Order, lines ...any) response {
+	t.Helper()
+
+	items := ""
+	for i := 0; i+1 < len(lines); i += 2 {
+		if i > 0 {
+			items += ", "
+		}
+		items += fmt.Sprintf(`{"item_id": "%s", "quantity": %d}`, lines[i], lines[i+1])
+	}
+	body := fmt.Sprintf(`{"carrier": "La Poste", "tracking_number": "6A123", "line_items": [%s]}`, items)
+	return call(t, srv, http.MethodPost, fmt.Sprintf("/orders/%d/shipments", o.OrderID), body)
+}
+
+func TestCreateShipmentPartialThenFull(t *testing.T) {
+	srv := testServer(t)
+	res := call(t, srv, http.MethodPost, "/orders", twoItemsBody)
+	if res.status != http.StatusCreated {
+		t.Fatalf("POST /orders = %d %s, want 201", res.status, res.body)
+	}
+	var o model.Order
+	res.decode(t, &o)
+
+	// Un premier colis avec une partie des articles rend la commande partiellement expédiée.
+	res = ship(t, srv, o, orderItem, 1)
+	if res.status != http.StatusOK {
+		t.Fatalf("first shipment = %d %s, want 200", res.status, res.body)
+	}
+	res.decode(t, &o)
+	if o.Status != model.StatusPartiallyShipped || o.ShippedAt != nil || len(o.Shipments) != 1 {
+		t.Errorf("after the first shipment: status %s, shipped_at %v, %d shipments, want partially_shipped, nil, 1",
+			o.Status, o.ShippedAt, len(o.Shipments))
+	}
+
+	// Le colis qui complète la commande la rend expédiée, à sa date d'expédition.
+	res = ship(t, srv, o, orderItem, 1, "33333333-3333-3333-3333-333333333333", 1)
+	if res.status != http.StatusOK {
+		t.Fatalf("second shipment = %d %s, want 200", res.status, res.body)
+	}
+	res.decode(t, &o)
+	if o.Status != model.StatusShipped || o.ShippedAt == nil || !o.ShippedAt.Equal(o.Shipments[1].ShippedAt) {
+		t.Errorf("after the last shipment: status %s, shipped_at %v, want shipped at %v",
+			o.Status, o.ShippedAt, o.Shipments[1].ShippedAt)
+	}
+
+	// Une commande entièrement expédiée ne reçoit plus de colis.
+	ship(t, srv, o, orderItem, 1).expectProblem(t, http.StatusBadRequest, CodeAlreadyShipped)
+}
+
+func TestCreateShipmentOverShipment(t *testing.T) {
+	srv := testServer(t)
+	o := create(t, srv)
+
+	if res := ship(t, srv, o, orderItem, 1); res.status != http.StatusOK {
+		t.Fatalf("first shipment = %d %s, want 200", res.status, res.body)
+	}
+
+	tests := []struct {
+		name  string
+		lines []any
+		field string
+		code  string
+	}{
+		// Il ne reste qu'un article à expédier sur les deux commandés.
+		{"more than left", []any{orderItem, 2}, "line_items[0].quantity", "out_of_range"},
+		{"unknown item", []any{"33333333-3333-3333-3333-333333333333", 1}, "line_items[0].item_id", "invalid"},
+		{"zero quantity", []any{orderItem, 0}, "line_items[0].quantity", "out_of_range"},
+		{"duplicate item", []any{orderItem, 1, orderItem, 1}, "line_items[1].item_id", "duplicate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ship(t, srv, o, tt.lines...)
+			res.expectProblem(t, http.StatusUnprocessableEntity, CodeValidationFailed)
+			if got := res.fieldCodes(t); len(got) != 1 || got[tt.field] != tt.code {
+				t.Errorf("errors = %v, want %s: %s", got, tt.field, tt.code)
+			}
+		})
+	}
+
+	// Les colis refusés ne modifient pas la commande.
+	var found model.Order
+	call(t, srv, http.MethodGet, fmt.Sprintf("/orders/%d", o.OrderID), "").decode(t, &found)
+	if len(found.Shipments) != 1 || found.Status != model.StatusPartiallyShipped {
+		t.Errorf("order has %d shipments and status %s, want 1 and partially_shipped", len(found.Shipments), found.Status)
+	}
+}
+
+func TestCreateShipmentCancelled(t *testing.T) {
+	srv := testServer(t)
+	o := create(t, srv)
+
+	res := call(t, srv, http.MethodPost, fmt.Sprintf("/orders/%d/cancel", o.OrderID), `{"reason": "customer_request"}`)
+	if res.status != http.StatusOK {
+		t.Fatalf("POST cancel = %d %s, want 200", res.status, res.body)
+	}
+	ship(t, srv, o, orderItem, 1).expectProblem(t, http.StatusBadRequest, CodeAlreadyCancelled)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/SamMebarek/orders-api/validation"
+	"github.com/SamMebarek/orders-api/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Webhook regroupe les gestionnaires HTTP des abonnements aux webhooks. Ils sont réservés aux administrateurs.
+type Webhook struct {
+	Store      webhook.Store // Abonnements et journaux des envois, nil si le stockage ne publie pas d'événements.
+	AdminToken string        // Jeton des administrateurs, vide pour refuser toutes les requêtes.
+}
+
+// Guard est un middleware qui refuse les requêtes lorsque les webhooks sont indisponibles
+// avec le stockage configuré, puis celles qui ne portent pas le jeton d'administration.
+func (h *Webhook) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Seul le stockage Redis publie les événements. Sinon, renvoie une erreur 501 (Not Implemented).
+		if h.Store == nil {
+			writeError(w, r, newProblem(http.StatusNotImplemented, CodeNotSupported,
+				"webhooks are not available with this storage"))
+			return
+		}
+
+		if !isAdmin(r, h.AdminToken) {
+			writeError(w, r, newProblem(http.StatusForbidden, CodeForbidden, "managing webhooks requires an admin token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Create est une méthode HTTP pour créer un abonnement.
+func (h *Webhook) Create(w http.ResponseWriter, r *http.Request) {
+	// Définition d'un struct pour décoder le corps de la requête JSON.
+	var body struct {
+		URL    string       `json:"url"`    // URL recevant les événements.
+		Events []event.Type `json:"events"` // Types d'événements envoyés, vide pour tous.
+		Secret string       `json:"secret"` // Clé de signature des envois.
+	}
+
+	// Décodage du corps de la requête JSON. Si cela échoue, renvoie une erreur 400 (Bad Request).
+	if err := decodeJSON(r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	sub := webhook.Subscription{
+		ID:        uuid.NewString(),
+		URL:       body.URL,
+		Events:    body.Events,
+		Secret:    body.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Validation de l'abonnement. Toutes les erreurs sont renvoyées avec le statut 422 (Unprocessable Entity).
+	if err := validation.Subscription(sub.URL, sub.Events, sub.Secret); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.Store.Create(r.Context(), sub); err != nil {
+		writeError(w, r, fmt.Errorf("failed to create subscription: %w", err))
+		return
+	}
+
+	// Envoi de l'abonnement créé, sans sa clé, avec le statut 201 (Created).
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, redact(sub))
+}
+
+// List est une méthode HTTP pour lister les abonnements.
+func (h *Webhook) List(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.Store.List(r.Context())
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to list subscriptions: %w", err))
+		return
+	}
+
+	var response struct {
+		Items []webhook.Subscription `json:"items"` // Abonnements, du plus ancien au plus récent.
+	}
+	response.Items = make([]webhook.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		response.Items = append(response.Items, redact(sub))
+	}
+
+	writeJSON(w, response)
+}
+
+// GetByID est une méthode HTTP pour obtenir un abonnement par son ID.
+func (h *Webhook) GetByID(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.Store.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to get subscription: %w", err))
+		return
+	}
+
+	writeJSON(w, redact(sub))
+}
+
+// DeleteByID est une méthode HTTP pour supprimer un abonnement. Ses envois en attente sont abandonnés.
+func (h *Webhook) DeleteByID(w http.ResponseWriter, r *http.Request) {
+	if err := h.Store.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeError(w, r, fmt.Errorf("failed to delete subscription: %w", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deliveries est une méthode HTTP pour obtenir le journal des dernières tentatives d'envoi d'un abonnement.
+func (h *Webhook) Deliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.Store.Deliveries(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to get deliveries: %w", err))
+		return
+	}
+
+	var response struct {
+		Items []webhook.Delivery `json:"items"` // Tentatives, de la plus récente à la plus ancienne.
+	}
+	response.Items = deliveries
+
+	writeJSON(w, response)
+}
+
+// DeadLetters est une méthode HTTP pour obtenir les envois abandonnés d'un abonnement.
+func (h *Webhook) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.Store.DeadLetters(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to get dead letters: %w", err))
+		return
+	}
+
+	var response struct {
+		Items []webhook.DeadLetter `json:"items"` // Envois abandonnés, du plus récent au plus ancien.
+	}
+	response.Items = letters
+
+	writeJSON(w, response)
+}
+
+// redact retire la clé de signature d'un abonnement avant de le renvoyer.
+func redact(sub webhook.Subscription) webhook.Subscription {
+	sub.Secret = ""
+	return sub
+}
+
+// writeJSON envoie v en JSON. Les erreurs de sérialisation sont journalisées.
+func writeJSON(w http.ResponseWriter, v any) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Println("failed to marshal:", err)
+	}
+}
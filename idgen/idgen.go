@@ -0,0 +1,16 @@
+// Package idgen génère les identifiants des commandes.
+package idgen
+
+import "context"
+
+// Generator fournit des identifiants uniques et croissants dans le temps.
+type Generator interface {
+	// NextID retourne un nouvel identifiant.
+	NextID(ctx context.Context) (uint64, error)
+}
+
+// Vérifie à la compilation que les implémentations respectent l'interface.
+var (
+	_ Generator = (*Snowflake)(nil)
+	_ Generator = (*RedisCounter)(nil)
+)
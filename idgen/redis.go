@@ -0,0 +1,24 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCounter génère des identifiants séquentiels partagés entre instances grâce à INCR.
+type RedisCounter struct {
+	Client *redis.Client
+	Key    string // Clé Redis du compteur.
+}
+
+// NextID retourne la valeur suivante du compteur.
+func (c *RedisCounter) NextID(ctx context.Context) (uint64, error) {
+	id, err := c.Client.Incr(ctx, c.Key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment id counter: %w", err)
+	}
+
+	return uint64(id), nil
+}
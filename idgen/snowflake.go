@@ -0,0 +1,71 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Répartition des 63 bits d'un identifiant Snowflake : date, nœud, puis séquence.
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	// MaxNode est le plus grand numéro de nœud accepté.
+	MaxNode     = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+)
+
+// epoch est l'origine des dates des identifiants (1er janvier 2023 UTC),
+// ce qui laisse environ 69 ans d'identifiants sur 41 bits de millisecondes.
+var epoch = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake génère des identifiants triés par date de création, sans coordination entre instances.
+// Chaque instance doit avoir un numéro de nœud différent.
+type Snowflake struct {
+	node uint64
+	now  func() time.Time // Horloge, remplacée dans les tests.
+
+	mu       sync.Mutex
+	last     int64  // Dernière milliseconde utilisée.
+	sequence uint64 // Compteur dans la milliseconde courante.
+}
+
+// NewSnowflake crée un générateur pour le nœud donné, compris entre 0 et MaxNode.
+func NewSnowflake(node uint16) (*Snowflake, error) {
+	if node > MaxNode {
+		return nil, fmt.Errorf("node id %d out of range [0, %d]", node, MaxNode)
+	}
+
+	return &Snowflake{node: uint64(node), now: time.Now}, nil
+}
+
+// NextID retourne un nouvel identifiant.
+// Si la séquence de la milliseconde courante est épuisée, ou si l'horloge recule, NextID attend
+// la milliseconde suivante pour ne jamais produire deux fois le même identifiant.
+func (s *Snowflake) NextID(ctx context.Context) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().Sub(epoch).Milliseconds()
+	if now < s.last {
+		now = s.last
+	}
+
+	if now == s.last {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			// Séquence épuisée : attente de la milliseconde suivante.
+			for now <= s.last {
+				time.Sleep(time.Millisecond / 10)
+				now = s.now().Sub(epoch).Milliseconds()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+
+	return uint64(now)<<(nodeBits+sequenceBits) | s.node<<sequenceBits | s.sequence, nil
+}
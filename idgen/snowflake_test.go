@@ -0,0 +1,158 @@
+package idgen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock est une horloge réglée par le test, qui avance d'une milliseconde à chaque attente
+// pour que le générateur puisse sortir d'une séquence épuisée.
+type fakeClock struct {
+	mu    sync.Mutex
+	at    time.Time
+	calls int
+	// advanceAfter fait avancer l'horloge d'une milliseconde après ce nombre de lectures, 0 pour ne jamais avancer.
+	advanceAfter int
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	if c.advanceAfter > 0 && c.calls > c.advanceAfter {
+		c.at = c.at.Add(time.Millisecond)
+		c.advanceAfter = 0
+	}
+	return c.at
+}
+
+func (c *fakeClock) set(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.at = at
+}
+
+// newTestSnowflake crée un générateur du nœud node sur l'horloge clock.
+func newTestSnowflake(t *testing.T, node uint16, clock *fakeClock) *Snowflake {
+	t.Helper()
+
+	s, err := NewSnowflake(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = clock.now
+	return s
+}
+
+// decode retourne la milliseconde, le nœud et la séquence d'un identifiant.
+func decode(id uint64) (ms int64, node, sequence uint64) {
+	return int64(id >> (nodeBits + sequenceBits)), id >> sequenceBits & MaxNode, id & maxSequence
+}
+
+func TestNewSnowflakeNodeRange(t *testing.T) {
+	for _, node := range []uint16{0, 1, MaxNode} {
+		if _, err := NewSnowflake(node); err != nil {
+			t.Errorf("NewSnowflake(%d) error = %v", node, err)
+		}
+	}
+	for _, node := range []uint16{MaxNode + 1, 1 << 15} {
+		if _, err := NewSnowflake(node); err == nil {
+			t.Errorf("NewSnowflake(%d) succeeded, want an error", node)
+		}
+	}
+}
+
+func TestSnowflakeMonotonic(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSnowflake(MaxNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last uint64
+	for i := 0; i < 10000; i++ {
+		id, err := s.NextID(ctx)
+		if err != nil {
+			t.Fatalf("NextID() error = %v", err)
+		}
+		if id <= last {
+			t.Fatalf("NextID() = %d after %d, want a greater id", id, last)
+		}
+		if _, node, _ := decode(id); node != MaxNode {
+			t.Fatalf("NextID() node = %d, want %d", node, MaxNode)
+		}
+		last = id
+	}
+}
+
+func TestSnowflakeSequenceExhausted(t *testing.T) {
+	ctx := context.Background()
+	start := epoch.Add(time.Hour)
+	clock := &fakeClock{at: start}
+	s := newTestSnowflake(t, 7, clock)
+
+	// Toute la séquence d'une milliseconde est utilisée sans que l'horloge avance.
+	for want := uint64(0); want <= maxSequence; want++ {
+		id, err := s.NextID(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ms, node, sequence := decode(id); ms != time.Hour.Milliseconds() || node != 7 || sequence != want {
+			t.Fatalf("NextID() = (%d, %d, %d), want (%d, 7, %d)", ms, node, sequence, time.Hour.Milliseconds(), want)
+		}
+	}
+
+	// L'identifiant suivant attend la milliseconde suivante.
+	clock.mu.Lock()
+	clock.advanceAfter = clock.calls + 3
+	clock.mu.Unlock()
+	id, err := s.NextID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms, _, sequence := decode(id); ms != time.Hour.Milliseconds()+1 || sequence != 0 {
+		t.Errorf("NextID() after an exhausted sequence = (%d, %d), want (%d, 0)", ms, sequence, time.Hour.Milliseconds()+1)
+	}
+}
+
+func TestSnowflakeClockBackwards(t *testing.T) {
+	ctx := context.Background()
+	start := epoch.Add(time.Hour)
+	clock := &fakeClock{at: start}
+	s := newTestSnowflake(t, 1, clock)
+
+	first, err := s.NextID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// L'horloge recule d'une seconde : les identifiants restent croissants, sur la dernière milliseconde utilisée.
+	clock.set(start.Add(-time.Second))
+	last := first
+	for i := 0; i < 3; i++ {
+		id, err := s.NextID(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("NextID() after the clock went backwards = %d, want more than %d", id, last)
+		}
+		if ms, _, _ := decode(id); ms != time.Hour.Milliseconds() {
+			t.Errorf("NextID() millisecond = %d, want %d", ms, time.Hour.Milliseconds())
+		}
+		last = id
+	}
+
+	// Quand l'horloge dépasse à nouveau la dernière milliseconde utilisée, la séquence repart de zéro.
+	clock.set(start.Add(time.Millisecond))
+	id, err := s.NextID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms, _, sequence := decode(id); id <= last || ms != time.Hour.Milliseconds()+1 || sequence != 0 {
+		t.Errorf("NextID() = %d (%d, %d), want more than %d at (%d, 0)", id, ms, sequence, last, time.Hour.Milliseconds()+1)
+	}
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Address est une adresse postale, recopiée dans la commande à sa création : un changement d'adresse du client
+// ne modifie pas ses commandes passées.
+type Address struct {
+	Name       string  `json:"name"`             // Nom du destinataire ou du titulaire de la facture.
+	Line1      string  `json:"line1"`            // Numéro et voie.
+	Line2      string  `json:"line2,omitempty"`  // Complément d'adresse : bâtiment, étage, boîte postale.
+	City       string  `json:"city"`             // Ville.
+	Region     string  `json:"region,omitempty"` // État, province ou région, lorsque le pays l'utilise.
+	PostalCode string  `json:"postal_code"`      // Code postal, au format du pays.
+	Country    Country `json:"country"`          // Pays.
+}
+
+// Normalize retourne l'adresse sans espaces superflus, avec le pays et le code postal en majuscules.
+func (a Address) Normalize() Address {
+	return Address{
+		Name:       strings.TrimSpace(a.Name),
+		Line1:      strings.TrimSpace(a.Line1),
+		Line2:      strings.TrimSpace(a.Line2),
+		City:       strings.TrimSpace(a.City),
+		Region:     strings.TrimSpace(a.Region),
+		PostalCode: strings.ToUpper(strings.TrimSpace(a.PostalCode)),
+		Country:    Country(strings.ToUpper(strings.TrimSpace(string(a.Country)))),
+	}
+}
+
+// Country est le code ISO 3166-1 alpha-2 d'un pays, par exemple "FR".
+type Country string
+
+// countries est l'ensemble des codes ISO 3166-1 alpha-2 attribués.
+var countries = func() map[Country]bool {
+	codes := strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ
+		CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR
+		GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO IQ IR IS IT
+		JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY
+		MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ
+		OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW
+		SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ
+		UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`)
+	set := make(map[Country]bool, len(codes))
+	for _, code := range codes {
+		set[Country(code)] = true
+	}
+	return set
+}()
+
+// MaxPostalCodeLength est la longueur maximale d'un code postal d'un pays dont le format n'est pas connu.
+const MaxPostalCodeLength = 10
+
+// genericPostalCode est le format accepté des codes postaux d'un pays dont le format n'est pas connu :
+// lettres majuscules et chiffres, éventuellement séparés par une espace ou un tiret.
+var genericPostalCode = regexp.MustCompile(`^[A-Z\d]+([ -][A-Z\d]+)*$`)
+
+// countryPostalCodes associe à certains pays le format de leurs codes postaux, en majuscules.
+var countryPostalCodes = map[Country]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"CZ": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"ES": regexp.MustCompile(`^(0[1-9]|[1-4]\d|5[0-2])\d{3}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"HU": regexp.MustCompile(`^\d{4}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"LU": regexp.MustCompile(`^\d{4}$`),
+	"MA": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^[1-9]\d{3} ?[A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"RO": regexp.MustCompile(`^\d{6}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"TN": regexp.MustCompile(`^\d{4}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// Valid indique si le pays est un code ISO 3166-1 alpha-2 attribué.
+func (c Country) Valid() bool {
+	return countries[c]
+}
+
+// ValidPostalCode indique si code a le format des codes postaux du pays. Pour un pays dont le format n'est pas
+// connu, le code doit seulement être non vide, d'au plus MaxPostalCodeLength caractères, fait de lettres
+// et de chiffres. Il est toujours invalide pour un pays qui n'est pas valide.
+func (c Country) ValidPostalCode(code string) bool {
+	if !c.Valid() {
+		return false
+	}
+	if format, exists := countryPostalCodes[c]; exists {
+		return format.MatchString(code)
+	}
+	return len(code) <= MaxPostalCodeLength && genericPostalCode.MatchString(code)
+}
+
+// AddressesEditable indique si les adresses de la commande peuvent encore être modifiées : jusqu'à l'expédition
+// de son premier colis. Une commande expédiée, même en partie ou remboursée depuis, retourne une erreur
+// correspondant à ErrAlreadyShipped.
+func (o Order) AddressesEditable() error {
+	switch o.CurrentStatus() {
+	case StatusPartiallyShipped, StatusShipped, StatusDelivered, StatusCompleted:
+		return fmt.Errorf("%w: addresses cannot be changed", ErrAlreadyShipped)
+	}
+	if o.ShippedAt != nil || len(o.Shipments) > 0 {
+		return fmt.Errorf("%w: addresses cannot be changed", ErrAlreadyShipped)
+	}
+	return nil
+}
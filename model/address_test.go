@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidPostalCode(t *testing.T) {
+	tests := []struct {
+		country Country
+		valid   []string
+		invalid []string
+	}{
+		{"FR", []string{"75001", "97400"}, []string{"7500", "750011", "75 001", "2A004"}},
+		{"DE", []string{"10115"}, []string{"1011", "D-10115"}},
+		{"ES", []string{"28013", "01001", "52001"}, []string{"53001", "00100"}},
+		{"GB", []string{"SW1A 1AA", "M1 1AE", "EC1A1BB"}, []string{"SW1A-1AA", "12345"}},
+		{"CA", []string{"K1A 0B1", "K1A0B1"}, []string{"D1A 0B1", "K1A 0BO"}},
+		{"NL", []string{"1012 AB", "1012AB"}, []string{"0123 AB", "1012"}},
+		{"US", []string{"10001", "10001-1234"}, []string{"1000", "10001-12"}},
+		{"JP", []string{"100-0001", "1000001"}, []string{"100-001"}},
+		{"PL", []string{"00-950"}, []string{"00950"}},
+		{"PT", []string{"1000-001"}, []string{"1000"}},
+		{"CZ", []string{"110 00", "11000"}, []string{"1100"}},
+		// Un pays sans format connu accepte tout code court de lettres et de chiffres.
+		{"IE", []string{"D02 X285", "A65F4E2"}, []string{"", "D02  X285", "D02_X285", "12345678901"}},
+		{"BR", []string{"01310-100"}, []string{"01310-", "-100"}},
+		// Un code qui n'est pas un pays n'a aucun code postal valide.
+		{"ZZ", nil, []string{"75001", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.country), func(t *testing.T) {
+			for _, code := range tt.valid {
+				if !tt.country.ValidPostalCode(code) {
+					t.Errorf("ValidPostalCode(%q) = false, want true", code)
+				}
+			}
+			for _, code := range tt.invalid {
+				if tt.country.ValidPostalCode(code) {
+					t.Errorf("ValidPostalCode(%q) = true, want false", code)
+				}
+			}
+		})
+	}
+}
+
+func TestCountryValid(t *testing.T) {
+	for _, c := range []Country{"FR", "IE", "BR", "IN", "HK"} {
+		if !c.Valid() {
+			t.Errorf("%s.Valid() = false, want true", c)
+		}
+	}
+	for _, c := range []Country{"", "ZZ", "fr", "FRA", "UK"} {
+		if c.Valid() {
+			t.Errorf("%q.Valid() = true, want false", c)
+		}
+	}
+}
+
+func TestAddressNormalize(t *testing.T) {
+	a := Address{Name: " Jeanne Martin ", Line1: "1 rue de Rivoli ", City: " Paris", PostalCode: " sw1a 1aa ", Country: " gb"}
+	want := Address{Name: "Jeanne Martin", Line1: "1 rue de Rivoli", City: "Paris", PostalCode: "SW1A 1AA", Country: "GB"}
+	if got := a.Normalize(); got != want {
+		t.Errorf("Normalize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAddressesEditable(t *testing.T) {
+	shipped := time.Now().UTC()
+	tests := []struct {
+		name  string
+		order Order
+		want  error
+	}{
+		{"pending", Order{Status: StatusPending}, nil},
+		{"paid", Order{Status: StatusPaid}, nil},
+		{"partially shipped", Order{Status: StatusPartiallyShipped}, ErrAlreadyShipped},
+		{"delivered", Order{Status: StatusDelivered, ShippedAt: &shipped}, ErrAlreadyShipped},
+		// Une commande remboursée après son expédition garde ses adresses.
+		{"refunded after shipping", Order{Status: StatusRefunded, ShippedAt: &shipped}, ErrAlreadyShipped},
+	}
+
+	for _, tt := range tests {
+		if err := tt.order.AddressesEditable(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: AddressesEditable() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+// AdjustmentKind est la nature d'une ligne d'ajustement du prix d'une commande.
+type AdjustmentKind string
+
+// Natures des lignes d'ajustement.
+const (
+	AdjustmentDiscount AdjustmentKind = "discount" // Remise, de montant négatif.
+	AdjustmentTax      AdjustmentKind = "tax"      // Taxe d'un article.
+	AdjustmentShipping AdjustmentKind = "shipping" // Frais de livraison.
+)
+
+// Adjustment est une ligne d'ajustement ajoutée au sous-total d'une commande lors du calcul de son prix.
+// Le total de la commande est la somme de son sous-total et de ses ajustements, ce qui le rend vérifiable.
+type Adjustment struct {
+	Kind        AdjustmentKind `json:"kind"`                  // Nature de l'ajustement.
+	Code        string         `json:"code,omitempty"`        // Code promotionnel à l'origine d'une remise.
+	Description string         `json:"description,omitempty"` // Libellé de la règle appliquée.
+	Line        *int           `json:"line,omitempty"`        // Index de l'article concerné, pour une taxe.
+	RateBP      int64          `json:"rate_bp,omitempty"`     // Taux appliqué, en points de base (2000 pour 20 %).
+	Base        *Money         `json:"base,omitempty"`        // Montant auquel le taux a été appliqué.
+	Amount      Money          `json:"amount"`                // Montant de l'ajustement, négatif pour une remise.
+}
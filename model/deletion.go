@@ -0,0 +1,37 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// Erreurs retournées lors de la suppression ou de la restauration d'une commande.
+var (
+	ErrAlreadyDeleted = errors.New("order already deleted")
+	ErrNotDeleted     = errors.New("order not deleted")
+)
+
+// Delete marque la commande comme supprimée à la date donnée, par l'auteur donné.
+// La commande est conservée jusqu'à sa purge et peut être restaurée d'ici là.
+func (o *Order) Delete(by string, at time.Time) error {
+	if o.DeletedAt != nil {
+		return ErrAlreadyDeleted
+	}
+
+	o.DeletedAt = &at
+	o.DeletedBy = by
+
+	return nil
+}
+
+// Restore annule la suppression de la commande.
+func (o *Order) Restore() error {
+	if o.DeletedAt == nil {
+		return ErrNotDeleted
+	}
+
+	o.DeletedAt = nil
+	o.DeletedBy = ""
+
+	return nil
+}
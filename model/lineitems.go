@@ -0,0 +1,81 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Erreurs retournées lors de la modification des articles d'une commande.
+var (
+	ErrLineItemNotFound = errors.New("line item not found")
+	ErrDuplicateItem    = errors.New("item already in order")
+	ErrNotEditable      = errors.New("order items and customer can only be changed while the order is pending")
+)
+
+// Editable indique si les articles et le client de la commande peuvent encore être modifiés : seule une commande
+// en attente le peut. Une commande expédiée, même en partie, retourne une erreur correspondant à ErrAlreadyShipped,
+// toute autre commande une erreur correspondant à ErrNotEditable.
+func (o Order) Editable() error {
+	switch status := o.CurrentStatus(); status {
+	case StatusPending:
+		return nil
+	case StatusPartiallyShipped, StatusShipped, StatusDelivered, StatusCompleted:
+		return fmt.Errorf("%w: line items cannot be changed", ErrAlreadyShipped)
+	default:
+		return fmt.Errorf("%w: order is %s", ErrNotEditable, status)
+	}
+}
+
+// AddLineItem ajoute un article à la commande en attente. Un article déjà présent retourne ErrDuplicateItem :
+// sa quantité se modifie avec SetQuantity.
+func (o *Order) AddLineItem(item LineItem) error {
+	if err := o.Editable(); err != nil {
+		return err
+	}
+	if _, err := o.lineIndex(item.ItemID); err == nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateItem, item.ItemID)
+	}
+
+	o.LineItems = append(o.LineItems, item)
+	return nil
+}
+
+// SetQuantity modifie la quantité d'un article de la commande en attente.
+func (o *Order) SetQuantity(itemID uuid.UUID, quantity uint) error {
+	if err := o.Editable(); err != nil {
+		return err
+	}
+	i, err := o.lineIndex(itemID)
+	if err != nil {
+		return err
+	}
+
+	o.LineItems[i].Quantity = quantity
+	return nil
+}
+
+// RemoveLineItem retire un article de la commande en attente, en conservant l'ordre des autres articles.
+func (o *Order) RemoveLineItem(itemID uuid.UUID) error {
+	if err := o.Editable(); err != nil {
+		return err
+	}
+	i, err := o.lineIndex(itemID)
+	if err != nil {
+		return err
+	}
+
+	o.LineItems = append(o.LineItems[:i:i], o.LineItems[i+1:]...)
+	return nil
+}
+
+// lineIndex retourne la position de l'article dans la commande, ou une erreur correspondant à ErrLineItemNotFound.
+func (o Order) lineIndex(itemID uuid.UUID) (int, error) {
+	for i, item := range o.LineItems {
+		if item.ItemID == itemID {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrLineItemNotFound, itemID)
+}
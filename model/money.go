@@ -0,0 +1,168 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Currency est le code ISO 4217 d'une devise, par exemple "EUR".
+type Currency string
+
+// LegacyCurrency est la devise des prix enregistrés sans devise, avant que les commandes en aient une.
+const LegacyCurrency Currency = "EUR"
+
+// currencyMinorUnits associe aux devises acceptées leur nombre de décimales (unités mineures) selon ISO 4217.
+var currencyMinorUnits = map[Currency]int{
+	"AUD": 2, "BHD": 3, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "JPY": 0, "KRW": 0, "KWD": 3, "MAD": 2, "MXN": 2,
+	"NOK": 2, "NZD": 2, "PLN": 2, "RON": 2, "SEK": 2, "SGD": 2, "TND": 3, "USD": 2,
+}
+
+// Valid indique si la devise fait partie des devises acceptées.
+func (c Currency) Valid() bool {
+	_, exists := currencyMinorUnits[c]
+	return exists
+}
+
+// MinorUnits retourne le nombre de décimales de la devise, par exemple 2 pour l'euro (centimes).
+func (c Currency) MinorUnits() int {
+	return currencyMinorUnits[c]
+}
+
+// Erreurs des opérations sur les montants.
+var (
+	ErrCurrencyMismatch = errors.New("amounts have different currencies")
+	ErrAmountOverflow   = errors.New("amount overflows")
+	ErrInvalidMoney     = errors.New("amounts must be an object with an integer amount in minor units and a currency")
+)
+
+// Money est un montant exact, en unités mineures de sa devise (par exemple en centimes pour l'euro).
+// Les calculs se font en entiers et échouent plutôt que de dépasser la capacité d'un int64.
+type Money struct {
+	Amount   int64    `json:"amount"`   // Montant en unités mineures.
+	Currency Currency `json:"currency"` // Devise du montant.
+}
+
+// Add retourne la somme de deux montants de même devise.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Mul retourne le montant multiplié par une quantité.
+func (m Money) Mul(quantity uint) (Money, error) {
+	if quantity == 0 || m.Amount == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	if uint64(quantity) > math.MaxInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	q := int64(quantity)
+	if m.Amount > math.MaxInt64/q || m.Amount < math.MinInt64/q {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: m.Amount * q, Currency: m.Currency}, nil
+}
+
+// UnmarshalJSON décode un montant, qui doit être un objet avec un montant entier et une devise.
+// Un simple entier retourne ErrInvalidMoney : l'ancien format n'est accepté que par UnmarshalStored.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	// Le type intermédiaire évite d'appeler UnmarshalJSON récursivement.
+	type money Money
+	if trimmed := strings.TrimSpace(string(data)); trimmed != "null" && !strings.HasPrefix(trimmed, "{") {
+		return ErrInvalidMoney
+	}
+	if err := json.Unmarshal(data, (*money)(m)); err != nil {
+		return ErrInvalidMoney
+	}
+	return nil
+}
+
+// UnmarshalStored décode des données enregistrées par le serveur, comme une commande ou un événement.
+// Les prix enregistrés avant l'ajout des devises sont un simple entier, en unités mineures de LegacyCurrency :
+// refusés dans les requêtes, ils sont convertis ici en montants avant le décodage.
+func UnmarshalStored(data []byte, v any) error {
+	err := json.Unmarshal(data, v)
+	if !errors.Is(err, ErrInvalidMoney) {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	upgraded, err := json.Marshal(legacyPrices(doc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(upgraded, v)
+}
+
+// legacyPrices remplace dans doc les prix donnés comme un simple entier par un montant en LegacyCurrency.
+func legacyPrices(doc any) any {
+	switch doc := doc.(type) {
+	case map[string]any:
+		for key, value := range doc {
+			if amount, ok := value.(json.Number); ok && key == "price" {
+				doc[key] = map[string]any{"amount": amount, "currency": LegacyCurrency}
+				continue
+			}
+			doc[key] = legacyPrices(value)
+		}
+	case []any:
+		for i, value := range doc {
+			doc[i] = legacyPrices(value)
+		}
+	}
+	return doc
+}
+
+// ComputeTotals calcule la devise et le sous-total de la commande à partir de ses articles,
+// puis le total en y ajoutant ses ajustements.
+// Retourne ErrCurrencyMismatch si les montants n'ont pas tous la même devise,
+// et ErrAmountOverflow si un montant dépasse la capacité d'un int64.
+func (o *Order) ComputeTotals() error {
+	currency := o.Currency
+	if len(o.LineItems) > 0 {
+		currency = o.LineItems[0].Price.Currency
+	}
+	if currency == "" {
+		currency = LegacyCurrency
+	}
+
+	subtotal := Money{Currency: currency}
+	for _, item := range o.LineItems {
+		line, err := item.Total()
+		if err != nil {
+			return err
+		}
+		if subtotal, err = subtotal.Add(line); err != nil {
+			return err
+		}
+	}
+
+	total := subtotal
+	for _, adj := range o.Adjustments {
+		var err error
+		if total, err = total.Add(adj.Amount); err != nil {
+			return err
+		}
+	}
+
+	o.Currency = currency
+	o.Subtotal = subtotal
+	o.Total = total
+	return nil
+}
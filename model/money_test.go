@@ -0,0 +1,129 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    Money
+		wantErr error
+	}{
+		{"sum", Money{Amount: 1000, Currency: "EUR"}, Money{Amount: -250, Currency: "EUR"}, Money{Amount: 750, Currency: "EUR"}, nil},
+		{"max", Money{Amount: math.MaxInt64 - 1, Currency: "EUR"}, Money{Amount: 1, Currency: "EUR"}, Money{Amount: math.MaxInt64, Currency: "EUR"}, nil},
+		{"overflow", Money{Amount: math.MaxInt64, Currency: "EUR"}, Money{Amount: 1, Currency: "EUR"}, Money{}, ErrAmountOverflow},
+		{"underflow", Money{Amount: math.MinInt64, Currency: "EUR"}, Money{Amount: -1, Currency: "EUR"}, Money{}, ErrAmountOverflow},
+		{"currency mismatch", Money{Amount: 1, Currency: "EUR"}, Money{Amount: 1, Currency: "USD"}, Money{}, ErrCurrencyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Add() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Add() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        Money
+		quantity uint
+		want     Money
+		wantErr  error
+	}{
+		{"product", Money{Amount: 1999, Currency: "USD"}, 3, Money{Amount: 5997, Currency: "USD"}, nil},
+		{"zero quantity", Money{Amount: math.MaxInt64, Currency: "EUR"}, 0, Money{Currency: "EUR"}, nil},
+		{"negative", Money{Amount: -500, Currency: "EUR"}, 2, Money{Amount: -1000, Currency: "EUR"}, nil},
+		{"overflow", Money{Amount: math.MaxInt64/2 + 1, Currency: "EUR"}, 2, Money{}, ErrAmountOverflow},
+		{"underflow", Money{Amount: math.MinInt64/2 - 1, Currency: "EUR"}, 2, Money{}, ErrAmountOverflow},
+		{"quantity overflow", Money{Amount: 1, Currency: "EUR"}, math.MaxInt64 + 1, Money{}, ErrAmountOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Mul(tt.quantity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Mul() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Mul() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Money
+		wantErr error
+	}{
+		{`{"amount": 1999, "currency": "USD"}`, Money{Amount: 1999, Currency: "USD"}, nil},
+		{`null`, Money{}, nil},
+		// Le simple entier des commandes enregistrées sans devise est refusé hors de UnmarshalStored.
+		{`1000`, Money{}, ErrInvalidMoney},
+		{`"10.00"`, Money{}, ErrInvalidMoney},
+		{`{"amount": 10.5, "currency": "EUR"}`, Money{}, ErrInvalidMoney},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Unmarshal(%s) error = %v, want %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalStoredLegacyPrices(t *testing.T) {
+	data := `{"order_id": 1, "line_items": [
+		{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 2, "price": 1000},
+		{"item_id": "33333333-3333-3333-3333-333333333333", "quantity": 1, "price": {"amount": 250, "currency": "EUR"}}
+	]}`
+
+	var o Order
+	if err := UnmarshalStored([]byte(data), &o); err != nil {
+		t.Fatalf("UnmarshalStored() error = %v", err)
+	}
+
+	want := []Money{{Amount: 1000, Currency: LegacyCurrency}, {Amount: 250, Currency: "EUR"}}
+	if len(o.LineItems) != len(want) {
+		t.Fatalf("UnmarshalStored() line items = %d, want %d", len(o.LineItems), len(want))
+	}
+	for i, item := range o.LineItems {
+		if item.Price != want[i] {
+			t.Errorf("line_items[%d].price = %v, want %v", i, item.Price, want[i])
+		}
+	}
+
+	// Les événements enregistrés contiennent la commande sous "order".
+	var e struct {
+		Order Order `json:"order"`
+	}
+	if err := UnmarshalStored([]byte(`{"order": `+data+`}`), &e); err != nil {
+		t.Fatalf("UnmarshalStored() error = %v", err)
+	}
+	if got := e.Order.LineItems[0].Price; got != want[0] {
+		t.Errorf("order.line_items[0].price = %v, want %v", got, want[0])
+	}
+
+	// Un montant mal formé autrement reste refusé.
+	if err := UnmarshalStored([]byte(`{"line_items": [{"price": "10.00"}]}`), &o); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("UnmarshalStored() error = %v, want %v", err, ErrInvalidMoney)
+	}
+}
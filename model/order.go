@@ -8,17 +8,76 @@ import (
 
 // Order représente une commande.
 type Order struct {
-	OrderID     uint64     `json:"order_id"`     // Identifiant unique de la commande.
-	CustomerID  uuid.UUID  `json:"customer_id"`  // Identifiant unique du client.
-	LineItems   []LineItem `json:"line_items"`   // Liste des articles de la commande.
-	CreatedAt   *time.Time `json:"created_at"`   // Date et heure de création de la commande.
-	ShippedAt   *time.Time `json:"shipped_at"`   // Date et heure d'expédition de la commande.
-	CompletedAt *time.Time `json:"completed_at"` // Date et heure de finalisation de la commande.
+	OrderID     uint64     `json:"order_id"`             // Identifiant unique de la commande.
+	Version     uint64     `json:"version"`              // Version de la commande, incrémentée à chaque mise à jour.
+	Status      Status     `json:"status"`               // État de la commande, voir CurrentStatus pour les anciennes commandes.
+	CustomerID  uuid.UUID  `json:"customer_id"`          // Identifiant unique du client.
+	LineItems   []LineItem `json:"line_items"`           // Liste des articles de la commande.
+	Currency    Currency   `json:"currency"`             // Devise de la commande, commune à tous ses articles.
+	Subtotal    Money      `json:"subtotal"`             // Somme des prix des articles, calculée par le serveur.
+	Total       Money      `json:"total"`                // Sous-total augmenté des ajustements, calculé par le serveur.
+	CreatedAt   *time.Time `json:"created_at"`           // Date et heure de création de la commande.
+	ShippedAt   *time.Time `json:"shipped_at"`           // Date et heure d'expédition complète de la commande.
+	CompletedAt *time.Time `json:"completed_at"`         // Date et heure de finalisation de la commande.
+	CancelledAt *time.Time `json:"cancelled_at"`         // Date et heure d'annulation de la commande.
+	DeletedAt   *time.Time `json:"deleted_at"`           // Date et heure de suppression de la commande, nil si elle est active.
+	DeletedBy   string     `json:"deleted_by,omitempty"` // Auteur de la suppression.
+
+	Cancellation *Cancellation `json:"cancellation,omitempty"`  // Motif de l'annulation, si la commande est annulée.
+	DiscountCode string        `json:"discount_code,omitempty"` // Code promotionnel fourni à la création.
+	Adjustments  []Adjustment  `json:"adjustments,omitempty"`   // Remises, taxes et frais ajoutés au sous-total.
+	Shipments    []Shipment    `json:"shipments,omitempty"`     // Colis expédiés, dans l'ordre de leur enregistrement.
+	Returns      []Return      `json:"returns,omitempty"`       // Demandes de retour, dans l'ordre de leur enregistrement.
+	Notes        string        `json:"notes,omitempty"`         // Notes libres sur la commande, modifiables à tout moment.
+
+	ShippingAddress *Address `json:"shipping_address,omitempty"` // Adresse de livraison, modifiable jusqu'à l'expédition.
+	BillingAddress  *Address `json:"billing_address,omitempty"`  // Adresse de facturation, modifiable jusqu'à l'expédition.
 }
 
 // LineItem représente un article d'une commande.
 type LineItem struct {
 	ItemID   uuid.UUID `json:"item_id"`  // Identifiant unique de l'article.
 	Quantity uint      `json:"quantity"` // Quantité commandée de l'article.
-	Price    uint      `json:"price"`    // Prix unitaire de l'article.
+	Price    Money     `json:"price"`    // Prix unitaire de l'article.
+}
+
+// Total retourne le prix de la ligne : prix unitaire multiplié par la quantité.
+func (li LineItem) Total() (Money, error) {
+	return li.Price.Mul(li.Quantity)
+}
+
+// Cancellation décrit l'annulation d'une commande.
+type Cancellation struct {
+	Reason CancelReason `json:"reason"`         // Motif de l'annulation.
+	Note   string       `json:"note,omitempty"` // Précision libre sur l'annulation.
+}
+
+// CancelReason est le motif codifié de l'annulation d'une commande.
+type CancelReason string
+
+// Motifs d'annulation possibles.
+const (
+	CancelCustomerRequest CancelReason = "customer_request" // Demande du client.
+	CancelPaymentFailed   CancelReason = "payment_failed"   // Paiement refusé ou non reçu.
+	CancelOutOfStock      CancelReason = "out_of_stock"     // Article indisponible.
+	CancelFraudSuspected  CancelReason = "fraud_suspected"  // Suspicion de fraude.
+	CancelDuplicateOrder  CancelReason = "duplicate_order"  // Commande passée en double.
+	CancelOther           CancelReason = "other"            // Autre motif, précisé dans la note.
+)
+
+// CancelReasons retourne tous les motifs d'annulation possibles.
+func CancelReasons() []CancelReason {
+	return []CancelReason{
+		CancelCustomerRequest, CancelPaymentFailed, CancelOutOfStock, CancelFraudSuspected, CancelDuplicateOrder, CancelOther,
+	}
+}
+
+// Valid indique si le motif fait partie des motifs d'annulation possibles.
+func (r CancelReason) Valid() bool {
+	for _, reason := range CancelReasons() {
+		if r == reason {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,296 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Return représente une demande de retour d'articles d'une commande livrée, jusqu'à son remboursement.
+type Return struct {
+	ReturnID    uuid.UUID    `json:"return_id"`             // Identifiant unique du retour.
+	Status      ReturnStatus `json:"status"`                // État du retour.
+	Reason      ReturnReason `json:"reason"`                // Motif du retour.
+	Note        string       `json:"note,omitempty"`        // Précision libre du client sur le retour.
+	LineItems   []ReturnLine `json:"line_items"`            // Articles retournés et leurs quantités.
+	Decision    string       `json:"decision,omitempty"`    // Explication de l'approbation ou du refus.
+	Refund      *Refund      `json:"refund,omitempty"`      // Remboursement, une fois effectué.
+	RequestedAt time.Time    `json:"requested_at"`          // Date et heure de la demande.
+	DecidedAt   *time.Time   `json:"decided_at,omitempty"`  // Date et heure de l'approbation ou du refus.
+	ReceivedAt  *time.Time   `json:"received_at,omitempty"` // Date et heure de réception des articles retournés.
+	RefundedAt  *time.Time   `json:"refunded_at,omitempty"` // Date et heure du remboursement.
+}
+
+// ReturnLine représente la quantité d'un article de la commande retournée.
+type ReturnLine struct {
+	ItemID   uuid.UUID `json:"item_id"`  // Identifiant de l'article, qui doit faire partie de la commande.
+	Quantity uint      `json:"quantity"` // Quantité retournée de l'article.
+}
+
+// Refund représente le remboursement d'un retour.
+type Refund struct {
+	Amount    Money  `json:"amount"`              // Montant remboursé, dans la devise de la commande.
+	Reference string `json:"reference,omitempty"` // Référence du remboursement chez le prestataire de paiement.
+}
+
+// ReturnReason est le motif codifié d'un retour.
+type ReturnReason string
+
+// Motifs de retour possibles.
+const (
+	ReturnDamaged        ReturnReason = "damaged"          // Article endommagé.
+	ReturnWrongItem      ReturnReason = "wrong_item"       // Article différent de celui commandé.
+	ReturnNotAsDescribed ReturnReason = "not_as_described" // Article non conforme à sa description.
+	ReturnNoLongerNeeded ReturnReason = "no_longer_needed" // Rétractation du client.
+	ReturnOther          ReturnReason = "other"            // Autre motif, précisé dans la note.
+)
+
+// ReturnReasons retourne tous les motifs de retour possibles.
+func ReturnReasons() []ReturnReason {
+	return []ReturnReason{ReturnDamaged, ReturnWrongItem, ReturnNotAsDescribed, ReturnNoLongerNeeded, ReturnOther}
+}
+
+// Valid indique si le motif fait partie des motifs de retour possibles.
+func (r ReturnReason) Valid() bool {
+	for _, reason := range ReturnReasons() {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnStatus est l'état d'un retour.
+type ReturnStatus string
+
+// États possibles d'un retour.
+const (
+	ReturnRequested ReturnStatus = "requested" // Retour demandé, en attente de décision.
+	ReturnApproved  ReturnStatus = "approved"  // Retour accepté, en attente des articles.
+	ReturnRejected  ReturnStatus = "rejected"  // Retour refusé.
+	ReturnReceived  ReturnStatus = "received"  // Articles retournés reçus, en attente de remboursement.
+	ReturnRefunded  ReturnStatus = "refunded"  // Retour remboursé.
+)
+
+// returnTransitions liste, pour chaque état d'un retour, l'état qu'il peut atteindre ensuite.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnRefunded},
+	ReturnRejected:  {},
+	ReturnRefunded:  {},
+}
+
+// Erreurs retournées par les opérations sur les retours.
+var (
+	ErrNotReturnable           = errors.New("order is not returnable")
+	ErrReturnNotFound          = errors.New("return not found")
+	ErrInvalidReturnTransition = errors.New("invalid return status transition")
+	ErrOverReturn              = errors.New("return exceeds returnable quantity")
+	ErrRefundExceedsPaid       = errors.New("refunds exceed the amount paid")
+	ErrRefundExceedsReturn     = errors.New("refund exceeds the amount paid for the returned items")
+	ErrInvalidRefund           = errors.New("refund amount must be positive")
+)
+
+// Returnable indique si la commande accepte des demandes de retour : seules les commandes livrées
+// ou finalisées le peuvent. Sinon, l'erreur correspond à ErrNotReturnable.
+func (o Order) Returnable() error {
+	switch status := o.CurrentStatus(); status {
+	case StatusDelivered, StatusCompleted:
+		return nil
+	default:
+		return fmt.Errorf("%w: order is %s", ErrNotReturnable, status)
+	}
+}
+
+// ReturnableQuantities retourne, pour chaque article, la quantité qui peut encore faire l'objet d'un retour :
+// la quantité commandée, moins celles des retours qui n'ont pas été refusés.
+func (o Order) ReturnableQuantities() map[uuid.UUID]uint {
+	returnable := make(map[uuid.UUID]uint, len(o.LineItems))
+	for _, item := range o.LineItems {
+		returnable[item.ItemID] = item.Quantity
+	}
+	for _, ret := range o.Returns {
+		if ret.Status == ReturnRejected {
+			continue
+		}
+		for _, line := range ret.LineItems {
+			if returnable[line.ItemID] >= line.Quantity {
+				returnable[line.ItemID] -= line.Quantity
+			} else {
+				returnable[line.ItemID] = 0
+			}
+		}
+	}
+	return returnable
+}
+
+// Refunded retourne la somme des remboursements de la commande.
+func (o Order) Refunded() (Money, error) {
+	refunded := Money{Currency: o.Total.Currency}
+	for _, ret := range o.Returns {
+		if ret.Refund == nil {
+			continue
+		}
+		var err error
+		if refunded, err = refunded.Add(ret.Refund.Amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return refunded, nil
+}
+
+// Refundable retourne le montant qui peut encore être remboursé : le total payé, moins les remboursements effectués.
+func (o Order) Refundable() (Money, error) {
+	refunded, err := o.Refunded()
+	if err != nil {
+		return Money{}, err
+	}
+	return o.Total.Add(Money{Amount: -refunded.Amount, Currency: refunded.Currency})
+}
+
+// RequestReturn ajoute une demande de retour à la commande.
+// Retourne l'erreur de Returnable si la commande n'accepte pas de retour, et ErrUnknownItem ou ErrOverReturn
+// si un article ne fait pas partie de la commande ou dépasse la quantité qui peut encore être retournée.
+func (o *Order) RequestReturn(ret Return) error {
+	if err := o.Returnable(); err != nil {
+		return err
+	}
+
+	returnable := o.ReturnableQuantities()
+	for _, line := range ret.LineItems {
+		left, exists := returnable[line.ItemID]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrUnknownItem, line.ItemID)
+		}
+		if line.Quantity > left {
+			return fmt.Errorf("%w: %d of item %s left to return", ErrOverReturn, left, line.ItemID)
+		}
+		returnable[line.ItemID] -= line.Quantity
+	}
+
+	ret.Status = ReturnRequested
+	o.Returns = append(o.Returns, ret)
+
+	return nil
+}
+
+// FindReturn retourne le retour d'ID id de la commande, ou ErrReturnNotFound.
+func (o Order) FindReturn(id uuid.UUID) (Return, error) {
+	ret, err := o.findReturn(id)
+	if err != nil {
+		return Return{}, err
+	}
+	return *ret, nil
+}
+
+// findReturn retourne un pointeur vers le retour d'ID id de la commande, ou ErrReturnNotFound.
+func (o *Order) findReturn(id uuid.UUID) (*Return, error) {
+	for i := range o.Returns {
+		if o.Returns[i].ReturnID == id {
+			return &o.Returns[i], nil
+		}
+	}
+	return nil, ErrReturnNotFound
+}
+
+// moveReturn fait passer le retour d'ID id à l'état to, en respectant la table des transitions des retours.
+func (o *Order) moveReturn(id uuid.UUID, to ReturnStatus) (*Return, error) {
+	ret, err := o.findReturn(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ret.canMove(to); err != nil {
+		return nil, err
+	}
+
+	ret.Status = to
+	return ret, nil
+}
+
+// canMove vérifie que le retour peut passer à l'état to. Sinon, l'erreur correspond à ErrInvalidReturnTransition.
+func (ret Return) canMove(to ReturnStatus) error {
+	for _, next := range returnTransitions[ret.Status] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: return cannot go from %s to %s", ErrInvalidReturnTransition, ret.Status, to)
+}
+
+// ApproveReturn accepte le retour d'ID id, en attente de décision, avec une explication facultative.
+func (o *Order) ApproveReturn(id uuid.UUID, decision string, at time.Time) error {
+	ret, err := o.moveReturn(id, ReturnApproved)
+	if err != nil {
+		return err
+	}
+	ret.Decision = decision
+	ret.DecidedAt = &at
+	return nil
+}
+
+// RejectReturn refuse le retour d'ID id, en attente de décision. Ses quantités peuvent de nouveau être retournées.
+func (o *Order) RejectReturn(id uuid.UUID, decision string, at time.Time) error {
+	ret, err := o.moveReturn(id, ReturnRejected)
+	if err != nil {
+		return err
+	}
+	ret.Decision = decision
+	ret.DecidedAt = &at
+	return nil
+}
+
+// ReceiveReturn enregistre la réception des articles du retour d'ID id, approuvé.
+func (o *Order) ReceiveReturn(id uuid.UUID, at time.Time) error {
+	ret, err := o.moveReturn(id, ReturnReceived)
+	if err != nil {
+		return err
+	}
+	ret.ReceivedAt = &at
+	return nil
+}
+
+// RefundReturn rembourse le retour d'ID id, dont les articles ont été reçus.
+// Le remboursement ne peut pas dépasser limit, le montant maximal pour les articles du retour
+// (voir pricing.MaxRefund) : sinon, l'erreur correspond à ErrRefundExceedsReturn. La somme des remboursements
+// ne peut jamais dépasser le total payé : sinon, l'erreur correspond à ErrRefundExceedsPaid.
+// Dans les deux cas, la commande n'est pas modifiée. Une commande entièrement remboursée passe à l'état refunded.
+func (o *Order) RefundReturn(id uuid.UUID, refund Refund, limit Money, at time.Time) error {
+	ret, err := o.findReturn(id)
+	if err != nil {
+		return err
+	}
+	if err := ret.canMove(ReturnRefunded); err != nil {
+		return err
+	}
+
+	// Le montant est vérifié avant tout changement.
+	refundable, err := o.Refundable()
+	if err != nil {
+		return err
+	}
+	if refund.Amount.Currency != refundable.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, refund.Amount.Currency, refundable.Currency)
+	}
+	if refund.Amount.Amount <= 0 {
+		return ErrInvalidRefund
+	}
+	if refund.Amount.Amount > refundable.Amount {
+		return fmt.Errorf("%w: %d %s left to refund", ErrRefundExceedsPaid, refundable.Amount, refundable.Currency)
+	}
+	if refund.Amount.Currency != limit.Currency || refund.Amount.Amount > limit.Amount {
+		return fmt.Errorf("%w: at most %d %s", ErrRefundExceedsReturn, limit.Amount, limit.Currency)
+	}
+
+	ret.Status = ReturnRefunded
+	ret.Refund = &refund
+	ret.RefundedAt = &at
+
+	if refund.Amount.Amount == refundable.Amount && o.CurrentStatus().CanTransition(StatusRefunded) {
+		o.setStatus(StatusRefunded, at)
+	}
+
+	return nil
+}
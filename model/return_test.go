@@ -0,0 +1,74 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRefundReturnNeverExceedsPaid(t *testing.T) {
+	item := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	o := Order{
+		Status:    StatusDelivered,
+		LineItems: []LineItem{{ItemID: item, Quantity: 2, Price: Money{Amount: 1000, Currency: "EUR"}}},
+		Total:     Money{Amount: 2500, Currency: "EUR"},
+	}
+	// Chaque retour peut rembourser son article et les frais de livraison.
+	limit := Money{Amount: 1500, Currency: "EUR"}
+	now := time.Now().UTC()
+
+	// Deux retours reçus d'un article chacun.
+	var ids []uuid.UUID
+	for i := 0; i < 2; i++ {
+		ret := Return{ReturnID: uuid.New(), Reason: ReturnDamaged, LineItems: []ReturnLine{{ItemID: item, Quantity: 1}}}
+		if err := o.RequestReturn(ret); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.ApproveReturn(ret.ReturnID, "", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.ReceiveReturn(ret.ReturnID, now); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, ret.ReturnID)
+	}
+
+	if err := o.RefundReturn(ids[0], Refund{Amount: Money{Amount: 1500, Currency: "EUR"}}, limit, now); err != nil {
+		t.Fatalf("RefundReturn() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		amount Money
+		limit  Money
+		want   error
+	}{
+		{"more than refundable", Money{Amount: 1001, Currency: "EUR"}, limit, ErrRefundExceedsPaid},
+		// Un montant dans le reste à rembourser est refusé s'il dépasse celui des articles du retour.
+		{"more than the return", Money{Amount: 600, Currency: "EUR"}, Money{Amount: 500, Currency: "EUR"},
+			ErrRefundExceedsReturn},
+		{"zero", Money{Amount: 0, Currency: "EUR"}, limit, ErrInvalidRefund},
+		{"other currency", Money{Amount: 100, Currency: "USD"}, limit, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := o.Returns[1]
+			if err := o.RefundReturn(ids[1], Refund{Amount: tt.amount}, tt.limit, now); !errors.Is(err, tt.want) {
+				t.Errorf("RefundReturn(%v) error = %v, want %v", tt.amount, err, tt.want)
+			}
+			if o.Returns[1].Status != before.Status || o.Returns[1].Refund != nil {
+				t.Errorf("return after a refused refund = %+v", o.Returns[1])
+			}
+		})
+	}
+
+	// Le reste exact est accepté, et la commande entièrement remboursée passe au statut refunded.
+	if err := o.RefundReturn(ids[1], Refund{Amount: Money{Amount: 1000, Currency: "EUR"}}, limit, now); err != nil {
+		t.Fatalf("RefundReturn() of the rest error = %v", err)
+	}
+	if refundable, _ := o.Refundable(); refundable.Amount != 0 || o.Status != StatusRefunded {
+		t.Errorf("after refunding the total: refundable %d, status %s, want 0, refunded", refundable.Amount, o.Status)
+	}
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Shipment représente un colis expédié pour une commande, contenant tout ou partie de ses articles.
+type Shipment struct {
+	ShipmentID     uuid.UUID      `json:"shipment_id"`     // Identifiant unique du colis.
+	Carrier        string         `json:"carrier"`         // Transporteur du colis.
+	TrackingNumber string         `json:"tracking_number"` // Numéro de suivi chez le transporteur.
+	LineItems      []ShipmentLine `json:"line_items"`      // Articles du colis et leurs quantités.
+	ShippedAt      time.Time      `json:"shipped_at"`      // Date et heure de remise du colis au transporteur.
+	CreatedAt      time.Time      `json:"created_at"`      // Date et heure d'enregistrement du colis.
+}
+
+// ShipmentLine représente la quantité d'un article de la commande contenue dans un colis.
+type ShipmentLine struct {
+	ItemID   uuid.UUID `json:"item_id"`  // Identifiant de l'article, qui doit faire partie de la commande.
+	Quantity uint      `json:"quantity"` // Quantité de l'article dans le colis.
+}
+
+// Erreurs retournées lors de l'ajout d'un colis.
+var (
+	ErrUnknownItem  = errors.New("item not in order")
+	ErrOverShipment = errors.New("shipment exceeds ordered quantity")
+)
+
+// ShippedQuantities retourne, pour chaque article, la quantité déjà expédiée dans les colis de la commande.
+func (o Order) ShippedQuantities() map[uuid.UUID]uint {
+	shipped := make(map[uuid.UUID]uint, len(o.LineItems))
+	for _, s := range o.Shipments {
+		for _, line := range s.LineItems {
+			shipped[line.ItemID] += line.Quantity
+		}
+	}
+	return shipped
+}
+
+// FullyShipped indique si les colis de la commande couvrent toutes les quantités commandées.
+func (o Order) FullyShipped() bool {
+	shipped := o.ShippedQuantities()
+	for _, item := range o.LineItems {
+		if shipped[item.ItemID] < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// Shippable indique si la commande peut recevoir un colis : elle doit être en attente, payée ou partiellement expédiée.
+// Sinon, une *TransitionError vers shipped est retournée, qui correspond par exemple à ErrAlreadyShipped.
+func (o Order) Shippable() error {
+	from := o.CurrentStatus()
+	if from != StatusPartiallyShipped && !from.CanTransition(StatusPartiallyShipped) {
+		return &TransitionError{From: from, To: StatusShipped}
+	}
+	return nil
+}
+
+// AddShipment ajoute un colis à la commande, puis déduit son statut des quantités expédiées :
+// partially_shipped tant qu'il reste des articles à expédier, shipped lorsque tout est expédié.
+// Une commande qui ne peut pas recevoir de colis retourne l'erreur de Shippable. Un article absent de la commande
+// ou une quantité supérieure au reste à expédier retourne ErrUnknownItem ou ErrOverShipment, sans modifier la commande.
+func (o *Order) AddShipment(s Shipment) error {
+	if err := o.Shippable(); err != nil {
+		return err
+	}
+
+	ordered := make(map[uuid.UUID]uint, len(o.LineItems))
+	for _, item := range o.LineItems {
+		ordered[item.ItemID] = item.Quantity
+	}
+	shipped := o.ShippedQuantities()
+	for _, line := range s.LineItems {
+		quantity, exists := ordered[line.ItemID]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrUnknownItem, line.ItemID)
+		}
+		if line.Quantity > quantity-shipped[line.ItemID] {
+			return fmt.Errorf("%w: %d of item %s left to ship", ErrOverShipment, quantity-shipped[line.ItemID], line.ItemID)
+		}
+		shipped[line.ItemID] += line.Quantity
+	}
+
+	o.Shipments = append(o.Shipments, s)
+
+	// La date d'expédition de la commande est celle du colis qui la complète.
+	to := StatusPartiallyShipped
+	if o.FullyShipped() {
+		to = StatusShipped
+	}
+	if to != o.CurrentStatus() {
+		o.setStatus(to, s.ShippedAt)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAddShipment(t *testing.T) {
+	item := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	o := Order{
+		Status:    StatusPaid,
+		LineItems: []LineItem{{ItemID: item, Quantity: 3, Price: Money{Amount: 1000, Currency: "EUR"}}},
+	}
+	shipment := func(quantity uint, at time.Time) Shipment {
+		return Shipment{ShipmentID: uuid.New(), LineItems: []ShipmentLine{{ItemID: item, Quantity: quantity}}, ShippedAt: at}
+	}
+	first := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	last := first.Add(48 * time.Hour)
+
+	if err := o.AddShipment(shipment(1, first)); err != nil {
+		t.Fatalf("AddShipment() error = %v", err)
+	}
+	if o.CurrentStatus() != StatusPartiallyShipped || o.ShippedAt != nil {
+		t.Errorf("after a partial shipment: status %s, shipped_at %v, want partially_shipped, nil", o.CurrentStatus(), o.ShippedAt)
+	}
+
+	// Plus que le reste à expédier, ou un article absent, ne modifie pas la commande.
+	if err := o.AddShipment(shipment(3, last)); !errors.Is(err, ErrOverShipment) {
+		t.Errorf("AddShipment() error = %v, want %v", err, ErrOverShipment)
+	}
+	unknown := Shipment{LineItems: []ShipmentLine{{ItemID: uuid.New(), Quantity: 1}}}
+	if err := o.AddShipment(unknown); !errors.Is(err, ErrUnknownItem) {
+		t.Errorf("AddShipment() error = %v, want %v", err, ErrUnknownItem)
+	}
+	if len(o.Shipments) != 1 {
+		t.Errorf("refused shipments were added: %d shipments, want 1", len(o.Shipments))
+	}
+
+	if err := o.AddShipment(shipment(2, last)); err != nil {
+		t.Fatalf("AddShipment() error = %v", err)
+	}
+	if o.CurrentStatus() != StatusShipped || o.ShippedAt == nil || !o.ShippedAt.Equal(last) {
+		t.Errorf("after the last shipment: status %s, shipped_at %v, want shipped at %v", o.CurrentStatus(), o.ShippedAt, last)
+	}
+
+	if err := o.AddShipment(shipment(1, last)); !errors.Is(err, ErrAlreadyShipped) {
+		t.Errorf("AddShipment() on a shipped order error = %v, want %v", err, ErrAlreadyShipped)
+	}
+}
@@ -0,0 +1,177 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status est l'état d'une commande dans son cycle de vie.
+type Status string
+
+// États possibles d'une commande.
+const (
+	StatusPending          Status = "pending"           // Commande créée, en attente de paiement ou d'expédition.
+	StatusPaid             Status = "paid"              // Commande payée, en attente d'expédition.
+	StatusPartiallyShipped Status = "partially_shipped" // Une partie des articles expédiée, voir Order.AddShipment.
+	StatusShipped          Status = "shipped"           // Commande expédiée.
+	StatusDelivered        Status = "delivered"         // Commande livrée au client.
+	StatusCompleted        Status = "completed"         // Commande finalisée.
+	StatusCancelled        Status = "cancelled"         // Commande annulée avant expédition.
+	StatusRefunded         Status = "refunded"          // Commande remboursée.
+)
+
+// transitions liste, pour chaque état, les états qu'une commande peut atteindre ensuite.
+// Une commande en attente peut être expédiée sans passer par le paiement, comme avant l'ajout de ce dernier.
+// Une commande partiellement expédiée ne peut plus être annulée : elle attend ses derniers colis.
+var transitions = map[Status][]Status{
+	StatusPending:          {StatusPaid, StatusPartiallyShipped, StatusShipped, StatusCancelled},
+	StatusPaid:             {StatusPartiallyShipped, StatusShipped, StatusCancelled, StatusRefunded},
+	StatusPartiallyShipped: {StatusShipped},
+	StatusShipped:          {StatusDelivered, StatusCompleted},
+	StatusDelivered:        {StatusCompleted, StatusRefunded},
+	StatusCompleted:        {StatusRefunded},
+	StatusCancelled:        {},
+	StatusRefunded:         {},
+}
+
+// Statuses retourne tous les états possibles, dans l'ordre du cycle de vie.
+func Statuses() []Status {
+	return []Status{
+		StatusPending, StatusPaid, StatusPartiallyShipped, StatusShipped, StatusDelivered, StatusCompleted,
+		StatusCancelled, StatusRefunded,
+	}
+}
+
+// Valid indique si l'état fait partie du cycle de vie d'une commande.
+func (s Status) Valid() bool {
+	_, exists := transitions[s]
+	return exists
+}
+
+// CanTransition indique si une commande peut passer de l'état s à l'état to.
+func (s Status) CanTransition(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Erreurs retournées lorsqu'un changement d'état n'est pas permis.
+// Une TransitionError correspond à ErrInvalidTransition, et à l'une des erreurs plus précises lorsqu'elle s'applique.
+var (
+	ErrUnknownStatus     = errors.New("unknown order status")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrAlreadyShipped    = errors.New("order already shipped")
+	ErrAlreadyCompleted  = errors.New("order already completed")
+	ErrNotShipped        = errors.New("order not shipped yet")
+	ErrAlreadyCancelled  = errors.New("order already cancelled")
+	ErrNotRequestable    = errors.New("order status cannot be requested directly")
+)
+
+// managedStatuses associe aux états qui ne peuvent pas être demandés par Transition l'opération qui les atteint.
+var managedStatuses = map[Status]string{
+	StatusPartiallyShipped: "partially_shipped is derived from the order shipments",
+	StatusShipped:          "shipped is derived from the order shipments",
+	StatusCancelled:        "cancelling an order requires a reason",
+	StatusRefunded:         "refunded is set when refunds cover the order total",
+}
+
+// TransitionError décrit un changement d'état refusé par la table des transitions.
+type TransitionError struct {
+	From Status // État actuel de la commande.
+	To   Status // État demandé.
+}
+
+// Error décrit la transition refusée.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order cannot go from %s to %s", e.From, e.To)
+}
+
+// Is permet de comparer une TransitionError aux erreurs de transition du package avec errors.Is.
+func (e *TransitionError) Is(target error) bool {
+	switch target {
+	case ErrInvalidTransition:
+		return true
+	case ErrAlreadyShipped:
+		shipped := e.From == StatusShipped || e.From == StatusDelivered || e.From == StatusCompleted
+		return (e.To == StatusShipped && shipped) ||
+			(e.To == StatusCancelled && (shipped || e.From == StatusPartiallyShipped))
+	case ErrAlreadyCompleted:
+		return e.To == StatusCompleted && e.From == StatusCompleted
+	case ErrNotShipped:
+		return e.To == StatusCompleted && (e.From == StatusPending || e.From == StatusPaid || e.From == StatusPartiallyShipped)
+	case ErrAlreadyCancelled:
+		return e.From == StatusCancelled
+	default:
+		return false
+	}
+}
+
+// CurrentStatus retourne l'état de la commande.
+// Les commandes enregistrées avant l'ajout de Status en sont dépourvues : leur état est déduit de leurs dates.
+func (o Order) CurrentStatus() Status {
+	switch {
+	case o.Status != "":
+		return o.Status
+	case o.CompletedAt != nil:
+		return StatusCompleted
+	case o.ShippedAt != nil:
+		return StatusShipped
+	default:
+		return StatusPending
+	}
+}
+
+// Transition fait passer la commande à l'état to à la date donnée, en respectant la table des transitions.
+// Retourne ErrUnknownStatus si l'état est inconnu, ou une *TransitionError si la transition n'est pas permise.
+// Les états de managedStatuses ne peuvent pas être demandés et retournent une erreur correspondant
+// à ErrNotRequestable : partially_shipped et shipped ne sont atteints que par AddShipment, selon les quantités
+// expédiées, cancelled que par Cancel et refunded que par RefundReturn, lorsque les remboursements couvrent
+// le total de la commande.
+func (o *Order) Transition(to Status, at time.Time) error {
+	if !to.Valid() {
+		return ErrUnknownStatus
+	}
+
+	from := o.CurrentStatus()
+	if !from.CanTransition(to) {
+		return &TransitionError{From: from, To: to}
+	}
+	if reason, managed := managedStatuses[to]; managed {
+		return fmt.Errorf("%w: %s", ErrNotRequestable, reason)
+	}
+
+	o.setStatus(to, at)
+
+	return nil
+}
+
+// setStatus fait passer la commande à l'état to à la date donnée, sans vérifier la table des transitions.
+func (o *Order) setStatus(to Status, at time.Time) {
+	// Les dates d'expédition et de finalisation sont conservées pour l'historique et les index par date.
+	switch to {
+	case StatusShipped:
+		o.ShippedAt = &at
+	case StatusCompleted:
+		o.CompletedAt = &at
+	case StatusCancelled:
+		o.CancelledAt = &at
+	}
+	o.Status = to
+}
+
+// Cancel annule la commande à la date donnée avec le motif fourni, seul moyen d'atteindre l'état cancelled.
+// Seule une commande pas encore expédiée peut être annulée : sinon, l'erreur correspond à ErrAlreadyShipped.
+func (o *Order) Cancel(c Cancellation, at time.Time) error {
+	if from := o.CurrentStatus(); !from.CanTransition(StatusCancelled) {
+		return &TransitionError{From: from, To: StatusCancelled}
+	}
+
+	o.setStatus(StatusCancelled, at)
+	o.Cancellation = &c
+
+	return nil
+}
@@ -0,0 +1,403 @@
+// Package patch applique à un document JSON un patch au format JSON Merge Patch (RFC 7396)
+// ou JSON Patch (RFC 6902). Les documents sont manipulés sous leur forme décodée par Decode :
+// objets map[string]any, tableaux []any et nombres json.Number, pour ne perdre aucune précision.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Types de médias des deux formats de patch.
+const (
+	MergePatchType = "application/merge-patch+json" // JSON Merge Patch (RFC 7396).
+	JSONPatchType  = "application/json-patch+json"  // JSON Patch (RFC 6902).
+)
+
+// Erreurs retournées lors du décodage ou de l'application d'un patch.
+var (
+	ErrInvalidPatch = errors.New("invalid patch document")
+	ErrConflict     = errors.New("patch does not apply to the document")
+	ErrTestFailed   = errors.New("patch test operation failed")
+)
+
+// Operation est une opération d'un JSON Patch.
+type Operation struct {
+	Op    string          `json:"op"`              // add, remove, replace, move, copy ou test.
+	Path  string          `json:"path"`            // Pointeur JSON (RFC 6901) de la valeur visée.
+	From  string          `json:"from,omitempty"`  // Pointeur JSON de la valeur source, pour move et copy.
+	Value json.RawMessage `json:"value,omitempty"` // Valeur ajoutée, remplaçante ou attendue, nil si absente.
+}
+
+// Decode décode un document JSON en conservant les nombres sous forme de json.Number.
+// Un document mal formé ou suivi d'autres données retourne une erreur correspondant à ErrInvalidPatch.
+func Decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after the document", ErrInvalidPatch)
+	}
+	return doc, nil
+}
+
+// Merge applique le merge patch p au document target et retourne le résultat, selon l'algorithme de la RFC 7396 :
+// un objet est fusionné membre par membre, un membre null est supprimé, toute autre valeur remplace la cible.
+// target peut être modifié.
+func Merge(target, p any) any {
+	fields, ok := p.(map[string]any)
+	if !ok {
+		return p
+	}
+
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+		} else {
+			doc[name] = Merge(doc[name], value)
+		}
+	}
+	return doc
+}
+
+// DecodeOperations décode et vérifie un JSON Patch : un tableau d'opérations connues, avec des pointeurs valides
+// et les membres requis par chacune. Sinon, l'erreur correspond à ErrInvalidPatch.
+func DecodeOperations(data []byte) ([]Operation, error) {
+	var ops []Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if ops == nil {
+		return nil, fmt.Errorf("%w: document must be an array of operations", ErrInvalidPatch)
+	}
+
+	for i, op := range ops {
+		if err := op.check(); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return ops, nil
+}
+
+// check vérifie que l'opération est complète et que ses pointeurs sont valides.
+func (op Operation) check() error {
+	if _, err := parsePointer(op.Path); err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%s requires a value", op.Op)
+		}
+	case "remove":
+		if op.Path == "" {
+			return errors.New("remove cannot target the whole document")
+		}
+	case "move", "copy":
+		if _, err := parsePointer(op.From); err != nil {
+			return err
+		}
+		// Une valeur ne peut pas être déplacée dans l'un de ses propres descendants.
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("cannot move %q into its own child %q", op.From, op.Path)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	return nil
+}
+
+// Apply applique les opérations ops, dans l'ordre, au document doc et retourne le résultat.
+// Une opération qui vise une valeur absente retourne une erreur correspondant à ErrConflict,
+// un test qui échoue une erreur correspondant à ErrTestFailed. doc peut être modifié même en cas d'erreur.
+func Apply(doc any, ops []Operation) (any, error) {
+	for i, op := range ops {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// apply applique l'opération au document doc, déjà vérifiée par check.
+func (op Operation) apply(doc any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := Decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		value, err := Decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		var value any
+		if op.Op == "move" {
+			doc, value, err = remove(doc, from)
+		} else {
+			value, err = get(doc, from)
+			value = clone(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "test":
+		expected, err := Decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(actual, expected) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer découpe un pointeur JSON (RFC 6901) en jetons : "" désigne tout le document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~1 est décodé avant ~0, pour que ~01 donne ~1 et non /.
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get retourne la valeur désignée par path dans doc.
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("%w: member %q not found", ErrConflict, token)
+			}
+			doc = value
+		case []any:
+			i, err := index(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrConflict, token)
+		}
+	}
+	return doc, nil
+}
+
+// add ajoute value à l'emplacement path de doc et retourne le document modifié. Dans un tableau, la valeur est
+// insérée avant l'élément désigné, ou à la fin pour l'indice "-" ; dans un objet, elle remplace un membre existant.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			node[token] = value
+			return node, nil
+		}
+		child, exists := node[token]
+		if !exists {
+			return nil, fmt.Errorf("%w: member %q not found", ErrConflict, token)
+		}
+		child, err := add(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []any:
+		if len(rest) == 0 {
+			i := len(node)
+			if token != "-" {
+				var err error
+				if i, err = index(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		i, err := index(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		if node[i], err = add(node[i], rest, value); err != nil {
+			return nil, err
+		}
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrConflict, token)
+	}
+}
+
+// remove retire la valeur désignée par path de doc et retourne le document modifié ainsi que la valeur retirée.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, exists := node[token]
+		if !exists {
+			return nil, nil, fmt.Errorf("%w: member %q not found", ErrConflict, token)
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := remove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []any:
+		i, err := index(token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(node[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[i] = child
+		return node, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %q is not inside an object or array", ErrConflict, token)
+	}
+}
+
+// index convertit le jeton token en indice de tableau compris entre 0 et max.
+// Seuls les entiers décimaux sans zéro initial sont acceptés.
+func index(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrConflict, token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > max {
+		return 0, fmt.Errorf("%w: array index %s out of range", ErrConflict, token)
+	}
+	return i, nil
+}
+
+// clone retourne une copie profonde de la valeur décodée v.
+func clone(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(node))
+		for name, value := range node {
+			c[name] = clone(value)
+		}
+		return c
+	case []any:
+		c := make([]any, len(node))
+		for i, value := range node {
+			c[i] = clone(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// Equal indique si deux valeurs décodées sont égales au sens JSON : les nombres sont comparés par leur valeur,
+// les objets sans tenir compte de l'ordre de leurs membres.
+func Equal(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for name, value := range a {
+			other, exists := b[name]
+			if !exists || !Equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Rat).SetString(a.String())
+		y, okB := new(big.Rat).SetString(b.String())
+		return okA && okB && x.Cmp(y) == 0
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,185 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+// mustDecode décode le document JSON data, ou arrête le test.
+func mustDecode(t *testing.T, data string) any {
+	t.Helper()
+
+	doc, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode(%s) error = %v", data, err)
+	}
+	return doc
+}
+
+func TestMerge(t *testing.T) {
+	// Exemples de l'annexe A de la RFC 7396.
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target+" "+tt.patch, func(t *testing.T) {
+			got := Merge(mustDecode(t, tt.target), mustDecode(t, tt.patch))
+			if want := mustDecode(t, tt.want); !Equal(got, want) {
+				t.Errorf("Merge() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		ops  string
+		want string
+	}{
+		{"add member", `{"a":1}`, `[{"op":"add","path":"/b","value":2}]`, `{"a":1,"b":2}`},
+		{"add replaces member", `{"a":1}`, `[{"op":"add","path":"/a","value":[1]}]`, `{"a":[1]}`},
+		{"add inserts before index", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{"add at array length", `{"a":[1]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2]}`},
+		{"add appends with -", `{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":{"b":3}}]`, `{"a":[1,2,{"b":3}]}`},
+		{"add whole document", `{"a":1}`, `[{"op":"add","path":"","value":[]}]`, `[]`},
+		{"remove member", `{"a":1,"b":2}`, `[{"op":"remove","path":"/a"}]`, `{"b":2}`},
+		{"remove array element", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1,3]}`},
+		{"replace nested", `{"a":{"b":[1,{"c":1}]}}`, `[{"op":"replace","path":"/a/b/1/c","value":"x"}]`,
+			`{"a":{"b":[1,{"c":"x"}]}}`},
+		{"move member", `{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`},
+		{"move array element", `{"a":[1,2,3]}`, `[{"op":"move","from":"/a/0","path":"/a/-"}]`, `{"a":[2,3,1]}`},
+		{"copy is deep", `{"a":{"b":[1]}}`,
+			`[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			`{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+		{"test then replace", `{"a":"b"}`,
+			`[{"op":"test","path":"/a","value":"b"},{"op":"replace","path":"/a","value":"c"}]`, `{"a":"c"}`},
+		{"test numbers by value", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1}`},
+		{"test objects without order", `{"a":{"x":1,"y":2}}`, `[{"op":"test","path":"/a","value":{"y":2,"x":1}}]`,
+			`{"a":{"x":1,"y":2}}`},
+		{"escaped slash", `{"a/b":1}`, `[{"op":"replace","path":"/a~1b","value":2}]`, `{"a/b":2}`},
+		{"escaped tilde", `{"m~n":1}`, `[{"op":"replace","path":"/m~0n","value":2}]`, `{"m~n":2}`},
+		{"~01 is ~1, not /", `{"~1":1,"/":2}`, `[{"op":"remove","path":"/~01"}]`, `{"/":2}`},
+		{"empty member name", `{"":1}`, `[{"op":"replace","path":"/","value":2}]`, `{"":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := DecodeOperations([]byte(tt.ops))
+			if err != nil {
+				t.Fatalf("DecodeOperations() error = %v", err)
+			}
+			got, err := Apply(mustDecode(t, tt.doc), ops)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if want := mustDecode(t, tt.want); !Equal(got, want) {
+				t.Errorf("Apply() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		ops  string
+		want error
+	}{
+		{"test fails", `{"a":"b"}`, `[{"op":"test","path":"/a","value":"c"}]`, ErrTestFailed},
+		{"test of a missing member", `{}`, `[{"op":"test","path":"/a","value":null}]`, ErrConflict},
+		{"remove missing member", `{"a":1}`, `[{"op":"remove","path":"/b"}]`, ErrConflict},
+		{"replace missing member", `{"a":1}`, `[{"op":"replace","path":"/b","value":1}]`, ErrConflict},
+		{"add under missing parent", `{}`, `[{"op":"add","path":"/a/b","value":1}]`, ErrConflict},
+		{"add past array end", `{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`, ErrConflict},
+		{"remove with -", `{"a":[1]}`, `[{"op":"remove","path":"/a/-"}]`, ErrConflict},
+		{"leading zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, ErrConflict},
+		{"index in a scalar", `{"a":1}`, `[{"op":"add","path":"/a/b","value":1}]`, ErrConflict},
+		{"move from missing", `{}`, `[{"op":"move","from":"/a","path":"/b"}]`, ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := DecodeOperations([]byte(tt.ops))
+			if err != nil {
+				t.Fatalf("DecodeOperations() error = %v", err)
+			}
+			if _, err := Apply(mustDecode(t, tt.doc), ops); !errors.Is(err, tt.want) {
+				t.Errorf("Apply() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFailedTestAbortsPatch(t *testing.T) {
+	// Le test échoue après une première opération : Apply ne retourne pas de document partiellement modifié.
+	ops, err := DecodeOperations([]byte(`[
+		{"op":"replace","path":"/a","value":2},
+		{"op":"test","path":"/b","value":"x"},
+		{"op":"remove","path":"/b"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Apply(mustDecode(t, `{"a":1,"b":"y"}`), ops)
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("Apply() error = %v, want %v", err, ErrTestFailed)
+	}
+	if got != nil {
+		t.Errorf("Apply() = %v, want nil", got)
+	}
+}
+
+func TestDecodeOperationsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  string
+	}{
+		{"not an array", `{"op":"add","path":"/a","value":1}`},
+		{"null", `null`},
+		{"unknown op", `[{"op":"increment","path":"/a"}]`},
+		{"pointer without /", `[{"op":"remove","path":"a"}]`},
+		{"add without value", `[{"op":"add","path":"/a"}]`},
+		{"replace without value", `[{"op":"replace","path":"/a"}]`},
+		{"test without value", `[{"op":"test","path":"/a"}]`},
+		{"remove whole document", `[{"op":"remove","path":""}]`},
+		{"invalid from", `[{"op":"copy","from":"a","path":"/b"}]`},
+		{"move into own child", `[{"op":"move","from":"/a","path":"/a/b"}]`},
+		{"malformed", `[{"op":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeOperations([]byte(tt.ops)); !errors.Is(err, ErrInvalidPatch) {
+				t.Errorf("DecodeOperations() error = %v, want %v", err, ErrInvalidPatch)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if _, err := Decode([]byte(`{"a":1} {}`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("Decode() of two documents error = %v, want %v", err, ErrInvalidPatch)
+	}
+
+	// Les grands entiers sont conservés sans perte de précision.
+	doc := mustDecode(t, `{"id":18446744073709551615}`)
+	if !Equal(doc, mustDecode(t, `{"id":18446744073709551615}`)) || Equal(doc, mustDecode(t, `{"id":18446744073709551614}`)) {
+		t.Errorf("Decode() lost the precision of a large integer: %v", doc)
+	}
+}
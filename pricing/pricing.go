@@ -0,0 +1,197 @@
+// Package pricing calcule le prix des commandes : remises, taxes et frais de livraison.
+//
+// Le calcul ajoute à la commande des lignes d'ajustement explicites, dans cet ordre :
+//
+//  1. Remises : chaque promotion applicable (automatique ou du code fourni), dans l'ordre des règles.
+//     Les pourcentages portent sur le sous-total, sans se cumuler entre eux ; le total des remises
+//     est plafonné au sous-total.
+//  2. Taxes : une ligne par article taxé. Les remises sont d'abord réparties entre les articles au prorata
+//     de leur prix (méthode du plus fort reste), puis le taux de l'article s'applique à son prix remisé.
+//  3. Livraison : les frais de la devise de la commande, sauf si le sous-total remisé atteint le seuil de gratuité.
+//     Les frais sont forfaitaires et ne sont pas taxés.
+//
+// Tous les montants sont des entiers en unités mineures. Chaque application d'un taux est arrondie
+// à l'unité la plus proche, à égalité vers l'unité paire, ce qui rend le résultat déterministe.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/validation"
+)
+
+// Apply calcule les ajustements de la commande selon les règles, puis ses totaux.
+// Les erreurs sur le code promotionnel ou un total trop grand sont retournées sous forme de validation.Errors.
+func (r Rules) Apply(o *model.Order) error {
+	// Sous-total des seuls articles.
+	o.Adjustments = nil
+	if err := o.ComputeTotals(); err != nil {
+		return totalError(err)
+	}
+
+	adjustments, discount, err := r.discounts(o)
+	if err != nil {
+		return err
+	}
+
+	taxes, err := r.taxes(o, discount)
+	if err != nil {
+		return totalError(err)
+	}
+	adjustments = append(adjustments, taxes...)
+
+	if shipping, ok := r.shipping(o, discount); ok {
+		adjustments = append(adjustments, shipping)
+	}
+
+	o.Adjustments = adjustments
+	if err := o.ComputeTotals(); err != nil {
+		return totalError(err)
+	}
+	return nil
+}
+
+// discounts retourne les remises applicables à la commande et leur montant total, positif.
+func (r Rules) discounts(o *model.Order) ([]model.Adjustment, int64, error) {
+	code := normalizeCode(o.DiscountCode)
+	o.DiscountCode = code
+
+	var adjustments []model.Adjustment
+	var total int64
+	found := false
+	for _, p := range r.Promotions {
+		if p.Code != "" && p.Code != code {
+			continue
+		}
+
+		// Les promotions automatiques inapplicables sont ignorées ; un code inapplicable est signalé au client.
+		if reason := p.inapplicable(o); reason != "" {
+			if p.Code != "" {
+				return nil, 0, codeError("discount code %q %s", code, reason)
+			}
+			continue
+		}
+		found = found || p.Code != ""
+
+		adj := model.Adjustment{
+			Kind:        model.AdjustmentDiscount,
+			Code:        p.Code,
+			Description: p.Description,
+		}
+		var amount int64
+		if p.Fixed != nil {
+			amount = p.Fixed.Amount
+		} else {
+			var err error
+			if amount, err = applyRate(o.Subtotal.Amount, p.PercentBP); err != nil {
+				return nil, 0, totalError(err)
+			}
+			base := o.Subtotal
+			adj.RateBP = p.PercentBP
+			adj.Base = &base
+		}
+
+		// Les remises ne peuvent pas dépasser le sous-total.
+		if left := o.Subtotal.Amount - total; amount > left {
+			amount = left
+		}
+		if amount == 0 {
+			continue
+		}
+		total += amount
+		adj.Amount = model.Money{Amount: -amount, Currency: o.Currency}
+		adjustments = append(adjustments, adj)
+	}
+
+	if code != "" && !found {
+		return nil, 0, codeError("unknown discount code %q", code)
+	}
+
+	return adjustments, total, nil
+}
+
+// inapplicable retourne la raison pour laquelle la promotion ne s'applique pas à la commande, ou "".
+func (p Promotion) inapplicable(o *model.Order) string {
+	if p.Fixed != nil && p.Fixed.Currency != o.Currency {
+		return fmt.Sprintf("does not apply to orders in %s", o.Currency)
+	}
+	if p.MinSubtotal != nil {
+		if p.MinSubtotal.Currency != o.Currency {
+			return fmt.Sprintf("does not apply to orders in %s", o.Currency)
+		}
+		if o.Subtotal.Amount < p.MinSubtotal.Amount {
+			return fmt.Sprintf("requires a subtotal of at least %d %s minor units", p.MinSubtotal.Amount, o.Currency)
+		}
+	}
+	return ""
+}
+
+// taxes retourne une ligne de taxe par article taxé, calculée sur son prix diminué de sa part des remises.
+func (r Rules) taxes(o *model.Order, discount int64) ([]model.Adjustment, error) {
+	lines := make([]int64, len(o.LineItems))
+	for i, item := range o.LineItems {
+		line, err := item.Total()
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = line.Amount
+	}
+	shares := allocate(discount, lines)
+
+	var adjustments []model.Adjustment
+	for i, item := range o.LineItems {
+		rate := r.Tax.rateOf(item.ItemID)
+		if rate == 0 {
+			continue
+		}
+
+		base := model.Money{Amount: lines[i] - shares[i], Currency: o.Currency}
+		tax, err := applyRate(base.Amount, rate)
+		if err != nil {
+			return nil, err
+		}
+
+		line := i
+		adjustments = append(adjustments, model.Adjustment{
+			Kind:   model.AdjustmentTax,
+			Line:   &line,
+			RateBP: rate,
+			Base:   &base,
+			Amount: model.Money{Amount: tax, Currency: o.Currency},
+		})
+	}
+
+	return adjustments, nil
+}
+
+// shipping retourne les frais de livraison de la commande, s'il y en a.
+func (r Rules) shipping(o *model.Order, discount int64) (model.Adjustment, bool) {
+	rule, exists := r.Shipping[o.Currency]
+	if !exists || rule.Fee == 0 {
+		return model.Adjustment{}, false
+	}
+	if rule.FreeFrom > 0 && o.Subtotal.Amount-discount >= rule.FreeFrom {
+		return model.Adjustment{}, false
+	}
+
+	return model.Adjustment{
+		Kind:        model.AdjustmentShipping,
+		Description: rule.Description,
+		Amount:      model.Money{Amount: rule.Fee, Currency: o.Currency},
+	}, true
+}
+
+// codeError crée l'erreur de validation du code promotionnel.
+func codeError(format string, args ...any) error {
+	return validation.Errors{{Field: "discount_code", Code: validation.CodeInvalid, Message: fmt.Sprintf(format, args...)}}
+}
+
+// totalError transforme un dépassement de capacité en erreur de validation du total.
+func totalError(err error) error {
+	if errors.Is(err, model.ErrAmountOverflow) {
+		return validation.Errors{{Field: "total", Code: validation.CodeOutOfRange, Message: "order total exceeds the maximum amount"}}
+	}
+	return err
+}
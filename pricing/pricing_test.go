@@ -0,0 +1,365 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/SamMebarek/orders-api/validation"
+	"github.com/google/uuid"
+)
+
+var (
+	itemA = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	itemB = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+)
+
+// testOrder retourne une commande de deux articles en euros : 2 × 10,00 taxé à 20 % et 1 × 5,00 taxé à 5,5 %,
+// soit un sous-total de 25,00.
+func testOrder(code string) *model.Order {
+	return &model.Order{
+		LineItems: []model.LineItem{
+			{ItemID: itemA, Quantity: 2, Price: model.Money{Amount: 1000, Currency: "EUR"}},
+			{ItemID: itemB, Quantity: 1, Price: model.Money{Amount: 500, Currency: "EUR"}},
+		},
+		DiscountCode: code,
+	}
+}
+
+// testRules retourne les taxes et la livraison de testOrder, avec les promotions données.
+func testRules(promotions ...Promotion) Rules {
+	return Rules{
+		Tax:        TaxRules{DefaultRateBP: 2000, ItemRatesBP: map[uuid.UUID]int64{itemB: 550}},
+		Promotions: promotions,
+		Shipping:   map[model.Currency]ShippingRule{"EUR": {Description: "Colissimo", Fee: 490, FreeFrom: 3000}},
+	}
+}
+
+// describe résume les ajustements en une ligne chacun, pour les comparer dans les tests.
+func describe(adjustments []model.Adjustment) []string {
+	lines := make([]string, 0, len(adjustments))
+	for _, adj := range adjustments {
+		switch adj.Kind {
+		case model.AdjustmentDiscount:
+			lines = append(lines, fmt.Sprintf("discount [%s] %d", adj.Code, adj.Amount.Amount))
+		case model.AdjustmentTax:
+			lines = append(lines, fmt.Sprintf("tax line %d: %d bp of %d = %d", *adj.Line, adj.RateBP, adj.Base.Amount,
+				adj.Amount.Amount))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %d", adj.Kind, adj.Amount.Amount))
+		}
+	}
+	return lines
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       Rules
+		code        string
+		adjustments []string
+		total       int64
+	}{
+		{
+			name:  "no rules",
+			rules: Rules{},
+			total: 2500,
+		},
+		{
+			name:  "taxes per line and shipping",
+			rules: testRules(),
+			adjustments: []string{
+				"tax line 0: 2000 bp of 2000 = 400",
+				"tax line 1: 550 bp of 500 = 28", // 27,5 arrondi au pair.
+				"shipping 490",
+			},
+			total: 3418,
+		},
+		{
+			name:  "percentage",
+			rules: testRules(Promotion{PercentBP: 1000}),
+			adjustments: []string{
+				"discount [] -250",
+				"tax line 0: 2000 bp of 1800 = 360",
+				"tax line 1: 550 bp of 450 = 25",
+				"shipping 490",
+			},
+			total: 3125,
+		},
+		{
+			name:  "fixed",
+			rules: testRules(Promotion{Fixed: &model.Money{Amount: 300, Currency: "EUR"}}),
+			adjustments: []string{
+				"discount [] -300",
+				"tax line 0: 2000 bp of 1760 = 352",
+				"tax line 1: 550 bp of 440 = 24",
+				"shipping 490",
+			},
+			total: 3066,
+		},
+		{
+			name:  "fixed in another currency",
+			rules: testRules(Promotion{Fixed: &model.Money{Amount: 300, Currency: "USD"}}),
+			adjustments: []string{
+				"tax line 0: 2000 bp of 2000 = 400",
+				"tax line 1: 550 bp of 500 = 28",
+				"shipping 490",
+			},
+			total: 3418,
+		},
+		{
+			name:  "minimum subtotal not reached",
+			rules: testRules(Promotion{PercentBP: 1000, MinSubtotal: &model.Money{Amount: 2501, Currency: "EUR"}}),
+			adjustments: []string{
+				"tax line 0: 2000 bp of 2000 = 400",
+				"tax line 1: 550 bp of 500 = 28",
+				"shipping 490",
+			},
+			total: 3418,
+		},
+		{
+			name:  "code",
+			rules: testRules(Promotion{Code: "WELCOME", PercentBP: 2000}),
+			code:  " welcome ",
+			adjustments: []string{
+				"discount [WELCOME] -500",
+				"tax line 0: 2000 bp of 1600 = 320",
+				"tax line 1: 550 bp of 400 = 22",
+				"shipping 490",
+			},
+			total: 2832,
+		},
+		{
+			name:  "code not given",
+			rules: testRules(Promotion{Code: "WELCOME", PercentBP: 2000}),
+			adjustments: []string{
+				"tax line 0: 2000 bp of 2000 = 400",
+				"tax line 1: 550 bp of 500 = 28",
+				"shipping 490",
+			},
+			total: 3418,
+		},
+		{
+			name: "automatic and code",
+			rules: testRules(
+				Promotion{PercentBP: 1000},
+				Promotion{Code: "WELCOME", Fixed: &model.Money{Amount: 250, Currency: "EUR"}},
+			),
+			code: "WELCOME",
+			adjustments: []string{
+				"discount [] -250",
+				"discount [WELCOME] -250",
+				"tax line 0: 2000 bp of 1600 = 320",
+				"tax line 1: 550 bp of 400 = 22",
+				"shipping 490",
+			},
+			total: 2832,
+		},
+		{
+			name:  "discount capped at the subtotal",
+			rules: testRules(Promotion{Fixed: &model.Money{Amount: 3000, Currency: "EUR"}}),
+			adjustments: []string{
+				"discount [] -2500",
+				"tax line 0: 2000 bp of 0 = 0",
+				"tax line 1: 550 bp of 0 = 0",
+				"shipping 490",
+			},
+			total: 490,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := testOrder(tt.code)
+			if err := tt.rules.Apply(o); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			if got := describe(o.Adjustments); !reflect.DeepEqual(got, append([]string{}, tt.adjustments...)) {
+				t.Errorf("adjustments = %q, want %q", got, tt.adjustments)
+			}
+			if o.Subtotal.Amount != 2500 || o.Total.Amount != tt.total || o.Total.Currency != "EUR" {
+				t.Errorf("subtotal = %v, total = %v, want 2500 and %d EUR", o.Subtotal, o.Total, tt.total)
+			}
+			if want := normalizeCode(tt.code); o.DiscountCode != want {
+				t.Errorf("discount code = %q, want %q", o.DiscountCode, want)
+			}
+		})
+	}
+}
+
+func TestApplyCodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		code    string
+		message string
+	}{
+		{
+			name:    "unknown code",
+			rules:   testRules(Promotion{Code: "WELCOME", PercentBP: 2000}),
+			code:    "OTHER",
+			message: `unknown discount code "OTHER"`,
+		},
+		{
+			name: "minimum subtotal not reached",
+			rules: testRules(Promotion{Code: "BIG", PercentBP: 2000,
+				MinSubtotal: &model.Money{Amount: 5000, Currency: "EUR"}}),
+			code:    "big",
+			message: `discount code "BIG" requires a subtotal of at least 5000 EUR minor units`,
+		},
+		{
+			name:    "another currency",
+			rules:   testRules(Promotion{Code: "TENUSD", Fixed: &model.Money{Amount: 1000, Currency: "USD"}}),
+			code:    "TENUSD",
+			message: `discount code "TENUSD" does not apply to orders in EUR`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Apply(testOrder(tt.code))
+
+			var errs validation.Errors
+			if !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("Apply() error = %v, want one validation error", err)
+			}
+			want := validation.FieldError{Field: "discount_code", Code: validation.CodeInvalid, Message: tt.message}
+			if errs[0] != want {
+				t.Errorf("Apply() error = %+v, want %+v", errs[0], want)
+			}
+		})
+	}
+}
+
+func TestApplyShipping(t *testing.T) {
+	tests := []struct {
+		name       string
+		currency   model.Currency
+		price      int64
+		promotions []Promotion
+		shipping   ShippingRule
+		want       int64 // Frais de livraison attendus, 0 si offerts.
+	}{
+		{"below threshold", "EUR", 2999, nil, ShippingRule{Fee: 490, FreeFrom: 3000}, 490},
+		{"at threshold", "EUR", 3000, nil, ShippingRule{Fee: 490, FreeFrom: 3000}, 0},
+		{"above threshold", "EUR", 3001, nil, ShippingRule{Fee: 490, FreeFrom: 3000}, 0},
+		{"discount below threshold", "EUR", 3500, []Promotion{{PercentBP: 2000}},
+			ShippingRule{Fee: 490, FreeFrom: 3000}, 490},
+		{"never free", "EUR", 100000, nil, ShippingRule{Fee: 490}, 490},
+		{"no fee", "EUR", 100, nil, ShippingRule{FreeFrom: 3000}, 0},
+		{"no rule for the currency", "USD", 100, nil, ShippingRule{Fee: 490, FreeFrom: 3000}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := Rules{
+				Promotions: tt.promotions,
+				Shipping:   map[model.Currency]ShippingRule{"EUR": tt.shipping},
+			}
+			o := &model.Order{LineItems: []model.LineItem{
+				{ItemID: itemA, Quantity: 1, Price: model.Money{Amount: tt.price, Currency: tt.currency}},
+			}}
+			if err := rules.Apply(o); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			var fee int64
+			for _, adj := range o.Adjustments {
+				if adj.Kind == model.AdjustmentShipping {
+					fee += adj.Amount.Amount
+				}
+			}
+			if fee != tt.want {
+				t.Errorf("shipping = %d, want %d", fee, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := writeRules(t, `{
+		"tax": {"default_rate_bp": 2000, "item_rates_bp": {"33333333-3333-3333-3333-333333333333": 550}},
+		"promotions": [{"code": " welcome10 ", "fixed": {"amount": 100, "currency": "EUR"}}],
+		"shipping": {"EUR": {"fee": 490, "free_from": 5000}}
+	}`)
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	if got := rules.Tax.rateOf(itemB); got != 550 {
+		t.Errorf("rate of %s = %d, want 550", itemB, got)
+	}
+	if got := rules.Promotions[0].Code; got != "WELCOME10" {
+		t.Errorf("promotion code = %q, want %q", got, "WELCOME10")
+	}
+
+	if rules, err := LoadRules(""); err != nil || !reflect.DeepEqual(rules, Rules{}) {
+		t.Errorf(`LoadRules("") = %+v, %v, want empty rules`, rules, err)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{"malformed", `{"tax": `, "failed to decode pricing rules"},
+		{"unknown field", `{"taxes": {}}`, `unknown field "taxes"`},
+		{"negative tax rate", `{"tax": {"default_rate_bp": -1}}`, "tax.default_rate_bp must be between 0 and 10000"},
+		{"item tax rate above 100 %",
+			`{"tax": {"item_rates_bp": {"33333333-3333-3333-3333-333333333333": 10001}}}`,
+			"tax.item_rates_bp[33333333-3333-3333-3333-333333333333] must be between 0 and 10000"},
+		{"code with spaces", `{"promotions": [{"code": "TWO WORDS", "percent_bp": 100}]}`,
+			"promotions[0].code must not contain spaces"},
+		{"duplicate code",
+			`{"promotions": [{"code": "welcome", "percent_bp": 100}, {"code": "WELCOME ", "percent_bp": 200}]}`,
+			"promotions[1].code duplicates promotions[0].code"},
+		{"no discount", `{"promotions": [{"code": "NONE"}]}`,
+			"promotions[0] must have exactly one of percent_bp and fixed"},
+		{"both discounts", `{"promotions": [{"percent_bp": 100, "fixed": {"amount": 100, "currency": "EUR"}}]}`,
+			"promotions[0] must have exactly one of percent_bp and fixed"},
+		{"percentage above 100 %", `{"promotions": [{"percent_bp": 10001}]}`,
+			"promotions[0].percent_bp must be between 1 and 10000"},
+		{"fixed not positive", `{"promotions": [{"fixed": {"amount": 0, "currency": "EUR"}}]}`,
+			"promotions[0].fixed must be a positive amount in a known currency"},
+		{"fixed in an unknown currency", `{"promotions": [{"fixed": {"amount": 100, "currency": "XYZ"}}]}`,
+			"promotions[0].fixed must be a positive amount in a known currency"},
+		{"negative minimum subtotal",
+			`{"promotions": [{"percent_bp": 100, "min_subtotal": {"amount": -1, "currency": "EUR"}}]}`,
+			"promotions[0].min_subtotal must be a non-negative amount in a known currency"},
+		{"currencies differ",
+			`{"promotions": [{"fixed": {"amount": 100, "currency": "EUR"}, "min_subtotal": {"amount": 1, "currency": "USD"}}]}`,
+			"promotions[0].fixed and min_subtotal must have the same currency"},
+		{"shipping in an unknown currency", `{"shipping": {"XYZ": {"fee": 490}}}`,
+			`shipping has unknown currency "XYZ"`},
+		{"negative shipping fee", `{"shipping": {"EUR": {"fee": -1}}}`,
+			"shipping[EUR] amounts must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRules(writeRules(t, tt.rules))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadRules() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadRules() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+// writeRules écrit les règles dans un fichier temporaire et retourne son chemin.
+func writeRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
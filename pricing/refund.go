@@ -0,0 +1,76 @@
+package pricing
+
+import (
+	"github.com/SamMebarek/orders-api/model"
+)
+
+// RefundAmount retourne le montant payé pour des articles retournés de la commande o.
+// Le prix net de chaque article est son prix, diminué de sa part des remises et augmenté de sa taxe,
+// répartis comme lors du calcul du prix de la commande ; un article retourné en partie rembourse la part
+// de ce prix net correspondant à la quantité retournée, arrondie comme les taux.
+// Les frais de livraison ne sont pas remboursés. Les articles absents de la commande sont ignorés.
+func RefundAmount(o model.Order, lines []model.ReturnLine) (model.Money, error) {
+	refund := model.Money{Currency: o.Currency}
+
+	// Prix de chaque article et total des remises, positif.
+	totals := make([]int64, len(o.LineItems))
+	for i, item := range o.LineItems {
+		line, err := item.Total()
+		if err != nil {
+			return model.Money{}, err
+		}
+		totals[i] = line.Amount
+	}
+	var discount int64
+	for _, adj := range o.Adjustments {
+		if adj.Kind == model.AdjustmentDiscount {
+			discount -= adj.Amount.Amount
+		}
+	}
+
+	// Prix net de chaque article.
+	net := make([]int64, len(o.LineItems))
+	for i, share := range allocate(discount, totals) {
+		net[i] = totals[i] - share
+	}
+	for _, adj := range o.Adjustments {
+		if adj.Kind == model.AdjustmentTax && adj.Line != nil && *adj.Line < len(net) {
+			net[*adj.Line] += adj.Amount.Amount
+		}
+	}
+
+	for _, line := range lines {
+		for i, item := range o.LineItems {
+			if item.ItemID != line.ItemID || item.Quantity == 0 {
+				continue
+			}
+			amount, err := mulDiv(net[i], int64(line.Quantity), int64(item.Quantity))
+			if err != nil {
+				return model.Money{}, err
+			}
+			if refund, err = refund.Add(model.Money{Amount: amount, Currency: o.Currency}); err != nil {
+				return model.Money{}, err
+			}
+		}
+	}
+
+	return refund, nil
+}
+
+// MaxRefund retourne le montant maximal d'un remboursement des articles retournés de la commande o :
+// leur prix net payé (voir RefundAmount), augmenté des frais de livraison de la commande,
+// qui ne sont remboursés que par un montant explicite.
+func MaxRefund(o model.Order, lines []model.ReturnLine) (model.Money, error) {
+	max, err := RefundAmount(o, lines)
+	if err != nil {
+		return model.Money{}, err
+	}
+	for _, adj := range o.Adjustments {
+		if adj.Kind == model.AdjustmentShipping {
+			if max, err = max.Add(adj.Amount); err != nil {
+				return model.Money{}, err
+			}
+		}
+	}
+	return max, nil
+}
@@ -0,0 +1,124 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+func TestRefundAmount(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		lines []model.ReturnLine
+		want  int64
+	}{
+		{
+			name:  "no rules",
+			rules: Rules{},
+			lines: []model.ReturnLine{{ItemID: itemA, Quantity: 1}},
+			want:  1000,
+		},
+		{
+			// 2 × 10,00 taxé à 20 % : 2400 pour la ligne, 1200 par article.
+			name:  "tax",
+			rules: testRules(),
+			lines: []model.ReturnLine{{ItemID: itemA, Quantity: 1}},
+			want:  1200,
+		},
+		{
+			// La remise de 2,50 est répartie 2,00 / 0,50 : (2000 - 200 + 360) / 2.
+			name:  "prorated discount and tax",
+			rules: testRules(Promotion{PercentBP: 1000}),
+			lines: []model.ReturnLine{{ItemID: itemA, Quantity: 1}},
+			want:  1080,
+		},
+		{
+			// 450 + 25 de taxe pour le second article.
+			name:  "other item with its own tax rate",
+			rules: testRules(Promotion{PercentBP: 1000}),
+			lines: []model.ReturnLine{{ItemID: itemB, Quantity: 1}},
+			want:  475,
+		},
+		{
+			// Tous les articles, sans les frais de livraison (490) du total de 3125.
+			name:  "whole order without shipping",
+			rules: testRules(Promotion{PercentBP: 1000}),
+			lines: []model.ReturnLine{{ItemID: itemA, Quantity: 2}, {ItemID: itemB, Quantity: 1}},
+			want:  2635,
+		},
+		{
+			// (2000 - 300 × 2000/2500 + 352) / 2 = (1760 + 352) / 2.
+			name:  "fixed discount",
+			rules: testRules(Promotion{Fixed: &model.Money{Amount: 300, Currency: "EUR"}}),
+			lines: []model.ReturnLine{{ItemID: itemA, Quantity: 1}},
+			want:  1056,
+		},
+		{
+			name:  "unknown item",
+			rules: testRules(),
+			lines: []model.ReturnLine{{ItemID: uuid.MustParse("99999999-9999-9999-9999-999999999999"), Quantity: 1}},
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := testOrder("")
+			if err := tt.rules.Apply(o); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := RefundAmount(*o, tt.lines)
+			if err != nil {
+				t.Fatalf("RefundAmount() error = %v", err)
+			}
+			if got != (model.Money{Amount: tt.want, Currency: "EUR"}) {
+				t.Errorf("RefundAmount() = %v, want %d EUR", got, tt.want)
+			}
+			if got.Amount > o.Total.Amount {
+				t.Errorf("RefundAmount() = %d, more than the order total %d", got.Amount, o.Total.Amount)
+			}
+		})
+	}
+}
+
+func TestRefundAmountPartialRounding(t *testing.T) {
+	// 3 × 3,33 taxé à 20 % : 999 + 200 = 1199 pour la ligne. Un article vaut 399,67, arrondi à 400 :
+	// trois remboursements d'un article dépassent le prix payé de la ligne, d'où le plafond au reste à rembourser.
+	o := &model.Order{
+		LineItems: []model.LineItem{{ItemID: itemA, Quantity: 3, Price: model.Money{Amount: 333, Currency: "EUR"}}},
+	}
+	if err := (Rules{Tax: TaxRules{DefaultRateBP: 2000}}).Apply(o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Total.Amount != 1199 {
+		t.Fatalf("total = %d, want 1199", o.Total.Amount)
+	}
+
+	one, err := RefundAmount(*o, []model.ReturnLine{{ItemID: itemA, Quantity: 1}})
+	if err != nil || one.Amount != 400 {
+		t.Errorf("RefundAmount() of one item = %v, %v, want 400 EUR", one, err)
+	}
+	all, err := RefundAmount(*o, []model.ReturnLine{{ItemID: itemA, Quantity: 3}})
+	if err != nil || all.Amount != o.Total.Amount {
+		t.Errorf("RefundAmount() of all items = %v, %v, want %d EUR", all, err, o.Total.Amount)
+	}
+}
+
+func TestMaxRefund(t *testing.T) {
+	o := testOrder("")
+	if err := testRules(Promotion{PercentBP: 1000}).Apply(o); err != nil {
+		t.Fatal(err)
+	}
+
+	// Le prix net des articles retournés (voir TestRefundAmount), plus les frais de livraison de 490.
+	one, err := MaxRefund(*o, []model.ReturnLine{{ItemID: itemA, Quantity: 1}})
+	if err != nil || one != (model.Money{Amount: 1080 + 490, Currency: "EUR"}) {
+		t.Errorf("MaxRefund() of one item = %v, %v, want 1570 EUR", one, err)
+	}
+	all, err := MaxRefund(*o, []model.ReturnLine{{ItemID: itemA, Quantity: 2}, {ItemID: itemB, Quantity: 1}})
+	if err != nil || all != o.Total {
+		t.Errorf("MaxRefund() of all items = %v, %v, want the total %v", all, err, o.Total)
+	}
+}
@@ -0,0 +1,79 @@
+package pricing
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/SamMebarek/orders-api/model"
+)
+
+// basisPoints est le dénominateur des taux : 10000 points de base valent 100 %.
+const basisPoints = 10000
+
+// applyRate retourne amount multiplié par le taux rateBP (en points de base), arrondi à l'unité mineure
+// la plus proche, et à égalité vers l'unité paire (arrondi bancaire) : 0,5 centime donne 0, 1,5 centime donne 2.
+// Retourne model.ErrAmountOverflow si le résultat dépasse la capacité d'un int64.
+func applyRate(amount, rateBP int64) (int64, error) {
+	return mulDiv(amount, rateBP, basisPoints)
+}
+
+// mulDiv retourne amount × num / den, arrondi à l'unité la plus proche et à égalité vers l'unité paire.
+// Le calcul est fait en entiers de taille arbitraire et ne dépend donc ni de l'ordre des opérations ni des flottants.
+// den doit être strictement positif. Retourne model.ErrAmountOverflow si le résultat dépasse la capacité d'un int64.
+func mulDiv(amount, num, den int64) (int64, error) {
+	product := new(big.Int).Mul(big.NewInt(amount), big.NewInt(num))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(den), new(big.Int))
+
+	// Compare le double du reste au diviseur pour savoir si la partie tronquée dépasse la moitié.
+	twice := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1)
+	cmp := twice.Cmp(big.NewInt(den))
+	if cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1) {
+		// QuoRem tronque vers zéro : l'arrondi s'éloigne de zéro dans le sens du produit.
+		quotient.Add(quotient, big.NewInt(int64(product.Sign())))
+	}
+
+	if !quotient.IsInt64() {
+		return 0, model.ErrAmountOverflow
+	}
+	return quotient.Int64(), nil
+}
+
+// allocate répartit total entre des parts proportionnelles à weights, sans perte ni création d'unité mineure,
+// par la méthode du plus fort reste : chaque part reçoit la partie entière de sa quote-part, puis les unités
+// restantes vont une à une aux parts de plus fort reste, et à égalité à la première.
+// total et weights doivent être positifs ou nuls ; sans poids, rien n'est réparti.
+func allocate(total int64, weights []int64) []int64 {
+	shares := make([]int64, len(weights))
+
+	sum := new(big.Int)
+	for _, w := range weights {
+		sum.Add(sum, big.NewInt(w))
+	}
+	if sum.Sign() == 0 || total == 0 {
+		return shares
+	}
+
+	remainders := make([]*big.Int, len(weights))
+	left := total
+	for i, w := range weights {
+		quotient, remainder := new(big.Int).QuoRem(new(big.Int).Mul(big.NewInt(total), big.NewInt(w)), sum, new(big.Int))
+		// Chaque quote-part est inférieure à total, elle tient donc dans un int64.
+		shares[i] = quotient.Int64()
+		remainders[i] = remainder
+		left -= shares[i]
+	}
+
+	// Les unités restantes sont moins nombreuses que les parts.
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+	for _, i := range order[:left] {
+		shares[i]++
+	}
+
+	return shares
+}
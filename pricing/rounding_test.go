@@ -0,0 +1,125 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/model"
+)
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		name             string
+		amount, num, den int64
+		want             int64
+	}{
+		{"exact", 1250, 2000, 10000, 250},
+		{"below half", 14, 1, 10, 1},
+		{"above half", 16, 1, 10, 2},
+		{"half to even zero", 5, 1, 10, 0},
+		{"half up to even", 15, 1, 10, 2},
+		{"half down to even", 25, 1, 10, 2},
+		{"negative below half", -14, 1, 10, -1},
+		{"negative above half", -16, 1, 10, -2},
+		{"negative half to even zero", -5, 1, 10, 0},
+		{"negative half down to even", -15, 1, 10, -2},
+		{"negative half up to even", -25, 1, 10, -2},
+		{"negative rate", 15, -1, 10, -2},
+		{"large intermediate product", math.MaxInt64, 2, 2, math.MaxInt64},
+		{"minimum", math.MinInt64, 1, 1, math.MinInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mulDiv(tt.amount, tt.num, tt.den)
+			if err != nil {
+				t.Fatalf("mulDiv(%d, %d, %d) error = %v", tt.amount, tt.num, tt.den, err)
+			}
+			if got != tt.want {
+				t.Errorf("mulDiv(%d, %d, %d) = %d, want %d", tt.amount, tt.num, tt.den, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMulDivOverflow(t *testing.T) {
+	tests := []struct {
+		name             string
+		amount, num, den int64
+	}{
+		{"above maximum", math.MaxInt64, 2, 1},
+		{"above maximum after division", math.MaxInt64, 3, 2},
+		{"below minimum", math.MinInt64, 2, 1},
+		{"negated minimum", math.MinInt64, -1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := mulDiv(tt.amount, tt.num, tt.den); !errors.Is(err, model.ErrAmountOverflow) {
+				t.Errorf("mulDiv(%d, %d, %d) error = %v, want %v", tt.amount, tt.num, tt.den, err, model.ErrAmountOverflow)
+			}
+		})
+	}
+}
+
+func TestApplyRate(t *testing.T) {
+	tests := []struct {
+		amount, rateBP int64
+		want           int64
+	}{
+		{2000, 2000, 400},
+		{500, 550, 28}, // 27,5 arrondi au pair supérieur.
+		{450, 550, 25}, // 24,75.
+		{1, 5000, 0},   // 0,5 arrondi au pair inférieur.
+		{3, 5000, 2},   // 1,5 arrondi au pair supérieur.
+		{-3, 5000, -2},
+		{1999, 0, 0},
+	}
+	for _, tt := range tests {
+		got, err := applyRate(tt.amount, tt.rateBP)
+		if err != nil {
+			t.Fatalf("applyRate(%d, %d) error = %v", tt.amount, tt.rateBP, err)
+		}
+		if got != tt.want {
+			t.Errorf("applyRate(%d, %d) = %d, want %d", tt.amount, tt.rateBP, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		weights []int64
+		want    []int64
+	}{
+		{"exact", 10, []int64{1, 2, 7}, []int64{1, 2, 7}},
+		{"largest remainder", 100, []int64{1, 2}, []int64{33, 67}},
+		{"ties go to the first parts", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"ties in order", 2, []int64{1, 1, 1}, []int64{1, 1, 0}},
+		{"tie between largest remainders", 1, []int64{1, 2, 2}, []int64{0, 1, 0}},
+		{"zero weight", 5, []int64{0, 1, 1}, []int64{0, 3, 2}},
+		{"zero total", 0, []int64{1, 2}, []int64{0, 0}},
+		{"zero weights", 10, []int64{0, 0}, []int64{0, 0}},
+		{"no weights", 10, nil, []int64{}},
+		{"maximum total", math.MaxInt64, []int64{1, 1}, []int64{math.MaxInt64/2 + 1, math.MaxInt64 / 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocate(tt.total, tt.weights)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("allocate(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+			}
+
+			// Les parts se répartissent tout le total dès qu'un poids est positif.
+			var sum, weights int64
+			for i, share := range got {
+				sum += share
+				weights += tt.weights[i]
+			}
+			if weights > 0 && sum != tt.total {
+				t.Errorf("allocate(%d, %v) sums to %d", tt.total, tt.weights, sum)
+			}
+		})
+	}
+}
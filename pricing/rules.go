@@ -0,0 +1,146 @@
+package pricing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// Rules regroupe les règles de prix, lues depuis un fichier JSON. La valeur zéro n'ajoute aucun ajustement.
+// Les taux sont en points de base : 2000 vaut 20 %, 550 vaut 5,5 %.
+type Rules struct {
+	Tax        TaxRules                        `json:"tax"`        // Taux de taxe des articles.
+	Promotions []Promotion                     `json:"promotions"` // Remises, appliquées dans l'ordre du fichier.
+	Shipping   map[model.Currency]ShippingRule `json:"shipping"`   // Frais de livraison par devise.
+}
+
+// TaxRules définit le taux de taxe de chaque article.
+type TaxRules struct {
+	DefaultRateBP int64               `json:"default_rate_bp"` // Taux des articles sans taux propre.
+	ItemRatesBP   map[uuid.UUID]int64 `json:"item_rates_bp"`   // Taux propres à certains articles.
+}
+
+// rateOf retourne le taux de taxe d'un article.
+func (t TaxRules) rateOf(itemID uuid.UUID) int64 {
+	if rate, exists := t.ItemRatesBP[itemID]; exists {
+		return rate
+	}
+	return t.DefaultRateBP
+}
+
+// Promotion est une remise sur le sous-total, en pourcentage ou d'un montant fixe.
+// Sans code, elle s'applique à toutes les commandes qui remplissent ses conditions ;
+// avec un code, seulement aux commandes créées avec ce code.
+type Promotion struct {
+	Code        string       `json:"code,omitempty"`         // Code promotionnel, insensible à la casse.
+	Description string       `json:"description,omitempty"`  // Libellé repris dans la ligne d'ajustement.
+	PercentBP   int64        `json:"percent_bp,omitempty"`   // Remise en pourcentage du sous-total.
+	Fixed       *model.Money `json:"fixed,omitempty"`        // Remise d'un montant fixe, dans la devise de la commande.
+	MinSubtotal *model.Money `json:"min_subtotal,omitempty"` // Sous-total minimal, dans la devise de la commande.
+}
+
+// ShippingRule définit les frais de livraison des commandes d'une devise.
+type ShippingRule struct {
+	Description string `json:"description,omitempty"` // Libellé repris dans la ligne d'ajustement.
+	Fee         int64  `json:"fee"`                   // Frais, en unités mineures.
+	FreeFrom    int64  `json:"free_from,omitempty"`   // Sous-total remisé à partir duquel la livraison est offerte, 0 pour jamais.
+}
+
+// LoadRules lit et vérifie les règles de prix du fichier path. Un chemin vide retourne des règles vides.
+func LoadRules(path string) (Rules, error) {
+	var rules Rules
+	if path == "" {
+		return rules, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, fmt.Errorf("failed to read pricing rules: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return Rules{}, fmt.Errorf("failed to decode pricing rules: %w", err)
+	}
+
+	if err := rules.validate(); err != nil {
+		return Rules{}, fmt.Errorf("invalid pricing rules: %w", err)
+	}
+
+	// Les codes sont comparés en majuscules.
+	for i := range rules.Promotions {
+		rules.Promotions[i].Code = normalizeCode(rules.Promotions[i].Code)
+	}
+
+	return rules, nil
+}
+
+// validate vérifie la cohérence des règles.
+func (r Rules) validate() error {
+	if err := checkRate("tax.default_rate_bp", r.Tax.DefaultRateBP); err != nil {
+		return err
+	}
+	for itemID, rate := range r.Tax.ItemRatesBP {
+		if err := checkRate(fmt.Sprintf("tax.item_rates_bp[%s]", itemID), rate); err != nil {
+			return err
+		}
+	}
+
+	codes := make(map[string]int, len(r.Promotions))
+	for i, p := range r.Promotions {
+		field := fmt.Sprintf("promotions[%d]", i)
+
+		if code := normalizeCode(p.Code); code != "" {
+			if strings.ContainsAny(code, " \t\r\n") {
+				return fmt.Errorf("%s.code must not contain spaces", field)
+			}
+			if first, exists := codes[code]; exists {
+				return fmt.Errorf("%s.code duplicates promotions[%d].code", field, first)
+			}
+			codes[code] = i
+		}
+
+		switch {
+		case (p.PercentBP == 0) == (p.Fixed == nil):
+			return fmt.Errorf("%s must have exactly one of percent_bp and fixed", field)
+		case p.Fixed != nil && (p.Fixed.Amount <= 0 || !p.Fixed.Currency.Valid()):
+			return fmt.Errorf("%s.fixed must be a positive amount in a known currency", field)
+		case p.Fixed == nil && (p.PercentBP < 0 || p.PercentBP > basisPoints):
+			return fmt.Errorf("%s.percent_bp must be between 1 and %d", field, basisPoints)
+		case p.MinSubtotal != nil && (p.MinSubtotal.Amount < 0 || !p.MinSubtotal.Currency.Valid()):
+			return fmt.Errorf("%s.min_subtotal must be a non-negative amount in a known currency", field)
+		case p.Fixed != nil && p.MinSubtotal != nil && p.Fixed.Currency != p.MinSubtotal.Currency:
+			return fmt.Errorf("%s.fixed and min_subtotal must have the same currency", field)
+		}
+	}
+
+	for currency, s := range r.Shipping {
+		if !currency.Valid() {
+			return fmt.Errorf("shipping has unknown currency %q", currency)
+		}
+		if s.Fee < 0 || s.FreeFrom < 0 {
+			return fmt.Errorf("shipping[%s] amounts must not be negative", currency)
+		}
+	}
+
+	return nil
+}
+
+// checkRate vérifie qu'un taux de taxe est compris entre 0 et 100 %.
+func checkRate(field string, rate int64) error {
+	if rate < 0 || rate > basisPoints {
+		return fmt.Errorf("%s must be between 0 and %d", field, basisPoints)
+	}
+	return nil
+}
+
+// normalizeCode met un code promotionnel sous sa forme de comparaison.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore enregistre les réponses idempotentes en mémoire, pour une seule instance.
+// La valeur zéro est prête à l'emploi et peut être utilisée par plusieurs goroutines.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+}
+
+// memoryRecord est un enregistrement avec sa date d'expiration.
+type memoryRecord struct {
+	record
+	expiresAt time.Time
+}
+
+// Begin réserve la clé si elle est libre ou expirée, ou retourne l'état de la requête d'origine.
+func (s *MemoryStore) Begin(ctx context.Context, key, hash string, lockTTL time.Duration) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records == nil {
+		s.records = make(map[string]memoryRecord)
+	}
+
+	now := time.Now()
+	if existing, ok := s.records[key]; ok && now.Before(existing.expiresAt) {
+		return existing.check(hash)
+	}
+
+	// Purge les enregistrements expirés pour limiter la mémoire utilisée.
+	for k, r := range s.records {
+		if !now.Before(r.expiresAt) {
+			delete(s.records, k)
+		}
+	}
+
+	s.records[key] = memoryRecord{record: record{Hash: hash}, expiresAt: now.Add(lockTTL)}
+
+	return nil, nil
+}
+
+// Complete enregistre la réponse d'une clé réservée.
+func (s *MemoryStore) Complete(ctx context.Context, key, hash string, res Response, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records == nil {
+		s.records = make(map[string]memoryRecord)
+	}
+
+	s.records[key] = memoryRecord{record: record{Hash: hash, Response: &res}, expiresAt: time.Now().Add(ttl)}
+
+	return nil
+}
+
+// Release libère une clé réservée.
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore est un struct pour enregistrer les réponses idempotentes dans Redis. Il contient un client Redis.
+// Les clés expirent d'elles-mêmes grâce au TTL Redis.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+// idempotencyKey génère la clé Redis d'une clé d'idempotence.
+func idempotencyKey(key string) string {
+	return fmt.Sprintf("idempotency:%s", key)
+}
+
+// Begin réserve la clé avec SetNX, ou retourne l'état de la requête d'origine.
+func (s *RedisStore) Begin(ctx context.Context, key, hash string, lockTTL time.Duration) (*Response, error) {
+	data, err := json.Marshal(record{Hash: hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	for {
+		// Tente de réserver la clé : une seule requête concurrente peut y parvenir.
+		reserved, err := s.Client.SetNX(ctx, idempotencyKey(key), data, lockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve key: %w", err)
+		} else if reserved {
+			return nil, nil
+		}
+
+		// La clé existe déjà : lit l'état de la requête d'origine.
+		value, err := s.Client.Get(ctx, idempotencyKey(key)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// La clé a expiré entre les deux commandes : nouvelle tentative.
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get record: %w", err)
+		}
+
+		var existing record
+		if err := json.Unmarshal(value, &existing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal record: %w", err)
+		}
+
+		return existing.check(hash)
+	}
+}
+
+// Complete enregistre la réponse d'une clé réservée.
+func (s *RedisStore) Complete(ctx context.Context, key, hash string, res Response, ttl time.Duration) error {
+	data, err := json.Marshal(record{Hash: hash, Response: &res})
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	if err := s.Client.Set(ctx, idempotencyKey(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store response: %w", err)
+	}
+
+	return nil
+}
+
+// Release libère une clé réservée.
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	if err := s.Client.Del(ctx, idempotencyKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to release key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Response est une réponse HTTP enregistrée, rejouée lorsqu'une requête est renvoyée avec la même clé.
+type Response struct {
+	Status int         `json:"status"` // Code de statut HTTP.
+	Header http.Header `json:"header"` // En-têtes à rejouer.
+	Body   []byte      `json:"body"`   // Corps de la réponse.
+}
+
+// Store enregistre les réponses associées aux clés d'idempotence.
+type Store interface {
+	// Begin réserve la clé pour une requête dont le contenu a pour empreinte hash.
+	// Si la clé est libre, elle est réservée pendant lockTTL et Begin retourne (nil, nil) :
+	// l'appelant doit traiter la requête puis appeler Complete ou Release.
+	// Si une réponse est déjà enregistrée pour la même empreinte, elle est retournée.
+	// Retourne ErrMismatch si la clé a été utilisée avec une autre empreinte,
+	// et ErrInProgress si la requête d'origine est encore en cours.
+	Begin(ctx context.Context, key, hash string, lockTTL time.Duration) (*Response, error)
+	// Complete enregistre la réponse d'une clé réservée pendant ttl.
+	Complete(ctx context.Context, key, hash string, res Response, ttl time.Duration) error
+	// Release libère une clé réservée sans enregistrer de réponse, pour que la requête puisse être renvoyée.
+	Release(ctx context.Context, key string) error
+}
+
+// ErrMismatch est une erreur retournée lorsqu'une clé est réutilisée avec un contenu différent.
+var ErrMismatch = errors.New("idempotency key reused with a different request")
+
+// ErrInProgress est une erreur retournée lorsqu'une requête avec la même clé est encore en cours.
+var ErrInProgress = errors.New("request with this idempotency key is in progress")
+
+// record est l'état d'une clé d'idempotence : réservée (Response nil) ou terminée.
+type record struct {
+	Hash     string    `json:"hash"`               // Empreinte de la requête d'origine.
+	Response *Response `json:"response,omitempty"` // Réponse enregistrée, nil tant que la requête est en cours.
+}
+
+// check compare un enregistrement existant à une nouvelle requête.
+func (r record) check(hash string) (*Response, error) {
+	if r.Hash != hash {
+		return nil, ErrMismatch
+	}
+	if r.Response == nil {
+		return nil, ErrInProgress
+	}
+	return r.Response, nil
+}
+
+// Vérifie à la compilation que les implémentations respectent l'interface.
+var (
+	_ Store = (*RedisStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,229 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SamMebarek/orders-api/audit"
+	"github.com/SamMebarek/orders-api/model"
+)
+
+// MemoryRepo est un dépôt de commandes en mémoire, utilisé pour les tests et le développement local.
+// Il reproduit le comportement de RedisRepo sans nécessiter de serveur Redis.
+// La valeur zéro est prête à l'emploi et peut être utilisée par plusieurs goroutines.
+type MemoryRepo struct {
+	mu      sync.RWMutex
+	orders  map[uint64][]byte        // Commandes sérialisées en JSON, comme dans Redis.
+	history map[uint64][]audit.Entry // Historique des modifications de chaque commande.
+}
+
+// defaultScanCount est le nombre de commandes retournées lorsque la taille de page n'est pas précisée,
+// comme le COUNT par défaut de SSCAN.
+const defaultScanCount = 10
+
+// Insert ajoute une nouvelle commande en mémoire.
+// Une commande existante n'est jamais écrasée.
+func (r *MemoryRepo) Insert(ctx context.Context, order model.Order) error {
+	// Convertit la commande en JSON.
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.orders == nil {
+		r.orders = make(map[uint64][]byte)
+		r.history = make(map[uint64][]audit.Entry)
+	}
+
+	// N'ajoute la commande que si l'ID n'est pas encore utilisé.
+	if _, exists := r.orders[order.OrderID]; exists {
+		return ErrAlreadyExists
+	}
+
+	// Ajoute la création à l'historique de la commande.
+	entry, err := audit.NewEntry(ctx, nil, order)
+	if err != nil {
+		return fmt.Errorf("failed to create history entry: %w", err)
+	}
+
+	r.orders[order.OrderID] = data
+	r.history[order.OrderID] = append(r.history[order.OrderID], entry)
+
+	return nil
+}
+
+// FindByID trouve une commande par son ID.
+func (r *MemoryRepo) FindByID(ctx context.Context, id uint64, opts FindOptions) (model.Order, error) {
+	r.mu.RLock()
+	data, exists := r.orders[id]
+	r.mu.RUnlock()
+
+	if !exists {
+		return model.Order{}, ErrNotExist
+	}
+
+	// Convertit la commande JSON en struct Order.
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return model.Order{}, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+
+	// Une commande supprimée n'est retournée que sur demande.
+	if order.DeletedAt != nil && !opts.IncludeDeleted {
+		return model.Order{}, ErrNotExist
+	}
+
+	return order, nil
+}
+
+// History retourne l'historique d'une commande, de la plus ancienne modification à la plus récente.
+func (r *MemoryRepo) History(ctx context.Context, id uint64) ([]audit.Entry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]audit.Entry{}, r.history[id]...), nil
+}
+
+// PurgeDeleted supprime définitivement les commandes supprimées avant la date donnée.
+func (r *MemoryRepo) PurgeDeleted(ctx context.Context, before time.Time) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged uint64
+	for id, data := range r.orders {
+		var order model.Order
+		if err := json.Unmarshal(data, &order); err != nil {
+			return purged, fmt.Errorf("failed to unmarshal order: %w", err)
+		}
+
+		if order.DeletedAt != nil && order.DeletedAt.Before(before) {
+			delete(r.orders, id)
+			delete(r.history, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// Update met à jour une commande existante si sa version n'a pas changé depuis sa lecture.
+// Comme SetXX, une commande absente n'est jamais créée.
+func (r *MemoryRepo) Update(ctx context.Context, order model.Order) error {
+	expected := order.Version
+	order.Version++
+
+	// Convertit la commande en JSON pour la mise à jour.
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.orders[order.OrderID]
+	if !exists {
+		return ErrNotExist
+	}
+
+	// Compare la version enregistrée à la version lue par l'appelant.
+	var previous model.Order
+	if err := json.Unmarshal(stored, &previous); err != nil {
+		return fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+	if previous.Version != expected {
+		return ErrVersionConflict
+	}
+
+	// Ajoute la modification à l'historique de la commande.
+	entry, err := audit.NewEntry(ctx, &previous, order)
+	if err != nil {
+		return fmt.Errorf("failed to create history entry: %w", err)
+	}
+
+	r.orders[order.OrderID] = data
+	r.history[order.OrderID] = append(r.history[order.OrderID], entry)
+
+	return nil
+}
+
+// FindAll trouve toutes les commandes avec une pagination, de la plus récente à la plus ancienne.
+func (r *MemoryRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	size := page.Size
+	if size == 0 {
+		size = defaultScanCount
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Convertit les commandes de JSON en struct Order en appliquant les filtres.
+	orders := make([]model.Order, 0, len(r.orders))
+	for _, data := range r.orders {
+		var order model.Order
+		if err := json.Unmarshal(data, &order); err != nil {
+			return FindResult{}, fmt.Errorf("failed to unmarshal order: %w", err)
+		}
+
+		if !page.matches(order) {
+			continue
+		}
+
+		orders = append(orders, order)
+	}
+
+	// Trie les commandes de la plus récente à la plus ancienne, puis par ID décroissant.
+	sort.Slice(orders, func(i, j int) bool {
+		return newerThan(orders[i], createdAt(orders[j]), orders[j].OrderID)
+	})
+
+	// Saute les commandes déjà retournées dans les pages précédentes.
+	start := 0
+	if page.After != nil {
+		start = sort.Search(len(orders), func(i int) bool {
+			return !newerThan(orders[i], page.After.CreatedAt, page.After.OrderID)
+		})
+		// La commande du cursor elle-même a déjà été retournée.
+		if start < len(orders) && orders[start].OrderID == page.After.OrderID {
+			start++
+		}
+	}
+
+	res := FindResult{
+		Orders: orders[start:],
+		Total:  uint64(len(orders)),
+	}
+
+	// S'il reste des commandes, le cursor pointe sur la dernière commande retournée.
+	if uint64(len(res.Orders)) > size {
+		res.Orders = res.Orders[:size]
+		last := res.Orders[size-1]
+		res.Next = &Cursor{CreatedAt: createdAt(last), OrderID: last.OrderID}
+	}
+
+	return res, nil
+}
+
+// createdAt retourne la date de création d'une commande, ou la date zéro si elle n'est pas définie.
+func createdAt(order model.Order) time.Time {
+	if order.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *order.CreatedAt
+}
+
+// newerThan indique si une commande vient avant la position (date, ID) dans l'ordre de listage.
+func newerThan(order model.Order, t time.Time, id uint64) bool {
+	created := createdAt(order)
+	if !created.Equal(t) {
+		return created.After(t)
+	}
+	return order.OrderID > id
+}
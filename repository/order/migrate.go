@@ -0,0 +1,111 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// migrations contient les fichiers de migration SQL, un dossier par base de données.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// Migrator est implémenté par les dépôts qui doivent préparer leur schéma avant utilisation.
+type Migrator interface {
+	// Migrate applique les migrations de schéma manquantes.
+	Migrate(ctx context.Context) error
+}
+
+// migrationSet décrit comment appliquer les migrations d'une base de données.
+type migrationSet struct {
+	dir    string // Dossier des fichiers .sql dans migrations.
+	lock   string // Requête de verrouillage exécutée en début de transaction, vide si inutile.
+	insert string // Requête enregistrant une version appliquée.
+}
+
+// apply applique dans une seule transaction, par ordre de nom de fichier, les migrations pas encore appliquées.
+func (m migrationSet) apply(ctx context.Context, db *sql.DB) error {
+	// Liste les fichiers de migration et les trie par nom.
+	entries, err := fs.ReadDir(migrations, m.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Empêche deux instances d'appliquer les migrations en même temps.
+	if m.lock != "" {
+		if _, err := tx.ExecContext(ctx, m.lock); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+
+	// Crée la table de suivi des migrations si elle n'existe pas encore.
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version TEXT PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	// Récupère les versions déjà appliquées.
+	applied := make(map[string]bool)
+	rows, err := tx.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	// Applique les migrations manquantes.
+	for _, name := range names {
+		version := strings.TrimSuffix(name, ".sql")
+		if applied[version] {
+			continue
+		}
+
+		query, err := fs.ReadFile(migrations, path.Join(m.dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", version, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, m.insert, version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+
+	return nil
+}
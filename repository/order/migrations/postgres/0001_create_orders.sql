@@ -0,0 +1,17 @@
+-- Les IDs de commande sont des uint64 stockés bit à bit dans un BIGINT signé.
+CREATE TABLE orders (
+    order_id     BIGINT PRIMARY KEY,
+    customer_id  UUID NOT NULL,
+    created_at   TIMESTAMPTZ,
+    shipped_at   TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE TABLE line_items (
+    order_id BIGINT NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    item_id  UUID NOT NULL,
+    quantity BIGINT NOT NULL,
+    price    BIGINT NOT NULL,
+    PRIMARY KEY (order_id, position)
+);
@@ -0,0 +1,4 @@
+-- Index pour la pagination par clé (date de création, ID), de la plus récente à la plus ancienne,
+-- de toutes les commandes et de celles d'un client.
+CREATE INDEX orders_created_at_order_id ON orders (created_at DESC, order_id DESC);
+CREATE INDEX orders_customer_id_created_at ON orders (customer_id, created_at DESC, order_id DESC);
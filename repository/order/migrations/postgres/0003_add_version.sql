@@ -0,0 +1,2 @@
+-- Version de la commande pour le contrôle de concurrence optimiste.
+ALTER TABLE orders ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+-- État de la commande, jusque-là déduit des dates d'expédition et de finalisation.
+ALTER TABLE orders ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+UPDATE orders SET status = CASE
+	WHEN completed_at IS NOT NULL THEN 'completed'
+	WHEN shipped_at IS NOT NULL THEN 'shipped'
+	ELSE 'pending'
+END;
+-- Index pour filtrer par état en conservant la pagination par clé.
+CREATE INDEX orders_status_created_at ON orders (status, created_at DESC, order_id DESC);
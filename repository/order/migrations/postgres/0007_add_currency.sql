@@ -0,0 +1,3 @@
+-- Devise de la commande (code ISO 4217), commune à tous ses articles dont les prix sont en unités mineures.
+-- Les commandes existantes étaient en euros.
+ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR';
@@ -0,0 +1,4 @@
+-- Code promotionnel fourni à la création de la commande, NULL sans code.
+-- Ajustements de prix (remises, taxes, frais de livraison) encodés en JSON, NULL sans ajustement.
+ALTER TABLE orders ADD COLUMN discount_code TEXT;
+ALTER TABLE orders ADD COLUMN adjustments TEXT;
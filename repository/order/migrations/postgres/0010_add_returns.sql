@@ -0,0 +1,2 @@
+-- Demandes de retour de la commande (articles, décision, réception et remboursement) encodées en JSON, NULL sans retour.
+ALTER TABLE orders ADD COLUMN returns TEXT;
@@ -0,0 +1,4 @@
+-- Adresse de livraison de la commande encodée en JSON, NULL sans adresse.
+-- Adresse de facturation de la commande encodée en JSON, NULL sans adresse.
+ALTER TABLE orders ADD COLUMN shipping_address TEXT;
+ALTER TABLE orders ADD COLUMN billing_address TEXT;
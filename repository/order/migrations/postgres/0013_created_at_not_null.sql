@@ -0,0 +1,6 @@
+-- Toute commande a une date de création : la pagination par clé (created_at, order_id) ne peut pas comparer
+-- une date NULL, rangée avant les autres en ordre décroissant. Les commandes enregistrées sans date reçoivent
+-- leur date d'expédition, de finalisation ou de suppression, à défaut la date de la migration.
+UPDATE orders SET created_at = COALESCE(shipped_at, completed_at, deleted_at, CURRENT_TIMESTAMP)
+    WHERE created_at IS NULL;
+ALTER TABLE orders ALTER COLUMN created_at SET NOT NULL;
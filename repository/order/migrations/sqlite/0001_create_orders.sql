@@ -0,0 +1,17 @@
+-- Les IDs de commande sont des uint64 stockés bit à bit dans un INTEGER signé.
+CREATE TABLE orders (
+    order_id     INTEGER PRIMARY KEY,
+    customer_id  TEXT NOT NULL,
+    created_at   DATETIME,
+    shipped_at   DATETIME,
+    completed_at DATETIME
+);
+
+CREATE TABLE line_items (
+    order_id INTEGER NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    item_id  TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    price    INTEGER NOT NULL,
+    PRIMARY KEY (order_id, position)
+);
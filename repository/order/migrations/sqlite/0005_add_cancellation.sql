@@ -0,0 +1,4 @@
+-- Date et motif d'annulation de la commande, nuls si elle n'est pas annulée.
+ALTER TABLE orders ADD COLUMN cancelled_at DATETIME;
+ALTER TABLE orders ADD COLUMN cancel_reason TEXT;
+ALTER TABLE orders ADD COLUMN cancel_note TEXT;
@@ -0,0 +1,5 @@
+-- Suppression logique : date et auteur de la suppression, nuls si la commande est active.
+ALTER TABLE orders ADD COLUMN deleted_at DATETIME;
+ALTER TABLE orders ADD COLUMN deleted_by TEXT;
+-- Index pour la purge des commandes supprimées.
+CREATE INDEX orders_deleted_at ON orders (deleted_at);
@@ -0,0 +1,3 @@
+-- Colis expédiés de la commande (transporteur, suivi, articles et quantités) encodés en JSON, NULL sans colis.
+-- Ils sont écrits avec la commande, dans la même requête conditionnée par sa version.
+ALTER TABLE orders ADD COLUMN shipments TEXT;
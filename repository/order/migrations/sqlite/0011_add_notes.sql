@@ -0,0 +1,2 @@
+-- Notes libres sur la commande, modifiables à tout moment, NULL sans note.
+ALTER TABLE orders ADD COLUMN notes TEXT;
@@ -0,0 +1,16 @@
+-- Toute commande a une date de création : la pagination par clé (created_at, order_id) ne peut pas comparer
+-- une date NULL. Les commandes enregistrées sans date reçoivent leur date d'expédition, de finalisation
+-- ou de suppression, à défaut la date de la migration, écrite au format des dates du dépôt (voir OpenSQLite)
+-- pour que leur comparaison textuelle reste chronologique.
+UPDATE orders SET created_at = COALESCE(shipped_at, completed_at, deleted_at, strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'))
+    WHERE created_at IS NULL;
+-- SQLite ne peut pas ajouter NOT NULL à une colonne existante, et reconstruire la table supprimerait
+-- les articles en cascade : la contrainte est vérifiée par des triggers.
+CREATE TRIGGER orders_created_at_insert BEFORE INSERT ON orders WHEN NEW.created_at IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'NOT NULL constraint failed: orders.created_at');
+END;
+CREATE TRIGGER orders_created_at_update BEFORE UPDATE OF created_at ON orders WHEN NEW.created_at IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'NOT NULL constraint failed: orders.created_at');
+END;
@@ -0,0 +1,55 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+
+	// Enregistre le driver "pgx" auprès de database/sql.
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresRepo est un struct pour interagir avec PostgreSQL. Il contient une connexion à la base.
+// Les commandes sont stockées dans la table orders et leurs articles dans la table line_items.
+type PostgresRepo struct {
+	DB *sql.DB
+}
+
+// postgresMigrations décrit l'application des migrations PostgreSQL.
+var postgresMigrations = migrationSet{
+	dir:    "migrations/postgres",
+	lock:   `SELECT pg_advisory_xact_lock(7268451093)`,
+	insert: `INSERT INTO schema_migrations (version) VALUES ($1)`,
+}
+
+// Migrate applique les migrations de schéma manquantes.
+func (r *PostgresRepo) Migrate(ctx context.Context) error {
+	return postgresMigrations.apply(ctx, r.DB)
+}
+
+// Insert ajoute une nouvelle commande et ses articles dans une transaction.
+func (r *PostgresRepo) Insert(ctx context.Context, order model.Order) error {
+	return sqlRepo{r.DB}.Insert(ctx, order)
+}
+
+// FindByID trouve une commande par son ID.
+func (r *PostgresRepo) FindByID(ctx context.Context, id uint64, opts FindOptions) (model.Order, error) {
+	return sqlRepo{r.DB}.FindByID(ctx, id, opts)
+}
+
+// PurgeDeleted supprime définitivement les commandes supprimées avant la date donnée, et leurs articles.
+func (r *PostgresRepo) PurgeDeleted(ctx context.Context, before time.Time) (uint64, error) {
+	return sqlRepo{r.DB}.PurgeDeleted(ctx, before)
+}
+
+// Update met à jour une commande existante et remplace ses articles dans une transaction.
+func (r *PostgresRepo) Update(ctx context.Context, order model.Order) error {
+	return sqlRepo{r.DB}.Update(ctx, order)
+}
+
+// FindAll trouve toutes les commandes avec une pagination par clé, de la plus récente à la plus ancienne.
+func (r *PostgresRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	return sqlRepo{r.DB}.FindAll(ctx, page)
+}
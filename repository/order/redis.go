@@ -5,22 +5,117 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/SamMebarek/orders-api/audit"
+	"github.com/SamMebarek/orders-api/event"
 	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
 
 	"github.com/redis/go-redis/v9"
 )
 
 // RedisRepo est un struct pour interagir avec Redis. Il contient un client Redis.
 type RedisRepo struct {
-	Client *redis.Client
+	Client       *redis.Client
+	StreamMaxLen int64 // Longueur approximative maximale du stream d'événements, 0 pour ne pas le tronquer.
+
+	// EventsClient sert aux lectures bloquantes du stream d'événements par ReadEvents, Client s'il est nil.
+	// Chaque lecture occupe une connexion le temps de son attente : un client séparé évite qu'elles épuisent
+	// les connexions des autres opérations.
+	EventsClient *redis.Client
 }
 
+// EventsStream est le stream Redis des événements des commandes (voir le package event).
+// Chaque entrée a deux champs : "type", le type de l'événement, et "event", l'événement en JSON.
+// Les événements y sont ajoutés dans la même transaction que la modification de la commande.
+const EventsStream = "orders:events"
+
 // orderIDKey génère une clé Redis pour une commande en utilisant son ID.
 func orderIDKey(id uint64) string {
 	return fmt.Sprintf("order:%d", id)
 }
 
+// orderHistoryKey génère la clé Redis de l'historique d'une commande.
+// Cet historique est une liste d'entrées JSON, de la plus ancienne à la plus récente.
+func orderHistoryKey(id uint64) string {
+	return fmt.Sprintf("order:%d:history", id)
+}
+
+// customerOrdersKey génère la clé Redis de l'index des commandes d'un client.
+// Cet index est un sorted set des clés de commandes, dont le score est la date de création.
+func customerOrdersKey(customerID uuid.UUID) string {
+	return fmt.Sprintf("customer:%s:orders", customerID)
+}
+
+// Clés des index triés communs à toutes les commandes.
+const (
+	createdOrdersKey   = "orders:created"   // Toutes les commandes, par date de création.
+	shippedOrdersKey   = "orders:shipped"   // Commandes expédiées, par date d'expédition.
+	completedOrdersKey = "orders:completed" // Commandes finalisées, par date de finalisation.
+	activeOrdersKey    = "orders:active"    // Commandes non supprimées, par date de création.
+	deletedOrdersKey   = "orders:deleted"   // Commandes supprimées, par date de suppression.
+)
+
+// statusOrdersKey génère la clé Redis de l'index des commandes d'un statut.
+// Cet index est un sorted set des clés de commandes, dont le score est la date de création,
+// ce qui permet de combiner filtre de statut et intervalle de dates en une seule requête.
+func statusOrdersKey(status model.Status) string {
+	return fmt.Sprintf("orders:status:%s", status)
+}
+
+// timeScore retourne le score d'une date dans les index triés.
+// Les microsecondes tiennent sans perte dans le float64 d'un score Redis.
+func timeScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// createdScore retourne le score d'une commande dans les index triés par date de création.
+// Les microsecondes tiennent sans perte dans le float64 d'un score Redis.
+func createdScore(order model.Order) float64 {
+	if order.CreatedAt == nil {
+		return 0
+	}
+	return timeScore(*order.CreatedAt)
+}
+
+// indexOrder ajoute une commande à tous les index secondaires.
+func indexOrder(ctx context.Context, pipe redis.Pipeliner, order model.Order) {
+	key := orderIDKey(order.OrderID)
+	created := redis.Z{Score: createdScore(order), Member: key}
+
+	pipe.ZAdd(ctx, customerOrdersKey(order.CustomerID), created)
+	pipe.ZAdd(ctx, createdOrdersKey, created)
+	pipe.ZAdd(ctx, statusOrdersKey(order.CurrentStatus()), created)
+	if order.ShippedAt != nil {
+		pipe.ZAdd(ctx, shippedOrdersKey, redis.Z{Score: timeScore(*order.ShippedAt), Member: key})
+	}
+	if order.CompletedAt != nil {
+		pipe.ZAdd(ctx, completedOrdersKey, redis.Z{Score: timeScore(*order.CompletedAt), Member: key})
+	}
+	if order.DeletedAt != nil {
+		pipe.ZAdd(ctx, deletedOrdersKey, redis.Z{Score: timeScore(*order.DeletedAt), Member: key})
+	} else {
+		pipe.ZAdd(ctx, activeOrdersKey, created)
+	}
+}
+
+// unindexOrder retire une commande de tous les index secondaires.
+func unindexOrder(ctx context.Context, pipe redis.Pipeliner, order model.Order) {
+	key := orderIDKey(order.OrderID)
+
+	pipe.ZRem(ctx, customerOrdersKey(order.CustomerID), key)
+	pipe.ZRem(ctx, createdOrdersKey, key)
+	pipe.ZRem(ctx, statusOrdersKey(order.CurrentStatus()), key)
+	pipe.ZRem(ctx, shippedOrdersKey, key)
+	pipe.ZRem(ctx, completedOrdersKey, key)
+	pipe.ZRem(ctx, activeOrdersKey, key)
+	pipe.ZRem(ctx, deletedOrdersKey, key)
+}
+
 // Insert ajoute une nouvelle commande dans Redis.
 func (r *RedisRepo) Insert(ctx context.Context, order model.Order) error {
 	// Convertit la commande en JSON.
@@ -29,26 +124,48 @@ func (r *RedisRepo) Insert(ctx context.Context, order model.Order) error {
 		return fmt.Errorf("failed to marshal order: %w", err)
 	}
 
-	// Crée une transaction Redis pour assurer que toutes les opérations soient effectuées atomiquement.
-	txn := r.Client.TxPipeline()
-
-	// Ajoute la commande avec une clé unique.
-	res := txn.SetNX(ctx, orderIDKey(order.OrderID), string(data), 0)
-	// Gère les erreurs de l'opération SetNX.
-	if res.Err() != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to insert set: %w", res.Err())
+	// Prépare l'entrée d'historique de la création.
+	entry, err := historyEntry(ctx, nil, order)
+	if err != nil {
+		return err
 	}
 
-	// Ajoute la clé de la commande à un ensemble pour faciliter les recherches.
-	if err := txn.SAdd(ctx, "orders", orderIDKey(order.OrderID)).Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to add order to set: %w", err)
-	}
+	key := orderIDKey(order.OrderID)
 
-	// Exécute la transaction.
-	if _, err := txn.Exec(ctx); err != nil {
-		return fmt.Errorf("failed to exec: %w", err)
+	// Surveille la clé de la commande : si elle est créée avant l'exécution, la transaction échoue.
+	err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// Vérifie qu'aucune commande n'utilise déjà cet ID.
+		exists, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check order: %w", err)
+		} else if exists > 0 {
+			return ErrAlreadyExists
+		}
+
+		// Crée une transaction Redis pour assurer que toutes les opérations soient effectuées atomiquement.
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// Ajoute la commande avec une clé unique.
+			pipe.Set(ctx, key, string(data), 0)
+			// Ajoute la clé de la commande à un ensemble pour faciliter les recherches.
+			pipe.SAdd(ctx, "orders", key)
+			// Ajoute la clé de la commande aux index du client, des dates et du statut.
+			indexOrder(ctx, pipe, order)
+			// Ajoute la création à l'historique de la commande.
+			pipe.RPush(ctx, orderHistoryKey(order.OrderID), entry)
+			// Publie l'événement de création.
+			return r.publish(ctx, pipe, nil, order)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to exec: %w", err)
+		}
+
+		return nil
+	}, key)
+	// Une clé modifiée pendant la transaction signifie qu'une commande concurrente a pris cet ID.
+	if errors.Is(err, ErrAlreadyExists) || errors.Is(err, redis.TxFailedErr) {
+		return ErrAlreadyExists
+	} else if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
 	}
 
 	return nil
@@ -57,10 +174,31 @@ func (r *RedisRepo) Insert(ctx context.Context, order model.Order) error {
 // ErrNotExist est une erreur retournée lorsqu'une commande n'est pas trouvée dans Redis.
 var ErrNotExist = errors.New("order does not exist")
 
+// ErrAlreadyExists est une erreur retournée lorsqu'une commande avec le même ID existe déjà.
+var ErrAlreadyExists = errors.New("order already exists")
+
+// ErrVersionConflict est une erreur retournée lorsqu'une commande a été modifiée depuis sa lecture.
+var ErrVersionConflict = errors.New("order version conflict")
+
 // FindByID trouve une commande par son ID.
-func (r *RedisRepo) FindByID(ctx context.Context, id uint64) (model.Order, error) {
+func (r *RedisRepo) FindByID(ctx context.Context, id uint64, opts FindOptions) (model.Order, error) {
+	order, err := getOrder(ctx, r.Client, orderIDKey(id))
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	// Une commande supprimée n'est retournée que sur demande.
+	if order.DeletedAt != nil && !opts.IncludeDeleted {
+		return model.Order{}, ErrNotExist
+	}
+
+	return order, nil
+}
+
+// getOrder obtient et décode la commande stockée sous la clé donnée.
+func getOrder(ctx context.Context, c redis.Cmdable, key string) (model.Order, error) {
 	// Obtient la commande de Redis en utilisant sa clé.
-	value, err := r.Client.Get(ctx, orderIDKey(id)).Result()
+	value, err := c.Get(ctx, key).Result()
 	// Gère les cas où la commande n'existe pas ou d'autres erreurs Redis.
 	if errors.Is(err, redis.Nil) {
 		return model.Order{}, ErrNotExist
@@ -68,9 +206,9 @@ func (r *RedisRepo) FindByID(ctx context.Context, id uint64) (model.Order, error
 		return model.Order{}, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	// Convertit la commande JSON en struct Order.
+	// Convertit la commande JSON en struct Order, y compris les commandes enregistrées sans devise.
 	var order model.Order
-	err = json.Unmarshal([]byte(value), &order)
+	err = model.UnmarshalStored([]byte(value), &order)
 	if err != nil {
 		return model.Order{}, fmt.Errorf("failed to unmarshal order: %w", err)
 	}
@@ -78,48 +216,131 @@ func (r *RedisRepo) FindByID(ctx context.Context, id uint64) (model.Order, error
 	return order, nil
 }
 
-// DeleteByID supprime une commande de Redis en utilisant son ID.
-func (r *RedisRepo) DeleteByID(ctx context.Context, id uint64) error {
-	// Crée une transaction Redis.
-	txn := r.Client.TxPipeline()
-
-	// Supprime la commande de Redis.
-	err := txn.Del(ctx, orderIDKey(id)).Err()
-	if errors.Is(err, redis.Nil) {
-		txn.Discard()
-		return ErrNotExist
-	} else if err != nil {
-		txn.Discard()
-		return fmt.Errorf("get order: %w", err)
+// purgeBatchSize est le nombre maximal de commandes purgées par appel à PurgeDeleted.
+// Les suivantes le sont aux appels suivants.
+const purgeBatchSize = 1000
+
+// PurgeDeleted supprime définitivement de Redis les commandes supprimées avant la date donnée.
+func (r *RedisRepo) PurgeDeleted(ctx context.Context, before time.Time) (uint64, error) {
+	keys, err := r.Client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:     deletedOrdersKey,
+		Start:   "-inf",
+		Stop:    "(" + strconv.FormatInt(before.UnixMicro(), 10),
+		ByScore: true,
+		Count:   purgeBatchSize,
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deleted orders: %w", err)
 	}
 
-	// Supprime la clé de la commande de l'ensemble.
-	if err := txn.SRem(ctx, "orders", orderIDKey(id)).Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to remove from orders set: %w", err)
+	var purged uint64
+	for _, key := range keys {
+		ok, err := r.purge(ctx, key, before)
+		if err != nil {
+			return purged, err
+		} else if ok {
+			purged++
+		}
 	}
 
-	// Exécute la transaction.
-	if _, err := txn.Exec(ctx); err != nil {
-		return fmt.Errorf("failed to exec: %w", err)
+	return purged, nil
+}
+
+// purge supprime définitivement une commande si elle est toujours supprimée depuis avant la date donnée.
+// Une commande restaurée ou modifiée pendant la purge est conservée.
+func (r *RedisRepo) purge(ctx context.Context, key string, before time.Time) (bool, error) {
+	// Surveille la clé de la commande : si elle est modifiée avant l'exécution, la transaction échoue.
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// Lit la commande pour vérifier sa suppression et connaître les index à mettre à jour.
+		order, err := getOrder(ctx, tx, key)
+		if errors.Is(err, ErrNotExist) {
+			// Retire une entrée d'index orpheline.
+			return tx.ZRem(ctx, deletedOrdersKey, key).Err()
+		} else if err != nil {
+			return err
+		}
+		if order.DeletedAt == nil || !order.DeletedAt.Before(before) {
+			return ErrNotExist
+		}
+
+		// Exécute la suppression dans une transaction.
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// Supprime la commande de Redis.
+			pipe.Del(ctx, key)
+			// Supprime la clé de la commande de l'ensemble.
+			pipe.SRem(ctx, "orders", key)
+			// Supprime la clé de la commande des index secondaires.
+			unindexOrder(ctx, pipe, order)
+			// Supprime son historique, soumis au même délai de rétention.
+			pipe.Del(ctx, orderHistoryKey(order.OrderID))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to exec: %w", err)
+		}
+
+		return nil
+	}, key)
+	if errors.Is(err, ErrNotExist) || errors.Is(err, redis.TxFailedErr) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to purge order: %w", err)
 	}
 
-	return nil
+	return true, nil
 }
 
-// Update met à jour une commande existante dans Redis.
+// Update met à jour une commande existante dans Redis si sa version n'a pas changé depuis sa lecture.
+// La commande, colis compris, est un seul document JSON : elle est réécrite entière dans la transaction.
 func (r *RedisRepo) Update(ctx context.Context, order model.Order) error {
+	expected := order.Version
+	order.Version++
+
 	// Convertit la commande en JSON pour la mise à jour.
 	data, err := json.Marshal(order)
 	if err != nil {
 		return fmt.Errorf("failed to marshal order: %w", err)
 	}
 
-	// Met à jour la commande dans Redis.
-	err = r.Client.SetXX(ctx, orderIDKey(order.OrderID), string(data), 0).Err()
-	// Gère les erreurs potentielles, y compris le cas où la commande n'existe pas.
-	if errors.Is(err, redis.Nil) {
-		return ErrNotExist
+	key := orderIDKey(order.OrderID)
+
+	// Surveille la clé de la commande : si elle est modifiée avant l'exécution, la transaction échoue.
+	err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// Lit la version actuelle pour la comparer et retirer ses entrées des index.
+		previous, err := getOrder(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if previous.Version != expected {
+			return ErrVersionConflict
+		}
+
+		// Prépare l'entrée d'historique à partir de la version lue.
+		entry, err := historyEntry(ctx, &previous, order)
+		if err != nil {
+			return err
+		}
+
+		// Met à jour la commande, ses index et son historique dans une transaction.
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetXX(ctx, key, string(data), 0)
+			unindexOrder(ctx, pipe, previous)
+			indexOrder(ctx, pipe, order)
+			pipe.RPush(ctx, orderHistoryKey(order.OrderID), entry)
+			return r.publish(ctx, pipe, &previous, order)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to exec: %w", err)
+		}
+
+		return nil
+	}, key)
+	// Gère les erreurs potentielles, y compris le cas où la commande n'existe pas
+	// et celui où elle a été modifiée pendant la transaction.
+	if errors.Is(err, ErrNotExist) || errors.Is(err, ErrVersionConflict) {
+		return err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return ErrVersionConflict
 	} else if err != nil {
 		return fmt.Errorf("failed to update order: %w", err)
 	}
@@ -127,59 +348,471 @@ func (r *RedisRepo) Update(ctx context.Context, order model.Order) error {
 	return nil
 }
 
-// FindAllPage est un struct pour paginer les résultats lors de la recherche de commandes.
-type FindAllPage struct {
-	Size   uint64 // Nombre de commandes à retourner par page.
-	Offset uint64 // Offset pour la pagination.
+// FindAll trouve toutes les commandes avec une pagination par clé, de la plus récente à la plus ancienne.
+// Les index triés par date de création sont parcourus ; les commandes de même date
+// sont départagées par ordre lexicographique décroissant de leur clé.
+// Lorsque plusieurs index sont concernés, leur intersection est calculée dans une clé temporaire.
+func (r *RedisRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	size := page.Size
+	if size == 0 {
+		size = defaultScanCount
+	}
+
+	// Sélectionne les index à intersecter. Tous ont la date de création pour score.
+	// Les commandes supprimées sont écartées par l'index des commandes actives.
+	var sources []string
+	if page.CustomerID != uuid.Nil {
+		sources = append(sources, customerOrdersKey(page.CustomerID))
+	}
+	if page.Status != "" {
+		sources = append(sources, statusOrdersKey(page.Status))
+	}
+	if !page.IncludeDeleted {
+		sources = append(sources, activeOrdersKey)
+	}
+	if len(sources) == 0 {
+		sources = append(sources, createdOrdersKey)
+	}
+
+	// Bornes de l'intervalle de dates de création : [CreatedAfter, CreatedBefore).
+	min, max := "-inf", "+inf"
+	if !page.CreatedAfter.IsZero() {
+		min = strconv.FormatInt(page.CreatedAfter.UnixMicro(), 10)
+	}
+	if !page.CreatedBefore.IsZero() {
+		max = "(" + strconv.FormatInt(page.CreatedBefore.UnixMicro(), 10)
+	}
+
+	txn := r.Client.TxPipeline()
+
+	// L'intersection est conservée le temps de compléter la page, et expire si sa suppression échoue.
+	source := sources[0]
+	if len(sources) > 1 {
+		source = "orders:tmp:" + uuid.NewString()
+		txn.ZInterStore(ctx, source, &redis.ZStore{Keys: sources, Aggregate: "MIN"})
+		txn.Expire(ctx, source, time.Minute)
+		defer func() {
+			if err := r.Client.Del(ctx, source).Err(); err != nil {
+				fmt.Println("failed to delete", source, err)
+			}
+		}()
+	}
+
+	// Compte toutes les commandes satisfaisant les filtres, indépendamment de la page.
+	total := txn.ZCount(ctx, source, min, max)
+
+	// Après un cursor, ne garde que les commandes strictement plus anciennes,
+	// et récupère à part celles de même date pour les départager par ID, si cette date est dans l'intervalle.
+	// Comme dans les autres dépôts, un cursor plus récent que l'intervalle n'en retire aucune commande,
+	// et un cursor plus ancien donne une page vide.
+	pageMax := max
+	var ties *redis.ZSliceCmd
+	if page.After != nil {
+		score := page.After.CreatedAt.UnixMicro()
+		if page.CreatedBefore.IsZero() || score < page.CreatedBefore.UnixMicro() {
+			pageMax = "(" + strconv.FormatInt(score, 10)
+			if page.CreatedAfter.IsZero() || score >= page.CreatedAfter.UnixMicro() {
+				ties = txn.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+					Key:     source,
+					Start:   score,
+					Stop:    score,
+					ByScore: true,
+					Rev:     true,
+				})
+			}
+		}
+	}
+
+	// Récupère une clé de plus que la taille de page pour savoir s'il reste des commandes.
+	older := txn.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+		Key:     source,
+		Start:   min,
+		Stop:    pageMax,
+		ByScore: true,
+		Rev:     true,
+		Count:   int64(size + 1),
+	})
+
+	if _, err := txn.Exec(ctx); err != nil {
+		return FindResult{}, fmt.Errorf("failed to get order ids: %w", err)
+	}
+
+	// Redis range les commandes de même date par ordre lexicographique de leur clé, où "order:9" suit "order:10".
+	// La dernière date lue peut donc avoir été coupée au mauvais endroit : elle est relue en entier,
+	// puis les commandes de même date sont départagées par ID, comme dans les autres dépôts.
+	fetched := older.Val()
+	if uint64(len(fetched)) > size {
+		boundary := strconv.FormatInt(int64(fetched[len(fetched)-1].Score), 10)
+		group, err := r.Client.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+			Key:     source,
+			Start:   boundary,
+			Stop:    boundary,
+			ByScore: true,
+		}).Result()
+		if err != nil {
+			return FindResult{}, fmt.Errorf("failed to get order ids: %w", err)
+		}
+
+		kept := fetched[:0]
+		for _, z := range fetched {
+			if z.Score != fetched[len(fetched)-1].Score {
+				kept = append(kept, z)
+			}
+		}
+		fetched = append(kept, group...)
+	}
+
+	// Assemble la page : commandes de même date que le cursor mais d'ID inférieur, puis les plus anciennes.
+	var entries []scoredOrder
+	if ties != nil {
+		tied, err := scoredOrders(ties.Val())
+		if err != nil {
+			return FindResult{}, err
+		}
+		for _, e := range tied {
+			if e.id < page.After.OrderID {
+				entries = append(entries, e)
+			}
+		}
+	}
+	rest, err := scoredOrders(fetched)
+	if err != nil {
+		return FindResult{}, err
+	}
+	entries = append(entries, rest...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].score != entries[j].score {
+			return entries[i].score > entries[j].score
+		}
+		return entries[i].id > entries[j].id
+	})
+
+	res := FindResult{
+		Total: uint64(total.Val()),
+	}
+
+	// S'il reste des commandes, le cursor pointe sur la dernière commande retournée.
+	if uint64(len(entries)) > size {
+		entries = entries[:size]
+		last := entries[size-1]
+
+		res.Next = &Cursor{
+			CreatedAt: time.UnixMicro(int64(last.score)).UTC(),
+			OrderID:   last.id,
+		}
+	}
+
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = orderIDKey(e.id)
+	}
+
+	orders, err := r.getOrders(ctx, keys)
+	if err != nil {
+		return FindResult{}, err
+	}
+	res.Orders = orders
+
+	// Retourne les commandes trouvées avec le cursor pour la pagination.
+	return res, nil
 }
 
-// FindResult est un struct pour retourner les résultats d'une recherche de commandes.
-type FindResult struct {
-	Orders []model.Order // Liste des commandes trouvées.
-	Cursor uint64        // Cursor pour la pagination.
+// scoredOrder est une commande d'un index, avec sa date de création en microsecondes pour score.
+type scoredOrder struct {
+	id    uint64
+	score float64
 }
 
-// FindAll trouve toutes les commandes avec une pagination.
-func (r *RedisRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
-	// Utilise SScan pour récupérer les clés des commandes de l'ensemble Redis.
-	res := r.Client.SScan(ctx, "orders", page.Offset, "*", int64(page.Size))
+// scoredOrders lit les IDs des commandes d'un index à partir de leurs clés.
+func scoredOrders(entries []redis.Z) ([]scoredOrder, error) {
+	orders := make([]scoredOrder, len(entries))
+	for i, z := range entries {
+		id, err := strconv.ParseUint(strings.TrimPrefix(z.Member.(string), "order:"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse order key: %w", err)
+		}
+		orders[i] = scoredOrder{id: id, score: z.Score}
+	}
+	return orders, nil
+}
 
-	// Obtient les résultats du SScan.
-	keys, cursor, err := res.Result()
+// History retourne l'historique d'une commande, de la plus ancienne modification à la plus récente.
+// Les commandes créées avant l'ajout de l'historique n'ont que les entrées de leurs modifications suivantes.
+func (r *RedisRepo) History(ctx context.Context, id uint64) ([]audit.Entry, error) {
+	values, err := r.Client.LRange(ctx, orderHistoryKey(id), 0, -1).Result()
 	if err != nil {
-		return FindResult{}, fmt.Errorf("failed to get order ids: %w", err)
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	entries := make([]audit.Entry, 0, len(values))
+	for _, value := range values {
+		var entry audit.Entry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history entry: %w", err)
+		}
+		entries = append(entries, entry)
 	}
 
+	return entries, nil
+}
+
+// LastEventID retourne l'ID de la dernière entrée du stream des événements, ou "0-0" s'il est vide.
+func (r *RedisRepo) LastEventID(ctx context.Context) (string, error) {
+	entries, err := r.Client.XRevRangeN(ctx, EventsStream, "+", "-", 1).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read last event: %w", err)
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// ReadEvents lit au plus count événements du stream après l'entrée after, en attendant au plus block.
+// Les entrées plus anciennes que after et déjà tronquées du stream sont perdues.
+func (r *RedisRepo) ReadEvents(ctx context.Context, after string, count int64, block time.Duration) ([]StreamEvent, error) {
+	client := r.EventsClient
+	if client == nil {
+		client = r.Client
+	}
+
+	streams, err := client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{EventsStream, after},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	// Aucun événement publié pendant l'attente.
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	var events []StreamEvent
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			data, _ := msg.Values["event"].(string)
+
+			var e event.Event
+			if err := model.UnmarshalStored([]byte(data), &e); err != nil {
+				return nil, fmt.Errorf("failed to decode event %s: %w", msg.ID, err)
+			}
+			events = append(events, StreamEvent{ID: msg.ID, Event: e})
+		}
+	}
+
+	return events, nil
+}
+
+// publish ajoute à la transaction l'événement du passage de before à after.
+func (r *RedisRepo) publish(ctx context.Context, pipe redis.Pipeliner, before *model.Order, after model.Order) error {
+	e := event.FromChange(before, after)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: EventsStream,
+		MaxLen: r.StreamMaxLen,
+		Approx: true,
+		Values: []any{"type", string(e.Type), "event", string(data)},
+	})
+
+	return nil
+}
+
+// historyEntry crée et sérialise l'entrée d'historique du passage de before à after.
+func historyEntry(ctx context.Context, before *model.Order, after model.Order) (string, error) {
+	entry, err := audit.NewEntry(ctx, before, after)
+	if err != nil {
+		return "", fmt.Errorf("failed to create history entry: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// getOrders obtient les commandes correspondant aux clés données, dans le même ordre.
+func (r *RedisRepo) getOrders(ctx context.Context, keys []string) ([]model.Order, error) {
 	// Si aucune clé n'est trouvée, retourne un résultat vide.
 	if len(keys) == 0 {
-		return FindResult{
-			Orders: []model.Order{},
-		}, nil
+		return []model.Order{}, nil
 	}
 
 	// Obtient les commandes de Redis en utilisant les clés trouvées.
 	xs, err := r.Client.MGet(ctx, keys...).Result()
 	if err != nil {
-		return FindResult{}, fmt.Errorf("failed to get orders: %w", err)
+		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
 
 	// Convertit les commandes de JSON en struct Order.
-	orders := make([]model.Order, len(xs))
-	for i, x := range xs {
-		x := x.(string)
+	orders := make([]model.Order, 0, len(xs))
+	for _, x := range xs {
+		// Ignore les commandes supprimées entre la lecture de l'index et MGet.
+		x, ok := x.(string)
+		if !ok {
+			continue
+		}
+
 		var order model.Order
+		err := model.UnmarshalStored([]byte(x), &order)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal order: %w", err)
+		}
+
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
 
-		err := json.Unmarshal([]byte(x), &order)
+// migrationsKey est l'ensemble Redis des migrations de données déjà appliquées.
+const migrationsKey = "orders:migrations"
+
+// redisMigrations liste, dans l'ordre, les migrations de données de RedisRepo.
+var redisMigrations = []struct {
+	version string
+	apply   func(r *RedisRepo, ctx context.Context, order model.Order) error
+}{
+	{"0001_customer_index", (*RedisRepo).indexCustomer},
+	{"0002_status_date_index", (*RedisRepo).reindex},
+	{"0003_store_status", (*RedisRepo).storeStatus},
+	{"0004_active_index", (*RedisRepo).reindex},
+	{"0005_store_totals", (*RedisRepo).storeTotals},
+}
+
+// Migrate applique aux commandes existantes les migrations de données manquantes,
+// par exemple pour construire un nouvel index.
+func (r *RedisRepo) Migrate(ctx context.Context) error {
+	for _, m := range redisMigrations {
+		applied, err := r.Client.SIsMember(ctx, migrationsKey, m.version).Result()
 		if err != nil {
-			return FindResult{}, fmt.Errorf("failed to unmarshal order: %w", err)
+			return fmt.Errorf("failed to check migration %s: %w", m.version, err)
+		} else if applied {
+			continue
+		}
+
+		// Parcourt toutes les commandes de l'ensemble "orders".
+		var cursor uint64
+		for {
+			keys, next, err := r.Client.SScan(ctx, "orders", cursor, "*", 100).Result()
+			if err != nil {
+				return fmt.Errorf("failed to scan orders: %w", err)
+			}
+
+			orders, err := r.getOrders(ctx, keys)
+			if err != nil {
+				return err
+			}
+
+			for _, order := range orders {
+				if err := m.apply(r, ctx, order); err != nil {
+					return fmt.Errorf("failed to apply migration %s: %w", m.version, err)
+				}
+			}
+
+			if next == 0 {
+				break
+			}
+			cursor = next
 		}
 
-		orders[i] = order
+		if err := r.Client.SAdd(ctx, migrationsKey, m.version).Err(); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.version, err)
+		}
 	}
 
-	// Retourne les commandes trouvées avec le cursor pour la pagination.
-	return FindResult{
-		Orders: orders,
-		Cursor: cursor,
-	}, nil
+	return nil
+}
+
+// indexCustomer ajoute une commande existante à l'index de son client.
+func (r *RedisRepo) indexCustomer(ctx context.Context, order model.Order) error {
+	return r.Client.ZAdd(ctx, customerOrdersKey(order.CustomerID), redis.Z{
+		Score:  createdScore(order),
+		Member: orderIDKey(order.OrderID),
+	}).Err()
+}
+
+// reindex ajoute une commande existante à tous les index secondaires.
+func (r *RedisRepo) reindex(ctx context.Context, order model.Order) error {
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		indexOrder(ctx, pipe, order)
+		return nil
+	})
+	return err
+}
+
+// storeStatus enregistre dans une commande existante l'état jusque-là déduit de ses dates.
+// Ses index ne changent pas, puisqu'ils utilisaient déjà cet état.
+func (r *RedisRepo) storeStatus(ctx context.Context, order model.Order) error {
+	if order.Status != "" {
+		return nil
+	}
+
+	return r.rewrite(ctx, order.OrderID, func(current *model.Order) error {
+		current.Status = current.CurrentStatus()
+		return nil
+	})
+}
+
+// storeTotals enregistre dans une commande existante sa devise et ses totaux.
+// Les prix des commandes enregistrées sans devise sont en unités mineures de model.LegacyCurrency.
+func (r *RedisRepo) storeTotals(ctx context.Context, order model.Order) error {
+	if order.Currency != "" {
+		return nil
+	}
+
+	return r.rewrite(ctx, order.OrderID, func(current *model.Order) error {
+		if err := current.ComputeTotals(); err != nil {
+			return fmt.Errorf("failed to compute totals of order %d: %w", current.OrderID, err)
+		}
+		return nil
+	})
+}
+
+// rewriteMaxAttempts est le nombre maximal de tentatives de rewrite sur une commande modifiée pendant la réécriture.
+const rewriteMaxAttempts = 10
+
+// rewrite applique change à une commande existante puis l'enregistre, sans modifier sa version ni ses index.
+// Une commande modifiée entre sa lecture et son enregistrement est relue, et change lui est appliqué à nouveau.
+func (r *RedisRepo) rewrite(ctx context.Context, id uint64, change func(current *model.Order) error) error {
+	key := orderIDKey(id)
+
+	for attempt := 1; ; attempt++ {
+		// Relit la commande sous WATCH pour ne pas écraser une mise à jour concurrente.
+		err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+			current, err := getOrder(ctx, tx, key)
+			if errors.Is(err, ErrNotExist) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if err := change(&current); err != nil {
+				return err
+			}
+			data, err := json.Marshal(current)
+			if err != nil {
+				return fmt.Errorf("failed to marshal order: %w", err)
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetXX(ctx, key, string(data), 0)
+				return nil
+			})
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		if attempt == rewriteMaxAttempts {
+			return fmt.Errorf("failed to rewrite order %d after %d attempts: %w", id, attempt, err)
+		}
+	}
 }
@@ -0,0 +1,118 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	"github.com/SamMebarek/orders-api/audit"
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// Repository décrit les opérations de stockage des commandes.
+// Les gestionnaires HTTP en dépendent afin de pouvoir changer de base de données sans modifier leur code.
+type Repository interface {
+	// Insert ajoute une nouvelle commande. Retourne ErrAlreadyExists si son ID est déjà utilisé.
+	Insert(ctx context.Context, order model.Order) error
+	// FindByID trouve une commande par son ID. Retourne ErrNotExist si elle n'existe pas,
+	// ou si elle a été supprimée et que opts.IncludeDeleted n'est pas demandé.
+	FindByID(ctx context.Context, id uint64, opts FindOptions) (model.Order, error)
+	// Update met à jour une commande existante. Retourne ErrNotExist si elle n'existe pas.
+	// order.Version doit être la version lue : la commande est enregistrée avec la version suivante,
+	// ou ErrVersionConflict est retournée si elle a été modifiée entre-temps.
+	// La suppression et la restauration d'une commande passent aussi par Update, via DeletedAt.
+	Update(ctx context.Context, order model.Order) error
+	// FindAll trouve toutes les commandes avec une pagination, de la plus récente à la plus ancienne.
+	FindAll(ctx context.Context, page FindAllPage) (FindResult, error)
+	// PurgeDeleted supprime définitivement les commandes supprimées avant la date donnée,
+	// et retourne leur nombre.
+	PurgeDeleted(ctx context.Context, before time.Time) (uint64, error)
+}
+
+// HistoryReader est implémentée par les dépôts qui conservent l'historique des modifications des commandes.
+// Chaque Insert et Update y ajoute une entrée, dans la même transaction que la modification.
+type HistoryReader interface {
+	// History retourne l'historique d'une commande, de la plus ancienne modification à la plus récente.
+	History(ctx context.Context, id uint64) ([]audit.Entry, error)
+}
+
+// EventReader est implémentée par les dépôts qui publient les événements des commandes dans un journal ordonné.
+type EventReader interface {
+	// LastEventID retourne la position de la dernière entrée du journal, à passer à ReadEvents
+	// pour ne lire que les événements suivants.
+	LastEventID(ctx context.Context) (string, error)
+	// ReadEvents retourne au plus count événements publiés après la position after, dans l'ordre de publication.
+	// S'il n'y en a aucun, attend au plus block qu'un événement soit publié.
+	ReadEvents(ctx context.Context, after string, count int64, block time.Duration) ([]StreamEvent, error)
+}
+
+// StreamEvent est un événement lu dans le journal, avec sa position.
+type StreamEvent struct {
+	ID    string      // Position de l'événement dans le journal.
+	Event event.Event // Événement publié.
+}
+
+// FindOptions modifie la recherche d'une commande par son ID.
+type FindOptions struct {
+	IncludeDeleted bool // Retourne aussi une commande supprimée.
+}
+
+// Cursor désigne la dernière commande d'une page.
+// Les commandes sont listées de la plus récente à la plus ancienne, l'ID départageant les dates égales :
+// la page suivante commence à la première commande plus ancienne que le cursor.
+type Cursor struct {
+	CreatedAt time.Time // Date de création de la dernière commande de la page.
+	OrderID   uint64    // ID de la dernière commande de la page.
+}
+
+// FindAllPage est un struct pour paginer et filtrer les résultats lors de la recherche de commandes.
+type FindAllPage struct {
+	Size           uint64       // Nombre de commandes à retourner par page.
+	After          *Cursor      // Dernière commande de la page précédente, nil pour la première page.
+	CustomerID     uuid.UUID    // Filtre sur le client, uuid.Nil pour toutes les commandes.
+	Status         model.Status // Filtre sur le statut, vide pour tous les statuts.
+	CreatedAfter   time.Time    // Commandes créées à partir de cette date incluse, zéro pour ne pas filtrer.
+	CreatedBefore  time.Time    // Commandes créées avant cette date exclue, zéro pour ne pas filtrer.
+	IncludeDeleted bool         // Inclut les commandes supprimées.
+}
+
+// matches indique si une commande satisfait les filtres de la page.
+func (p FindAllPage) matches(order model.Order) bool {
+	if !p.IncludeDeleted && order.DeletedAt != nil {
+		return false
+	}
+	if p.CustomerID != uuid.Nil && order.CustomerID != p.CustomerID {
+		return false
+	}
+	if p.Status != "" && order.CurrentStatus() != p.Status {
+		return false
+	}
+	if !p.CreatedAfter.IsZero() && (order.CreatedAt == nil || order.CreatedAt.Before(p.CreatedAfter)) {
+		return false
+	}
+	if !p.CreatedBefore.IsZero() && (order.CreatedAt == nil || !order.CreatedAt.Before(p.CreatedBefore)) {
+		return false
+	}
+	return true
+}
+
+// FindResult est un struct pour retourner les résultats d'une recherche de commandes.
+type FindResult struct {
+	Orders []model.Order // Liste des commandes trouvées.
+	Next   *Cursor       // Cursor de la page suivante, nil s'il n'y en a pas.
+	Total  uint64        // Nombre total de commandes satisfaisant les filtres.
+}
+
+// Vérifie à la compilation que les implémentations respectent l'interface.
+var (
+	_ Repository = (*RedisRepo)(nil)
+	_ Repository = (*PostgresRepo)(nil)
+	_ Repository = (*SQLiteRepo)(nil)
+	_ Repository = (*MemoryRepo)(nil)
+
+	_ HistoryReader = (*RedisRepo)(nil)
+	_ HistoryReader = (*MemoryRepo)(nil)
+
+	_ EventReader = (*RedisRepo)(nil)
+)
@@ -0,0 +1,576 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// backend ouvre un dépôt vide d'une implémentation de Repository.
+type backend struct {
+	name string
+	open func(t *testing.T) Repository
+}
+
+// backends retourne les implémentations disponibles : mémoire, SQLite et Redis (miniredis) toujours,
+// PostgreSQL si TEST_POSTGRES_URL désigne une base de test, dont les tables sont vidées.
+func backends() []backend {
+	list := []backend{
+		{"memory", func(t *testing.T) Repository {
+			return &MemoryRepo{}
+		}},
+		{"sqlite", func(t *testing.T) Repository {
+			db, err := OpenSQLite(filepath.Join(t.TempDir(), "orders.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			repo := &SQLiteRepo{DB: db}
+			if err := repo.Migrate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			return repo
+		}},
+		{"redis", func(t *testing.T) Repository {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			return &RedisRepo{Client: client}
+		}},
+	}
+
+	if url := os.Getenv("TEST_POSTGRES_URL"); url != "" {
+		list = append(list, backend{"postgres", func(t *testing.T) Repository {
+			db, err := sql.Open("pgx", url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			repo := &PostgresRepo{DB: db}
+			if err := repo.Migrate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(`TRUNCATE orders, line_items`); err != nil {
+				t.Fatal(err)
+			}
+			return repo
+		}})
+	}
+
+	return list
+}
+
+// testCustomer est le client des commandes de test.
+var testCustomer = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+
+// newOrder retourne une commande en attente créée à la date created, à la microseconde près comme dans Redis.
+func newOrder(id uint64, created time.Time) model.Order {
+	created = created.UTC().Truncate(time.Microsecond)
+	price := model.Money{Amount: 1000, Currency: "EUR"}
+	return model.Order{
+		OrderID:    id,
+		Version:    1,
+		Status:     model.StatusPending,
+		CustomerID: testCustomer,
+		LineItems: []model.LineItem{
+			{ItemID: uuid.MustParse("22222222-2222-2222-2222-222222222222"), Quantity: 2, Price: price},
+		},
+		Currency:  "EUR",
+		Subtotal:  model.Money{Amount: 2000, Currency: "EUR"},
+		Total:     model.Money{Amount: 2000, Currency: "EUR"},
+		CreatedAt: &created,
+	}
+}
+
+// equalOrders indique si deux commandes ont les mêmes champs enregistrés, les dates étant comparées
+// indépendamment de leur fuseau.
+func equalOrders(a, b model.Order) bool {
+	sameTime := func(x, y *time.Time) bool {
+		return (x == nil) == (y == nil) && (x == nil || x.Equal(*y))
+	}
+	return a.OrderID == b.OrderID && a.Version == b.Version && a.CurrentStatus() == b.CurrentStatus() &&
+		a.CustomerID == b.CustomerID && reflect.DeepEqual(a.LineItems, b.LineItems) &&
+		a.Currency == b.Currency && a.Subtotal == b.Subtotal && a.Total == b.Total &&
+		sameTime(a.CreatedAt, b.CreatedAt) && sameTime(a.DeletedAt, b.DeletedAt) && a.DeletedBy == b.DeletedBy
+}
+
+// ids retourne les IDs des commandes, dans leur ordre.
+func ids(orders []model.Order) []uint64 {
+	list := make([]uint64, 0, len(orders))
+	for _, o := range orders {
+		list = append(list, o.OrderID)
+	}
+	return list
+}
+
+// TestRepository vérifie que toutes les implémentations de Repository se comportent de la même façon.
+func TestRepository(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			t.Run("insert and find", func(t *testing.T) { testInsertFind(t, b.open(t)) })
+			t.Run("update", func(t *testing.T) { testUpdate(t, b.open(t)) })
+			t.Run("delete", func(t *testing.T) { testDelete(t, b.open(t)) })
+			t.Run("find all ties", func(t *testing.T) { testFindAllTies(t, b.open(t)) })
+			t.Run("find all filters", func(t *testing.T) { testFindAllFilters(t, b.open(t)) })
+		})
+	}
+}
+
+func testInsertFind(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	o := newOrder(1, time.Now())
+
+	if err := repo.Insert(ctx, o); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := repo.Insert(ctx, o); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Insert() twice error = %v, want %v", err, ErrAlreadyExists)
+	}
+
+	found, err := repo.FindByID(ctx, o.OrderID, FindOptions{})
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if !equalOrders(found, o) {
+		t.Errorf("FindByID() = %+v, want %+v", found, o)
+	}
+
+	if _, err := repo.FindByID(ctx, 2, FindOptions{}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("FindByID() of a missing order error = %v, want %v", err, ErrNotExist)
+	}
+}
+
+func testUpdate(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	o := newOrder(1, time.Now())
+	if err := repo.Insert(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	// L'enregistrement de la version lue passe à la version suivante.
+	o.Status = model.StatusPaid
+	if err := repo.Update(ctx, o); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	found, err := repo.FindByID(ctx, o.OrderID, FindOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Version = 2
+	if !equalOrders(found, o) {
+		t.Errorf("FindByID() after Update() = %+v, want %+v", found, o)
+	}
+
+	// Une version périmée est refusée sans modifier la commande.
+	stale := o
+	stale.Version = 1
+	stale.Status = model.StatusCancelled
+	if err := repo.Update(ctx, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Update() of a stale version error = %v, want %v", err, ErrVersionConflict)
+	}
+	found, _ = repo.FindByID(ctx, o.OrderID, FindOptions{})
+	if found.Version != 2 || found.CurrentStatus() != model.StatusPaid {
+		t.Errorf("order after a conflict = version %d, %s, want version 2, paid", found.Version, found.CurrentStatus())
+	}
+
+	if err := repo.Update(ctx, newOrder(2, time.Now())); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Update() of a missing order error = %v, want %v", err, ErrNotExist)
+	}
+}
+
+func testDelete(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	kept, deleted := newOrder(1, now), newOrder(2, now.Add(time.Second))
+	for _, o := range []model.Order{kept, deleted} {
+		if err := repo.Insert(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deletedAt := now.Add(time.Minute)
+	deleted.DeletedAt = &deletedAt
+	deleted.DeletedBy = "admin"
+	if err := repo.Update(ctx, deleted); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	deleted.Version++
+
+	// Une commande supprimée n'est retournée que sur demande.
+	if _, err := repo.FindByID(ctx, deleted.OrderID, FindOptions{}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("FindByID() of a deleted order error = %v, want %v", err, ErrNotExist)
+	}
+	found, err := repo.FindByID(ctx, deleted.OrderID, FindOptions{IncludeDeleted: true})
+	if err != nil || !equalOrders(found, deleted) {
+		t.Errorf("FindByID() with IncludeDeleted = %+v, %v, want %+v", found, err, deleted)
+	}
+
+	for _, tt := range []struct {
+		includeDeleted bool
+		want           []uint64
+	}{
+		{false, []uint64{1}},
+		{true, []uint64{2, 1}},
+	} {
+		res, err := repo.FindAll(ctx, FindAllPage{Size: 10, IncludeDeleted: tt.includeDeleted})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(res.Orders); !reflect.DeepEqual(got, tt.want) || res.Total != uint64(len(tt.want)) {
+			t.Errorf("FindAll(IncludeDeleted: %v) = %v (total %d), want %v", tt.includeDeleted, got, res.Total, tt.want)
+		}
+	}
+
+	// Seules les commandes supprimées avant la date donnée sont purgées.
+	if purged, err := repo.PurgeDeleted(ctx, deletedAt); err != nil || purged != 0 {
+		t.Errorf("PurgeDeleted() at the deletion date = %d, %v, want 0", purged, err)
+	}
+	if purged, err := repo.PurgeDeleted(ctx, deletedAt.Add(time.Second)); err != nil || purged != 1 {
+		t.Errorf("PurgeDeleted() = %d, %v, want 1", purged, err)
+	}
+	if _, err := repo.FindByID(ctx, deleted.OrderID, FindOptions{IncludeDeleted: true}); !errors.Is(err, ErrNotExist) {
+		t.Errorf("FindByID() of a purged order error = %v, want %v", err, ErrNotExist)
+	}
+	if _, err := repo.FindByID(ctx, kept.OrderID, FindOptions{}); err != nil {
+		t.Errorf("FindByID() of a kept order error = %v", err)
+	}
+}
+
+func testFindAllTies(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	// Les commandes de même date sont départagées par ID décroissant, comparés comme des nombres :
+	// 100 vient avant 11, 10 et 9, même si "order:9" suit "order:100" dans l'ordre lexicographique.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	created := map[uint64]time.Time{
+		7:   now.Add(time.Second),
+		9:   now,
+		10:  now,
+		11:  now,
+		100: now,
+		8:   now.Add(-time.Second),
+		12:  now.Add(-time.Second),
+		1:   now.Add(-time.Minute),
+	}
+	for id, at := range created {
+		if err := repo.Insert(ctx, newOrder(id, at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []uint64{7, 100, 11, 10, 9, 12, 8, 1}
+
+	for size := uint64(1); size <= uint64(len(want))+1; size++ {
+		var got []uint64
+		page := FindAllPage{Size: size}
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatalf("size %d: pagination does not end", size)
+			}
+
+			res, err := repo.FindAll(ctx, page)
+			if err != nil {
+				t.Fatalf("FindAll() error = %v", err)
+			}
+			if res.Total != uint64(len(want)) {
+				t.Errorf("size %d: total = %d, want %d", size, res.Total, len(want))
+			}
+			if uint64(len(res.Orders)) > size {
+				t.Fatalf("size %d: page has %d orders", size, len(res.Orders))
+			}
+			got = append(got, ids(res.Orders)...)
+
+			if res.Next == nil {
+				break
+			}
+			last := res.Orders[len(res.Orders)-1]
+			if res.Next.OrderID != last.OrderID || !res.Next.CreatedAt.Equal(*last.CreatedAt) {
+				t.Errorf("size %d: cursor = %+v, want the last order %d", size, *res.Next, last.OrderID)
+			}
+			page.After = res.Next
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("size %d: orders = %v, want %v", size, got, want)
+		}
+	}
+}
+
+func testFindAllFilters(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	other := uuid.MustParse("99999999-9999-9999-9999-999999999999")
+
+	orders := []model.Order{
+		newOrder(1, now.Add(-3*time.Hour)),
+		newOrder(2, now.Add(-2*time.Hour)),
+		newOrder(3, now.Add(-time.Hour)),
+		newOrder(4, now),
+	}
+	orders[1].Status = model.StatusPaid
+	orders[2].CustomerID = other
+	orders[3].Status = model.StatusPaid
+	for _, o := range orders {
+		if err := repo.Insert(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		page FindAllPage
+		want []uint64
+	}{
+		{"all", FindAllPage{}, []uint64{4, 3, 2, 1}},
+		{"customer", FindAllPage{CustomerID: other}, []uint64{3}},
+		{"other customer", FindAllPage{CustomerID: testCustomer}, []uint64{4, 2, 1}},
+		{"unknown customer", FindAllPage{CustomerID: uuid.MustParse("88888888-8888-8888-8888-888888888888")}, nil},
+		{"status", FindAllPage{Status: model.StatusPaid}, []uint64{4, 2}},
+		{"customer and status", FindAllPage{CustomerID: testCustomer, Status: model.StatusPending}, []uint64{1}},
+		{"created after, included", FindAllPage{CreatedAfter: now.Add(-2 * time.Hour)}, []uint64{4, 3, 2}},
+		{"created before, excluded", FindAllPage{CreatedBefore: now.Add(-time.Hour)}, []uint64{2, 1}},
+		{"date range and status",
+			FindAllPage{CreatedAfter: now.Add(-2 * time.Hour), CreatedBefore: now, Status: model.StatusPaid},
+			[]uint64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := repo.FindAll(ctx, tt.page)
+			if err != nil {
+				t.Fatalf("FindAll() error = %v", err)
+			}
+			if got := ids(res.Orders); !reflect.DeepEqual(got, tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("FindAll() = %v, want %v", got, tt.want)
+			}
+			if res.Total != uint64(len(tt.want)) {
+				t.Errorf("FindAll() total = %d, want %d", res.Total, len(tt.want))
+			}
+			if res.Next != nil {
+				t.Errorf("FindAll() next = %+v, want nil", *res.Next)
+			}
+		})
+	}
+
+	// Une page après un cursor garde les filtres, et le total compte toutes les commandes qui les satisfont.
+	res, err := repo.FindAll(ctx, FindAllPage{Status: model.StatusPaid, After: &Cursor{CreatedAt: now, OrderID: 4}})
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+	if got := ids(res.Orders); !reflect.DeepEqual(got, []uint64{2}) || res.Total != 2 || res.Next != nil {
+		t.Errorf("FindAll() after a cursor = %v (total %d, next %v), want [2] (total 2, no next)", got, res.Total, res.Next)
+	}
+
+	// Un cursor hors de l'intervalle de dates n'est pas ignoré : plus récent, il n'en retire aucune commande,
+	// plus ancien, il donne une page vide, au lieu de recommencer à la première page.
+	cursors := []struct {
+		name  string
+		after Cursor
+		want  []uint64
+	}{
+		{"cursor after the range", Cursor{CreatedAt: now, OrderID: 4}, []uint64{3, 2}},
+		{"cursor before the range", Cursor{CreatedAt: now.Add(-3 * time.Hour), OrderID: 1}, nil},
+	}
+	for _, tt := range cursors {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.after
+			res, err := repo.FindAll(ctx, FindAllPage{
+				CreatedAfter: now.Add(-2 * time.Hour), CreatedBefore: now.Add(-time.Minute), After: &after,
+			})
+			if err != nil {
+				t.Fatalf("FindAll() error = %v", err)
+			}
+			if got := ids(res.Orders); !reflect.DeepEqual(got, tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("FindAll() = %v, want %v", got, tt.want)
+			}
+			if res.Next != nil {
+				t.Errorf("FindAll() next = %+v, want nil", *res.Next)
+			}
+		})
+	}
+}
+
+// TestRedisLegacyPrices vérifie qu'une commande enregistrée avant l'ajout des devises, dont les prix sont un simple
+// entier, reste lisible et reçoit ses totaux en model.LegacyCurrency à la migration.
+func TestRedisLegacyPrices(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := &RedisRepo{Client: client}
+	ctx := context.Background()
+
+	legacy := `{"order_id": 1, "customer_id": "11111111-1111-1111-1111-111111111111", "line_items": [
+		{"item_id": "22222222-2222-2222-2222-222222222222", "quantity": 2, "price": 1000}
+	], "created_at": "2024-01-01T00:00:00Z"}`
+	mr.Set(orderIDKey(1), legacy)
+	mr.SAdd("orders", orderIDKey(1))
+
+	if err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	got, err := repo.FindByID(ctx, 1, FindOptions{})
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	price := model.Money{Amount: 1000, Currency: model.LegacyCurrency}
+	if got.LineItems[0].Price != price {
+		t.Errorf("price = %v, want %v", got.LineItems[0].Price, price)
+	}
+	total := model.Money{Amount: 2000, Currency: model.LegacyCurrency}
+	if got.Currency != model.LegacyCurrency || got.Subtotal != total || got.Total != total {
+		t.Errorf("totals = %s %v %v, want %s %v %v", got.Currency, got.Subtotal, got.Total, model.LegacyCurrency, total, total)
+	}
+}
+
+// TestRedisRewriteRetries vérifie qu'une réécriture de migration relit la commande modifiée pendant son exécution,
+// au lieu d'échouer ou d'écraser la modification.
+func TestRedisRewriteRetries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := &RedisRepo{Client: client}
+	ctx := context.Background()
+
+	o := newOrder(1, time.Now())
+	if err := repo.Insert(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	// La première application est interrompue par une mise à jour concurrente de la commande.
+	calls := 0
+	err := repo.rewrite(ctx, o.OrderID, func(current *model.Order) error {
+		calls++
+		if calls == 1 {
+			updated := *current
+			updated.Notes = "mise à jour concurrente"
+			if err := repo.Update(ctx, updated); err != nil {
+				t.Fatal(err)
+			}
+		}
+		current.DiscountCode = "MIGRATED"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("rewrite() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("change applied %d times, want 2", calls)
+	}
+
+	got, err := repo.FindByID(ctx, o.OrderID, FindOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Notes != "mise à jour concurrente" || got.DiscountCode != "MIGRATED" || got.Version != 2 {
+		t.Errorf("order = notes %q, discount %q, version %d, want both changes at version 2", got.Notes, got.DiscountCode, got.Version)
+	}
+
+	// Une commande modifiée à chaque tentative finit par retourner une erreur. L'espace ajouté au JSON enregistré
+	// suffit à modifier la clé surveillée.
+	err = repo.rewrite(ctx, o.OrderID, func(current *model.Order) error {
+		return client.Append(ctx, orderIDKey(o.OrderID), " ").Err()
+	})
+	if !errors.Is(err, redis.TxFailedErr) {
+		t.Errorf("rewrite() error = %v, want %v", err, redis.TxFailedErr)
+	}
+}
+
+// TestSQLiteCreatedAtNotNull vérifie que la migration du NOT NULL de created_at date les commandes enregistrées
+// sans date de création, puis refuse les commandes sans date.
+func TestSQLiteCreatedAtNotNull(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Schéma d'avant la migration, avec une commande expédiée sans date de création et une sans aucune date.
+	const version = "0013_created_at_not_null"
+	if _, err := db.ExecContext(ctx, `CREATE TABLE schema_migrations (version TEXT PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := fs.ReadDir(migrations, sqliteMigrations.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		if name >= version {
+			continue
+		}
+		query, err := fs.ReadFile(migrations, path.Join(sqliteMigrations.dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ExecContext(ctx, string(query)); err != nil {
+			t.Fatalf("migration %s: %v", name, err)
+		}
+		if _, err := db.ExecContext(ctx, sqliteMigrations.insert, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shipped := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	_, err = db.ExecContext(ctx, `INSERT INTO orders (order_id, customer_id, shipped_at) VALUES (1, $1, $2), (2, $1, NULL)`,
+		testCustomer.String(), shipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &SQLiteRepo{DB: db}
+	if err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	var nulls int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders WHERE created_at IS NULL`).Scan(&nulls); err != nil {
+		t.Fatal(err)
+	}
+	if nulls != 0 {
+		t.Errorf("%d orders without created_at after the migration, want 0", nulls)
+	}
+	got, err := repo.FindByID(ctx, 1, FindOptions{})
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if got.CreatedAt == nil || !got.CreatedAt.Equal(shipped) {
+		t.Errorf("created_at = %v, want the shipping date %v", got.CreatedAt, shipped)
+	}
+
+	// Les commandes datées se paginent toutes, sans doublon.
+	res, err := repo.FindAll(ctx, FindAllPage{Size: 1})
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+	next, err := repo.FindAll(ctx, FindAllPage{Size: 1, After: res.Next})
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+	if pages := append(ids(res.Orders), ids(next.Orders)...); !reflect.DeepEqual(pages, []uint64{2, 1}) {
+		t.Errorf("pages = %v, want [2 1]", pages)
+	}
+
+	// Une commande sans date de création est refusée, à l'insertion comme à la mise à jour.
+	o := newOrder(3, time.Now())
+	o.CreatedAt = nil
+	if err := repo.Insert(ctx, o); err == nil {
+		t.Error("Insert() without created_at succeeded, want an error")
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE orders SET created_at = NULL WHERE order_id = 1`); err == nil {
+		t.Error("UPDATE created_at = NULL succeeded, want an error")
+	}
+}
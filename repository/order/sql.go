@@ -0,0 +1,505 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// sqlRepo regroupe les requêtes communes aux dépôts SQL (PostgreSQL et SQLite).
+// Les paramètres sont écrits $1, $2… ce que les deux bases acceptent.
+// Les IDs de commande sont des uint64 stockés bit à bit dans un entier signé de 64 bits.
+type sqlRepo struct {
+	db *sql.DB
+}
+
+// Insert ajoute une nouvelle commande et ses articles dans une transaction.
+func (r sqlRepo) Insert(ctx context.Context, order model.Order) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	adjustments, err := jsonColumn(order.Adjustments)
+	if err != nil {
+		return err
+	}
+	shipments, err := jsonColumn(order.Shipments)
+	if err != nil {
+		return err
+	}
+	returns, err := jsonColumn(order.Returns)
+	if err != nil {
+		return err
+	}
+	shippingAddress, err := jsonObject(order.ShippingAddress)
+	if err != nil {
+		return err
+	}
+	billingAddress, err := jsonObject(order.BillingAddress)
+	if err != nil {
+		return err
+	}
+
+	// Ajoute la commande, sans écraser une commande existante.
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO orders (order_id, version, status, customer_id, created_at, shipped_at, completed_at,
+			cancelled_at, cancel_reason, cancel_note, deleted_at, deleted_by, currency, discount_code, adjustments,
+			shipments, returns, notes, shipping_address, billing_address)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		ON CONFLICT (order_id) DO NOTHING`,
+		int64(order.OrderID), int64(order.Version), order.CurrentStatus(), order.CustomerID,
+		order.CreatedAt, order.ShippedAt, order.CompletedAt, order.CancelledAt, cancelReason(order), cancelNote(order),
+		order.DeletedAt, deletedBy(order), currency(order), discountCode(order), adjustments, shipments, returns,
+		notes(order), shippingAddress, billingAddress,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+
+	// Une commande déjà présente est laissée telle quelle.
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	} else if n == 0 {
+		return ErrAlreadyExists
+	}
+
+	if err := insertLineItems(ctx, tx, order); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// insertLineItems ajoute les articles d'une commande en conservant leur ordre.
+// Les prix sont stockés en unités mineures de la devise de la commande.
+func insertLineItems(ctx context.Context, tx *sql.Tx, order model.Order) error {
+	for i, item := range order.LineItems {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO line_items (order_id, position, item_id, quantity, price)
+			VALUES ($1, $2, $3, $4, $5)`,
+			int64(order.OrderID), i, item.ItemID, int64(item.Quantity), item.Price.Amount,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert line item: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// currency retourne la devise d'une commande, celle des commandes enregistrées sans devise par défaut.
+func currency(order model.Order) string {
+	if order.Currency == "" {
+		return string(model.LegacyCurrency)
+	}
+	return string(order.Currency)
+}
+
+// discountCode retourne le code promotionnel d'une commande, ou nil si elle n'en a pas.
+func discountCode(order model.Order) any {
+	if order.DiscountCode == "" {
+		return nil
+	}
+	return order.DiscountCode
+}
+
+// notes retourne les notes d'une commande, ou nil si elle n'en a pas.
+func notes(order model.Order) any {
+	if order.Notes == "" {
+		return nil
+	}
+	return order.Notes
+}
+
+// jsonColumn retourne values encodées en JSON, pour les listes stockées dans une colonne texte, ou nil si elle est vide.
+func jsonColumn[T any](values []T) (any, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal column: %w", err)
+	}
+	return string(data), nil
+}
+
+// jsonObject retourne value encodée en JSON, pour les objets stockés dans une colonne texte, ou nil s'il est absent.
+func jsonObject[T any](value *T) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal column: %w", err)
+	}
+	return string(data), nil
+}
+
+// cancelReason retourne le motif d'annulation d'une commande, ou nil si elle n'est pas annulée.
+func cancelReason(order model.Order) any {
+	if order.Cancellation == nil {
+		return nil
+	}
+	return string(order.Cancellation.Reason)
+}
+
+// cancelNote retourne la note d'annulation d'une commande, ou nil si elle n'est pas annulée.
+func cancelNote(order model.Order) any {
+	if order.Cancellation == nil {
+		return nil
+	}
+	return order.Cancellation.Note
+}
+
+// deletedBy retourne l'auteur de la suppression d'une commande, ou nil si elle est active.
+func deletedBy(order model.Order) any {
+	if order.DeletedAt == nil {
+		return nil
+	}
+	return order.DeletedBy
+}
+
+// selectOrders sélectionne une page de commandes (CTE page) jointe à leurs articles.
+// Les lignes sont triées de la commande la plus récente à la plus ancienne, puis par position d'article.
+const selectOrders = `
+	SELECT page.order_id, page.version, page.status, page.customer_id,
+		page.created_at, page.shipped_at, page.completed_at,
+		page.cancelled_at, page.cancel_reason, page.cancel_note, page.deleted_at, page.deleted_by, page.currency,
+		page.discount_code, page.adjustments, page.shipments, page.returns, page.notes,
+		page.shipping_address, page.billing_address,
+		li.item_id, li.quantity, li.price
+	FROM page
+	LEFT JOIN line_items li ON li.order_id = page.order_id
+	ORDER BY page.created_at DESC, page.order_id DESC, li.position`
+
+// FindByID trouve une commande par son ID.
+func (r sqlRepo) FindByID(ctx context.Context, id uint64, opts FindOptions) (model.Order, error) {
+	// Une commande supprimée n'est retournée que sur demande.
+	cond := "order_id = $1 AND deleted_at IS NULL"
+	if opts.IncludeDeleted {
+		cond = "order_id = $1"
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		WITH page AS (SELECT * FROM orders WHERE `+cond+`)`+selectOrders,
+		int64(id),
+	)
+	if err != nil {
+		return model.Order{}, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	// Gère le cas où la commande n'existe pas.
+	if len(orders) == 0 {
+		return model.Order{}, ErrNotExist
+	}
+
+	return orders[0], nil
+}
+
+// PurgeDeleted supprime définitivement les commandes supprimées avant la date donnée, et leurs articles.
+func (r sqlRepo) PurgeDeleted(ctx context.Context, before time.Time) (uint64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM line_items
+		WHERE order_id IN (SELECT order_id FROM orders WHERE deleted_at < $1)`,
+		before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete line items: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE deleted_at < $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orders: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orders: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return uint64(n), nil
+}
+
+// Update met à jour une commande existante et remplace ses articles dans une transaction.
+func (r sqlRepo) Update(ctx context.Context, order model.Order) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	adjustments, err := jsonColumn(order.Adjustments)
+	if err != nil {
+		return err
+	}
+	shipments, err := jsonColumn(order.Shipments)
+	if err != nil {
+		return err
+	}
+	returns, err := jsonColumn(order.Returns)
+	if err != nil {
+		return err
+	}
+	shippingAddress, err := jsonObject(order.ShippingAddress)
+	if err != nil {
+		return err
+	}
+	billingAddress, err := jsonObject(order.BillingAddress)
+	if err != nil {
+		return err
+	}
+
+	// Met à jour la commande seulement si sa version est celle lue par l'appelant.
+	res, err := tx.ExecContext(ctx, `
+		UPDATE orders SET version = version + 1, status = $3, customer_id = $4, created_at = $5, shipped_at = $6,
+			completed_at = $7, cancelled_at = $8, cancel_reason = $9, cancel_note = $10, deleted_at = $11, deleted_by = $12,
+			currency = $13, discount_code = $14, adjustments = $15, shipments = $16,
+			returns = $17, notes = $18, shipping_address = $19, billing_address = $20
+		WHERE order_id = $1 AND version = $2`,
+		int64(order.OrderID), int64(order.Version), order.CurrentStatus(), order.CustomerID,
+		order.CreatedAt, order.ShippedAt, order.CompletedAt, order.CancelledAt, cancelReason(order), cancelNote(order),
+		order.DeletedAt, deletedBy(order), currency(order), discountCode(order), adjustments, shipments, returns,
+		notes(order), shippingAddress, billingAddress,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	// Aucune ligne modifiée : la commande n'existe pas ou sa version a changé.
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	} else if n == 0 {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1)`,
+			int64(order.OrderID)).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check order: %w", err)
+		} else if !exists {
+			return ErrNotExist
+		}
+		return ErrVersionConflict
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM line_items WHERE order_id = $1`, int64(order.OrderID)); err != nil {
+		return fmt.Errorf("failed to delete line items: %w", err)
+	}
+
+	if err := insertLineItems(ctx, tx, order); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// FindAll trouve toutes les commandes avec une pagination par clé (date de création, ID),
+// de la plus récente à la plus ancienne.
+func (r sqlRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	size := page.Size
+	if size == 0 {
+		size = defaultScanCount
+	}
+
+	// Construit les conditions de la requête à partir des filtres.
+	var args []any
+	conds := []string{"TRUE"}
+	if !page.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if page.CustomerID != uuid.Nil {
+		args = append(args, page.CustomerID)
+		conds = append(conds, fmt.Sprintf("customer_id = $%d", len(args)))
+	}
+	if page.Status != "" {
+		args = append(args, page.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if !page.CreatedAfter.IsZero() {
+		args = append(args, page.CreatedAfter.UTC())
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !page.CreatedBefore.IsZero() {
+		args = append(args, page.CreatedBefore.UTC())
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	// Compte toutes les commandes satisfaisant les filtres, indépendamment de la page.
+	var total int64
+	err := r.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT COUNT(*) FROM orders WHERE %s`, strings.Join(conds, " AND ")), args...,
+	).Scan(&total)
+	if err != nil {
+		return FindResult{}, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	// Ne garde que les commandes plus anciennes que le cursor.
+	if page.After != nil {
+		args = append(args, page.After.CreatedAt.UTC(), int64(page.After.OrderID))
+		conds = append(conds, fmt.Sprintf("(created_at < $%d OR (created_at = $%d AND order_id < $%d))",
+			len(args)-1, len(args)-1, len(args)))
+	}
+
+	// Demande une commande de plus que la taille de page pour savoir s'il reste des résultats.
+	args = append(args, int64(size+1))
+	query := fmt.Sprintf(`
+		WITH page AS (
+			SELECT * FROM orders WHERE %s
+			ORDER BY created_at DESC, order_id DESC LIMIT $%d
+		)`,
+		strings.Join(conds, " AND "), len(args))
+
+	rows, err := r.db.QueryContext(ctx, query+selectOrders, args...)
+	if err != nil {
+		return FindResult{}, fmt.Errorf("failed to get orders: %w", err)
+	}
+
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return FindResult{}, err
+	}
+
+	res := FindResult{
+		Orders: orders,
+		Total:  uint64(total),
+	}
+
+	// S'il reste des commandes, le cursor pointe sur la dernière commande retournée.
+	if uint64(len(orders)) > size {
+		res.Orders = orders[:size]
+		last := res.Orders[size-1]
+		res.Next = &Cursor{CreatedAt: createdAt(last), OrderID: last.OrderID}
+	}
+
+	return res, nil
+}
+
+// scanOrders regroupe les lignes d'une jointure commandes/articles en commandes et ferme rows.
+func scanOrders(rows *sql.Rows) ([]model.Order, error) {
+	defer rows.Close()
+
+	orders := []model.Order{}
+	for rows.Next() {
+		var (
+			orderID  int64
+			version  int64
+			order    model.Order
+			reason   sql.NullString
+			note     sql.NullString
+			by       sql.NullString
+			code     sql.NullString
+			adjs     sql.NullString
+			ships    sql.NullString
+			rets     sql.NullString
+			notes    sql.NullString
+			shipTo   sql.NullString
+			billTo   sql.NullString
+			itemID   uuid.NullUUID
+			quantity sql.NullInt64
+			price    sql.NullInt64
+		)
+
+		err := rows.Scan(&orderID, &version, &order.Status, &order.CustomerID,
+			&order.CreatedAt, &order.ShippedAt, &order.CompletedAt, &order.CancelledAt, &reason, &note,
+			&order.DeletedAt, &by, &order.Currency, &code, &adjs, &ships, &rets, &notes, &shipTo, &billTo,
+			&itemID, &quantity, &price)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		// Un nouvel ID signifie une nouvelle commande.
+		if len(orders) == 0 || orders[len(orders)-1].OrderID != uint64(orderID) {
+			order.OrderID = uint64(orderID)
+			order.Version = uint64(version)
+			order.DeletedBy = by.String
+			order.DiscountCode = code.String
+			order.Notes = notes.String
+			if adjs.Valid {
+				if err := json.Unmarshal([]byte(adjs.String), &order.Adjustments); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal adjustments of order %d: %w", orderID, err)
+				}
+			}
+			if ships.Valid {
+				if err := json.Unmarshal([]byte(ships.String), &order.Shipments); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal shipments of order %d: %w", orderID, err)
+				}
+			}
+			if rets.Valid {
+				if err := json.Unmarshal([]byte(rets.String), &order.Returns); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal returns of order %d: %w", orderID, err)
+				}
+			}
+			if shipTo.Valid {
+				if err := json.Unmarshal([]byte(shipTo.String), &order.ShippingAddress); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal shipping address of order %d: %w", orderID, err)
+				}
+			}
+			if billTo.Valid {
+				if err := json.Unmarshal([]byte(billTo.String), &order.BillingAddress); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal billing address of order %d: %w", orderID, err)
+				}
+			}
+			if reason.Valid {
+				order.Cancellation = &model.Cancellation{Reason: model.CancelReason(reason.String), Note: note.String}
+			}
+			order.LineItems = []model.LineItem{}
+			orders = append(orders, order)
+		}
+
+		// La jointure externe retourne des colonnes nulles pour une commande sans article.
+		if !itemID.Valid {
+			continue
+		}
+
+		item := model.LineItem{
+			ItemID:   itemID.UUID,
+			Quantity: uint(quantity.Int64),
+			Price:    model.Money{Amount: price.Int64, Currency: orders[len(orders)-1].Currency},
+		}
+
+		last := &orders[len(orders)-1]
+		last.LineItems = append(last.LineItems, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orders: %w", err)
+	}
+
+	// Les totaux ne sont pas stockés : ils sont recalculés à partir des articles et des ajustements.
+	for i := range orders {
+		if err := orders[i].ComputeTotals(); err != nil {
+			return nil, fmt.Errorf("failed to compute totals of order %d: %w", orders[i].OrderID, err)
+		}
+	}
+
+	return orders, nil
+}
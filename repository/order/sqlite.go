@@ -0,0 +1,87 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/SamMebarek/orders-api/model"
+
+	// Enregistre le driver "sqlite" (Go pur, sans cgo) auprès de database/sql.
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRepo est un struct pour interagir avec une base SQLite locale. Il contient une connexion à la base.
+// Il utilise le même schéma que PostgresRepo et convient aux déploiements sur une seule machine.
+type SQLiteRepo struct {
+	DB *sql.DB
+}
+
+// sqliteMigrations décrit l'application des migrations SQLite.
+// Aucun verrou explicite n'est nécessaire : les transactions prennent le verrou d'écriture dès leur début.
+var sqliteMigrations = migrationSet{
+	dir:    "migrations/sqlite",
+	insert: `INSERT INTO schema_migrations (version) VALUES ($1)`,
+}
+
+// OpenSQLite ouvre la base SQLite située à path en mode WAL.
+// Les transactions sont ouvertes en mode IMMEDIATE pour éviter les erreurs SQLITE_BUSY
+// lorsqu'une transaction de lecture tente ensuite d'écrire.
+// Les dates sont écrites au format SQLite afin que leur comparaison textuelle suive l'ordre chronologique.
+func OpenSQLite(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_txlock", "immediate")
+	params.Add("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite: %w", err)
+	}
+
+	return db, nil
+}
+
+// Migrate applique les migrations de schéma manquantes.
+func (r *SQLiteRepo) Migrate(ctx context.Context) error {
+	return sqliteMigrations.apply(ctx, r.DB)
+}
+
+// Checkpoint recopie le journal WAL dans la base principale et le tronque,
+// pour que le fichier -wal ne grossisse pas indéfiniment.
+func (r *SQLiteRepo) Checkpoint(ctx context.Context) error {
+	if _, err := r.DB.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// Insert ajoute une nouvelle commande et ses articles dans une transaction.
+func (r *SQLiteRepo) Insert(ctx context.Context, order model.Order) error {
+	return sqlRepo{r.DB}.Insert(ctx, order)
+}
+
+// FindByID trouve une commande par son ID.
+func (r *SQLiteRepo) FindByID(ctx context.Context, id uint64, opts FindOptions) (model.Order, error) {
+	return sqlRepo{r.DB}.FindByID(ctx, id, opts)
+}
+
+// PurgeDeleted supprime définitivement les commandes supprimées avant la date donnée, et leurs articles.
+func (r *SQLiteRepo) PurgeDeleted(ctx context.Context, before time.Time) (uint64, error) {
+	return sqlRepo{r.DB}.PurgeDeleted(ctx, before)
+}
+
+// Update met à jour une commande existante et remplace ses articles dans une transaction.
+func (r *SQLiteRepo) Update(ctx context.Context, order model.Order) error {
+	return sqlRepo{r.DB}.Update(ctx, order)
+}
+
+// FindAll trouve toutes les commandes avec une pagination par clé, de la plus récente à la plus ancienne.
+func (r *SQLiteRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	return sqlRepo{r.DB}.FindAll(ctx, page)
+}
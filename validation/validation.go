@@ -0,0 +1,461 @@
+// Package validation vérifie le contenu des commandes, de leurs colis et retours,
+// et des abonnements aux webhooks, avant leur enregistrement.
+// Les règles sont indépendantes du transport : elles servent aux gestionnaires HTTP
+// comme aux chemins d'import en masse.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// Codes d'erreur stables associés aux champs invalides.
+const (
+	CodeRequired   = "required"     // Champ obligatoire absent ou vide.
+	CodeTooMany    = "too_many"     // Liste dépassant la taille maximale.
+	CodeOutOfRange = "out_of_range" // Valeur hors des bornes permises.
+	CodeDuplicate  = "duplicate"    // Valeur déjà présente dans la liste.
+	CodeInvalid    = "invalid"      // Valeur ne faisant pas partie des valeurs permises.
+	CodeTooLong    = "too_long"     // Texte dépassant la longueur maximale.
+	CodeImmutable  = "immutable"    // Champ qui ne peut pas être modifié.
+)
+
+// FieldError décrit une erreur portant sur un champ d'une commande.
+type FieldError struct {
+	Field   string // Chemin du champ, par exemple "line_items[0].quantity".
+	Code    string // Code d'erreur stable.
+	Message string // Explication lisible.
+}
+
+// Errors regroupe toutes les erreurs trouvées lors d'une validation.
+type Errors []FieldError
+
+// Error résume les erreurs de validation.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, f := range e {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Rules définit les règles de validation d'une commande.
+type Rules struct {
+	MaxLineItems           int  // Nombre maximal d'articles par commande, 0 pour ne pas limiter.
+	MaxQuantity            uint // Quantité maximale par article, 0 pour ne pas limiter.
+	RequireCustomer        bool // Exige un ID de client.
+	RequireShippingAddress bool // Exige une adresse de livraison.
+}
+
+// DefaultRules retourne les règles utilisées lorsqu'aucune configuration n'est fournie.
+func DefaultRules() Rules {
+	return Rules{
+		MaxLineItems:    100,
+		MaxQuantity:     1000,
+		RequireCustomer: true,
+	}
+}
+
+// Order vérifie une commande et retourne toutes ses erreurs sous forme d'Errors, ou nil si elle est valide.
+func (r Rules) Order(o model.Order) error {
+	return r.order(o, func(string) bool { return true })
+}
+
+// Fields vérifie seulement les champs fields de la commande o, désignés par leur nom JSON : customer_id,
+// line_items, notes, shipping_address ou billing_address. Elle sert aux modifications qui ne touchent pas
+// les autres champs, déjà vérifiés à l'enregistrement de la commande.
+func (r Rules) Fields(o model.Order, fields ...string) error {
+	checked := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		checked[field] = true
+	}
+	return r.order(o, func(field string) bool { return checked[field] })
+}
+
+// order vérifie les champs de la commande o pour lesquels check retourne true.
+func (r Rules) order(o model.Order, check func(field string) bool) error {
+	var errs Errors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if check("customer_id") && r.RequireCustomer && o.CustomerID == uuid.Nil {
+		add("customer_id", CodeRequired, "customer_id is required")
+	}
+
+	if check("line_items") {
+		r.lineItems(o, add)
+	}
+
+	if check("notes") && utf8.RuneCountInString(o.Notes) > MaxNoteLength {
+		add("notes", CodeTooLong, "notes must not exceed %d characters", MaxNoteLength)
+	}
+
+	if check("shipping_address") {
+		if o.ShippingAddress != nil {
+			address("shipping_address.", *o.ShippingAddress, add)
+		} else if r.RequireShippingAddress {
+			add("shipping_address", CodeRequired, "shipping_address is required")
+		}
+	}
+	if check("billing_address") && o.BillingAddress != nil {
+		address("billing_address.", *o.BillingAddress, add)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// lineItems vérifie les articles de la commande o, puis son total lorsqu'ils sont valides.
+func (r Rules) lineItems(o model.Order, add func(field, code, format string, args ...any)) {
+	valid := true
+	fail := func(field, code, format string, args ...any) {
+		valid = false
+		add(field, code, format, args...)
+	}
+
+	if len(o.LineItems) == 0 {
+		fail("line_items", CodeRequired, "at least one line item is required")
+	} else if r.MaxLineItems > 0 && len(o.LineItems) > r.MaxLineItems {
+		fail("line_items", CodeTooMany, "at most %d line items are allowed", r.MaxLineItems)
+	}
+
+	// Chaque article est vérifié, et un même article ne peut apparaître qu'une fois.
+	// Tous les prix doivent être dans la devise du premier article.
+	var currency model.Currency
+	seen := make(map[uuid.UUID]int, len(o.LineItems))
+	for i, item := range o.LineItems {
+		prefix := fmt.Sprintf("line_items[%d].", i)
+
+		if item.ItemID == uuid.Nil {
+			fail(prefix+"item_id", CodeRequired, "item_id is required")
+		} else if first, exists := seen[item.ItemID]; exists {
+			fail(prefix+"item_id", CodeDuplicate, "item already listed in line_items[%d]", first)
+		} else {
+			seen[item.ItemID] = i
+		}
+
+		if item.Quantity == 0 {
+			fail(prefix+"quantity", CodeOutOfRange, "quantity must be positive")
+		} else if r.MaxQuantity > 0 && item.Quantity > r.MaxQuantity {
+			fail(prefix+"quantity", CodeOutOfRange, "quantity must not exceed %d", r.MaxQuantity)
+		}
+
+		if item.Price.Amount <= 0 {
+			fail(prefix+"price.amount", CodeOutOfRange, "price must be positive")
+		}
+
+		switch {
+		case item.Price.Currency == "":
+			fail(prefix+"price.currency", CodeRequired, "currency is required")
+		case !item.Price.Currency.Valid():
+			fail(prefix+"price.currency", CodeInvalid, "unknown currency %q", item.Price.Currency)
+		case currency == "":
+			currency = item.Price.Currency
+		case item.Price.Currency != currency:
+			fail(prefix+"price.currency", CodeInvalid, "all line items must be priced in %s", currency)
+		}
+	}
+
+	// Les totaux ne sont calculés que pour des articles valides, afin de signaler leur dépassement de capacité.
+	if valid {
+		if err := o.ComputeTotals(); err != nil {
+			add("total", CodeOutOfRange, "order total exceeds the maximum amount")
+		}
+	}
+}
+
+// MaxAddressFieldLength est la longueur maximale, en caractères, de chaque ligne d'une adresse.
+const MaxAddressFieldLength = 200
+
+// address vérifie une adresse, dont les champs sont signalés avec le préfixe prefix : nom, première ligne, ville
+// et pays obligatoires, code postal au format du pays.
+func address(prefix string, a model.Address, add func(field, code, format string, args ...any)) {
+	lines := []struct {
+		field    string
+		value    string
+		required bool
+	}{
+		{"name", a.Name, true},
+		{"line1", a.Line1, true},
+		{"line2", a.Line2, false},
+		{"city", a.City, true},
+		{"region", a.Region, false},
+	}
+	for _, line := range lines {
+		switch n := utf8.RuneCountInString(strings.TrimSpace(line.value)); {
+		case n == 0 && line.required:
+			add(prefix+line.field, CodeRequired, "%s is required", line.field)
+		case n > MaxAddressFieldLength:
+			add(prefix+line.field, CodeTooLong, "%s must not exceed %d characters", line.field, MaxAddressFieldLength)
+		}
+	}
+
+	switch {
+	case a.Country == "":
+		add(prefix+"country", CodeRequired, "country is required")
+	case !a.Country.Valid():
+		add(prefix+"country", CodeInvalid, "unknown country %q", a.Country)
+	case a.PostalCode == "":
+		add(prefix+"postal_code", CodeRequired, "postal_code is required")
+	case !a.Country.ValidPostalCode(a.PostalCode):
+		add(prefix+"postal_code", CodeInvalid, "invalid postal code %q for %s", a.PostalCode, a.Country)
+	}
+}
+
+// MaxNoteLength est la longueur maximale, en caractères, d'une note libre.
+const MaxNoteLength = 1000
+
+// Cancellation vérifie le motif et la note d'une annulation. Le motif other doit être précisé dans la note.
+func (r Rules) Cancellation(c model.Cancellation) error {
+	var errs Errors
+
+	switch {
+	case c.Reason == "":
+		errs = append(errs, FieldError{Field: "reason", Code: CodeRequired, Message: "reason is required"})
+	case !c.Reason.Valid():
+		errs = append(errs, FieldError{Field: "reason", Code: CodeInvalid,
+			Message: fmt.Sprintf("unknown reason %q", c.Reason)})
+	}
+
+	switch n := utf8.RuneCountInString(strings.TrimSpace(c.Note)); {
+	case n == 0 && c.Reason == model.CancelOther:
+		errs = append(errs, FieldError{Field: "note", Code: CodeRequired, Message: "note is required for reason other"})
+	case n > MaxNoteLength:
+		errs = append(errs, FieldError{Field: "note", Code: CodeTooLong,
+			Message: fmt.Sprintf("note must not exceed %d characters", MaxNoteLength)})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Bornes des champs d'un colis.
+const (
+	MaxCarrierLength        = 100 // Longueur maximale du nom du transporteur.
+	MaxTrackingNumberLength = 100 // Longueur maximale du numéro de suivi.
+)
+
+// Shipment vérifie un colis à ajouter à la commande o : transporteur, numéro de suivi et articles.
+// Chaque article doit faire partie de la commande, une seule fois, sans dépasser la quantité restant à expédier.
+func (r Rules) Shipment(o model.Order, s model.Shipment) error {
+	var errs Errors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch n := utf8.RuneCountInString(strings.TrimSpace(s.Carrier)); {
+	case n == 0:
+		add("carrier", CodeRequired, "carrier is required")
+	case n > MaxCarrierLength:
+		add("carrier", CodeTooLong, "carrier must not exceed %d characters", MaxCarrierLength)
+	}
+
+	switch n := utf8.RuneCountInString(strings.TrimSpace(s.TrackingNumber)); {
+	case n == 0:
+		add("tracking_number", CodeRequired, "tracking_number is required")
+	case n > MaxTrackingNumberLength:
+		add("tracking_number", CodeTooLong, "tracking_number must not exceed %d characters", MaxTrackingNumberLength)
+	}
+
+	if len(s.LineItems) == 0 {
+		add("line_items", CodeRequired, "at least one line item is required")
+	}
+
+	ordered := make(map[uuid.UUID]uint, len(o.LineItems))
+	for _, item := range o.LineItems {
+		ordered[item.ItemID] = item.Quantity
+	}
+	shipped := o.ShippedQuantities()
+	seen := make(map[uuid.UUID]int, len(s.LineItems))
+	for i, line := range s.LineItems {
+		prefix := fmt.Sprintf("line_items[%d].", i)
+
+		quantity, exists := ordered[line.ItemID]
+		switch {
+		case line.ItemID == uuid.Nil:
+			add(prefix+"item_id", CodeRequired, "item_id is required")
+			continue
+		case !exists:
+			add(prefix+"item_id", CodeInvalid, "item %s is not in the order", line.ItemID)
+			continue
+		}
+		if first, exists := seen[line.ItemID]; exists {
+			add(prefix+"item_id", CodeDuplicate, "item already listed in line_items[%d]", first)
+			continue
+		}
+		seen[line.ItemID] = i
+
+		if left := quantity - shipped[line.ItemID]; line.Quantity == 0 {
+			add(prefix+"quantity", CodeOutOfRange, "quantity must be positive")
+		} else if line.Quantity > left {
+			add(prefix+"quantity", CodeOutOfRange, "quantity must not exceed the %d left to ship", left)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// MaxRefundReferenceLength est la longueur maximale de la référence d'un remboursement.
+const MaxRefundReferenceLength = 100
+
+// Return vérifie une demande de retour d'articles de la commande o : motif, note et articles.
+// Chaque article doit faire partie de la commande, une seule fois,
+// sans dépasser la quantité qui peut encore être retournée.
+func (r Rules) Return(o model.Order, ret model.Return) error {
+	var errs Errors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case ret.Reason == "":
+		add("reason", CodeRequired, "reason is required")
+	case !ret.Reason.Valid():
+		add("reason", CodeInvalid, "unknown reason %q", ret.Reason)
+	}
+
+	if utf8.RuneCountInString(ret.Note) > MaxNoteLength {
+		add("note", CodeTooLong, "note must not exceed %d characters", MaxNoteLength)
+	}
+
+	if len(ret.LineItems) == 0 {
+		add("line_items", CodeRequired, "at least one line item is required")
+	}
+
+	returnable := o.ReturnableQuantities()
+	seen := make(map[uuid.UUID]int, len(ret.LineItems))
+	for i, line := range ret.LineItems {
+		prefix := fmt.Sprintf("line_items[%d].", i)
+
+		left, exists := returnable[line.ItemID]
+		switch {
+		case line.ItemID == uuid.Nil:
+			add(prefix+"item_id", CodeRequired, "item_id is required")
+			continue
+		case !exists:
+			add(prefix+"item_id", CodeInvalid, "item %s is not in the order", line.ItemID)
+			continue
+		}
+		if first, exists := seen[line.ItemID]; exists {
+			add(prefix+"item_id", CodeDuplicate, "item already listed in line_items[%d]", first)
+			continue
+		}
+		seen[line.ItemID] = i
+
+		if line.Quantity == 0 {
+			add(prefix+"quantity", CodeOutOfRange, "quantity must be positive")
+		} else if line.Quantity > left {
+			add(prefix+"quantity", CodeOutOfRange, "quantity must not exceed the %d left to return", left)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ReturnDecision vérifie l'explication de l'approbation ou du refus d'un retour.
+func (r Rules) ReturnDecision(decision string) error {
+	if utf8.RuneCountInString(decision) > MaxNoteLength {
+		return Errors{{Field: "note", Code: CodeTooLong,
+			Message: fmt.Sprintf("note must not exceed %d characters", MaxNoteLength)}}
+	}
+	return nil
+}
+
+// Refund vérifie un remboursement de la commande o : un montant positif dans la devise de la commande,
+// qui ne dépasse pas le reste à rembourser, et une référence facultative.
+func (r Rules) Refund(o model.Order, refund model.Refund) error {
+	var errs Errors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	refundable, err := o.Refundable()
+	switch {
+	case err != nil:
+		return err
+	case refund.Amount.Currency != refundable.Currency:
+		add("amount.currency", CodeInvalid, "refund must be in %s", refundable.Currency)
+	case refund.Amount.Amount <= 0:
+		add("amount.amount", CodeOutOfRange, "amount must be positive")
+	case refund.Amount.Amount > refundable.Amount:
+		add("amount.amount", CodeOutOfRange, "amount must not exceed the %d left to refund", refundable.Amount)
+	}
+
+	if utf8.RuneCountInString(refund.Reference) > MaxRefundReferenceLength {
+		add("reference", CodeTooLong, "reference must not exceed %d characters", MaxRefundReferenceLength)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Bornes des champs d'un abonnement aux webhooks.
+const (
+	MaxURLLength    = 2048 // Longueur maximale de l'URL.
+	MinSecretLength = 16   // Longueur minimale de la clé de signature.
+	MaxSecretLength = 256  // Longueur maximale de la clé de signature.
+)
+
+// Subscription vérifie l'URL, le filtre d'événements events et la clé de signature secret d'un abonnement
+// aux webhooks. Les champs sont passés séparément pour que ce paquet ne dépende pas du paquet webhook.
+func Subscription(rawURL string, events []event.Type, secret string) error {
+	var errs Errors
+	add := func(field, code, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// L'URL doit être absolue, en HTTP ou HTTPS.
+	if rawURL == "" {
+		add("url", CodeRequired, "url is required")
+	} else if len(rawURL) > MaxURLLength {
+		add("url", CodeTooLong, "url must not exceed %d characters", MaxURLLength)
+	} else if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("url", CodeInvalid, "url must be an absolute http or https URL")
+	}
+
+	// Chaque type d'événement doit être connu et n'apparaître qu'une fois.
+	seen := make(map[event.Type]int, len(events))
+	for i, t := range events {
+		field := fmt.Sprintf("events[%d]", i)
+		if !t.Valid() {
+			add(field, CodeInvalid, "unknown event type %q", t)
+		} else if first, exists := seen[t]; exists {
+			add(field, CodeDuplicate, "event type already listed in events[%d]", first)
+		} else {
+			seen[t] = i
+		}
+	}
+
+	switch n := utf8.RuneCountInString(secret); {
+	case n == 0:
+		add("secret", CodeRequired, "secret is required")
+	case n < MinSecretLength:
+		add("secret", CodeOutOfRange, "secret must have at least %d characters", MinSecretLength)
+	case n > MaxSecretLength:
+		add("secret", CodeTooLong, "secret must not exceed %d characters", MaxSecretLength)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package validation
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/SamMebarek/orders-api/model"
+	"github.com/google/uuid"
+)
+
+// fieldCodes retourne les erreurs de err sous la forme "champ:code", dans leur ordre, ou nil si err est nil.
+func fieldCodes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error = %v, want Errors", err)
+	}
+	var codes []string
+	for _, f := range errs {
+		codes = append(codes, f.Field+":"+f.Code)
+	}
+	return codes
+}
+
+var (
+	customer = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	item1    = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	item2    = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+)
+
+// lineItem retourne un article valide de quantité quantity au prix de 10 euros.
+func lineItem(id uuid.UUID, quantity uint) model.LineItem {
+	return model.LineItem{ItemID: id, Quantity: quantity, Price: model.Money{Amount: 1000, Currency: "EUR"}}
+}
+
+func TestOrder(t *testing.T) {
+	rules := Rules{MaxLineItems: 2, MaxQuantity: 10, RequireCustomer: true}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		order model.Order
+		want  []string
+	}{
+		{"valid", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{lineItem(item1, 2)}}, nil},
+		{"no customer", rules, model.Order{LineItems: []model.LineItem{lineItem(item1, 2)}},
+			[]string{"customer_id:required"}},
+		{"customer not required", Rules{}, model.Order{LineItems: []model.LineItem{lineItem(item1, 2)}}, nil},
+		{"no line items", rules, model.Order{CustomerID: customer}, []string{"line_items:required"}},
+		{"too many line items", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{
+			lineItem(item1, 1), lineItem(item2, 1), lineItem(uuid.New(), 1),
+		}}, []string{"line_items:too_many"}},
+		{"duplicate item", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{
+			lineItem(item1, 1), lineItem(item1, 1),
+		}}, []string{"line_items[1].item_id:duplicate"}},
+		{"quantities", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{
+			lineItem(item1, 0), lineItem(item2, 11),
+		}}, []string{"line_items[0].quantity:out_of_range", "line_items[1].quantity:out_of_range"}},
+		{"prices", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{
+			{ItemID: item1, Quantity: 1, Price: model.Money{Amount: 0, Currency: "XXX"}},
+			{ItemID: item2, Quantity: 1, Price: model.Money{Amount: 100}},
+		}}, []string{
+			"line_items[0].price.amount:out_of_range", "line_items[0].price.currency:invalid",
+			"line_items[1].price.currency:required",
+		}},
+		{"mixed currencies", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{
+			lineItem(item1, 1), {ItemID: item2, Quantity: 1, Price: model.Money{Amount: 100, Currency: "USD"}},
+		}}, []string{"line_items[1].price.currency:invalid"}},
+		{"total overflow", Rules{}, model.Order{CustomerID: customer, LineItems: []model.LineItem{
+			{ItemID: item1, Quantity: 2, Price: model.Money{Amount: math.MaxInt64, Currency: "EUR"}},
+		}}, []string{"total:out_of_range"}},
+		{"long notes", rules, model.Order{CustomerID: customer, LineItems: []model.LineItem{lineItem(item1, 1)},
+			Notes: strings.Repeat("é", MaxNoteLength+1)}, []string{"notes:too_long"}},
+		{"shipping address required", Rules{RequireShippingAddress: true},
+			model.Order{LineItems: []model.LineItem{lineItem(item1, 1)}}, []string{"shipping_address:required"}},
+		// Toutes les erreurs sont signalées ensemble.
+		{"all errors", rules, model.Order{Notes: strings.Repeat("a", MaxNoteLength+1)},
+			[]string{"customer_id:required", "line_items:required", "notes:too_long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldCodes(t, tt.rules.Order(tt.order)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	rules := DefaultRules()
+	o := model.Order{Notes: strings.Repeat("a", MaxNoteLength+1)}
+
+	tests := []struct {
+		fields []string
+		want   []string
+	}{
+		{nil, nil},
+		{[]string{"notes"}, []string{"notes:too_long"}},
+		{[]string{"customer_id", "line_items"}, []string{"customer_id:required", "line_items:required"}},
+	}
+
+	for _, tt := range tests {
+		if got := fieldCodes(t, rules.Fields(o, tt.fields...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Fields(%v) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	secret := strings.Repeat("s", MinSecretLength)
+
+	tests := []struct {
+		name   string
+		url    string
+		events []event.Type
+		secret string
+		want   []string
+	}{
+		{"valid", "https://example.com/hooks", []event.Type{event.OrderCreated, event.OrderShipped}, secret, nil},
+		{"all events", "http://example.com/hooks", nil, secret, nil},
+		{"no url", "", nil, secret, []string{"url:required"}},
+		{"relative url", "/hooks", nil, secret, []string{"url:invalid"}},
+		{"other scheme", "ftp://example.com/hooks", nil, secret, []string{"url:invalid"}},
+		{"long url", "https://example.com/" + strings.Repeat("a", MaxURLLength), nil, secret, []string{"url:too_long"}},
+		{"unknown event", "https://example.com/hooks", []event.Type{"OrderLost"}, secret,
+			[]string{"events[0]:invalid"}},
+		{"duplicate event", "https://example.com/hooks", []event.Type{event.OrderCreated, event.OrderCreated}, secret,
+			[]string{"events[1]:duplicate"}},
+		{"no secret", "https://example.com/hooks", nil, "", []string{"secret:required"}},
+		{"short secret", "https://example.com/hooks", nil, secret[1:], []string{"secret:out_of_range"}},
+		{"long secret", "https://example.com/hooks", nil, strings.Repeat("s", MaxSecretLength+1),
+			[]string{"secret:too_long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldCodes(t, Subscription(tt.url, tt.events, tt.secret)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subscription() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderAddresses(t *testing.T) {
+	valid := model.Address{Name: "Jeanne Martin", Line1: "1 rue de Rivoli", City: "Paris", PostalCode: "75001", Country: "FR"}
+	order := func(shipTo, billTo *model.Address) model.Order {
+		return model.Order{CustomerID: customer, LineItems: []model.LineItem{lineItem(item1, 1)},
+			ShippingAddress: shipTo, BillingAddress: billTo}
+	}
+	with := func(change func(a *model.Address)) *model.Address {
+		a := valid
+		change(&a)
+		return &a
+	}
+
+	tests := []struct {
+		name  string
+		order model.Order
+		want  []string
+	}{
+		{"valid", order(&valid, &valid), nil},
+		{"no addresses", order(nil, nil), nil},
+		{"missing lines", order(with(func(a *model.Address) { a.Name, a.Line1, a.City = " ", "", "" }), nil),
+			[]string{"shipping_address.name:required", "shipping_address.line1:required", "shipping_address.city:required"}},
+		{"long line", order(nil, with(func(a *model.Address) { a.Line2 = strings.Repeat("a", MaxAddressFieldLength+1) })),
+			[]string{"billing_address.line2:too_long"}},
+		{"no country", order(with(func(a *model.Address) { a.Country = "" }), nil),
+			[]string{"shipping_address.country:required"}},
+		{"unknown country", order(with(func(a *model.Address) { a.Country = "ZZ" }), nil),
+			[]string{"shipping_address.country:invalid"}},
+		{"no postal code", order(with(func(a *model.Address) { a.PostalCode = "" }), nil),
+			[]string{"shipping_address.postal_code:required"}},
+		{"postal code of another country", order(nil, with(func(a *model.Address) { a.PostalCode = "SW1A 1AA" })),
+			[]string{"billing_address.postal_code:invalid"}},
+		{"postal code for its country", order(with(func(a *model.Address) { a.PostalCode, a.Country = "SW1A 1AA", "GB" }), nil),
+			nil},
+		// Un pays sans format de code postal connu est accepté, avec un code postal court.
+		{"country without a postal code format", order(with(func(a *model.Address) { a.PostalCode, a.Country = "D02 X285", "IE" }), nil),
+			nil},
+		{"long postal code without a format", order(with(func(a *model.Address) { a.PostalCode, a.Country = "12345678901", "IN" }), nil),
+			[]string{"shipping_address.postal_code:invalid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldCodes(t, DefaultRules().Order(tt.order)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
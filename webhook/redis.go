@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore est un struct pour enregistrer les abonnements dans Redis. Il contient un client Redis.
+// Il gère aussi la file des envois en attente, partagée par les workers de toutes les instances.
+type RedisStore struct {
+	Client *redis.Client
+}
+
+// Clés Redis des abonnements et de la file des envois.
+const (
+	subscriptionsKey = "webhooks"       // Hash des abonnements en JSON, par ID.
+	queueKey         = "webhooks:queue" // Envois en attente, avec pour score la date de la prochaine tentative (ms).
+)
+
+// consumerGroup est le groupe de consommateurs du stream des événements partagé par les workers.
+const consumerGroup = "webhooks"
+
+// Nombre maximal d'entrées conservées dans le journal et les lettres mortes de chaque abonnement.
+const (
+	maxDeliveries  = 100
+	maxDeadLetters = 1000
+)
+
+// deliveriesKey génère la clé Redis du journal des envois d'un abonnement.
+func deliveriesKey(id string) string {
+	return fmt.Sprintf("webhook:%s:deliveries", id)
+}
+
+// deadLettersKey génère la clé Redis des lettres mortes d'un abonnement.
+func deadLettersKey(id string) string {
+	return fmt.Sprintf("webhook:%s:dead", id)
+}
+
+// Create enregistre un nouvel abonnement.
+func (s *RedisStore) Create(ctx context.Context, sub Subscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription: %w", err)
+	}
+
+	created, err := s.Client.HSetNX(ctx, subscriptionsKey, sub.ID, string(data)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to create subscription: %w", err)
+	} else if !created {
+		return fmt.Errorf("subscription %s already exists", sub.ID)
+	}
+
+	return nil
+}
+
+// List retourne tous les abonnements, du plus ancien au plus récent.
+func (s *RedisStore) List(ctx context.Context) ([]Subscription, error) {
+	values, err := s.Client.HVals(ctx, subscriptionsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	subs := make([]Subscription, 0, len(values))
+	for _, value := range values {
+		var sub Subscription
+		if err := json.Unmarshal([]byte(value), &sub); err != nil {
+			return nil, fmt.Errorf("failed to decode subscription json: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+
+	return subs, nil
+}
+
+// Get retourne un abonnement, ou ErrNotExist s'il n'existe pas.
+func (s *RedisStore) Get(ctx context.Context, id string) (Subscription, error) {
+	value, err := s.Client.HGet(ctx, subscriptionsKey, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return Subscription{}, ErrNotExist
+	} else if err != nil {
+		return Subscription{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	var sub Subscription
+	if err := json.Unmarshal([]byte(value), &sub); err != nil {
+		return Subscription{}, fmt.Errorf("failed to decode subscription json: %w", err)
+	}
+
+	return sub, nil
+}
+
+// Delete supprime un abonnement avec son journal et ses lettres mortes, ou retourne ErrNotExist.
+// Les envois en attente sont abandonnés par le worker lorsqu'il constate l'absence de l'abonnement.
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	var removed *redis.IntCmd
+	_, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HDel(ctx, subscriptionsKey, id)
+		pipe.Del(ctx, deliveriesKey(id), deadLettersKey(id))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	if removed.Val() == 0 {
+		return ErrNotExist
+	}
+
+	return nil
+}
+
+// Deliveries retourne les dernières tentatives d'envoi d'un abonnement, de la plus récente à la plus ancienne.
+func (s *RedisStore) Deliveries(ctx context.Context, id string) ([]Delivery, error) {
+	values, err := s.readList(ctx, id, deliveriesKey(id))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[Delivery](values)
+}
+
+// DeadLetters retourne les envois abandonnés d'un abonnement, du plus récent au plus ancien.
+func (s *RedisStore) DeadLetters(ctx context.Context, id string) ([]DeadLetter, error) {
+	values, err := s.readList(ctx, id, deadLettersKey(id))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[DeadLetter](values)
+}
+
+// readList lit la liste Redis key d'un abonnement, ou retourne ErrNotExist s'il n'existe pas.
+func (s *RedisStore) readList(ctx context.Context, id, key string) ([]string, error) {
+	var exists *redis.BoolCmd
+	var values *redis.StringSliceCmd
+	_, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		exists = pipe.HExists(ctx, subscriptionsKey, id)
+		values = pipe.LRange(ctx, key, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if !exists.Val() {
+		return nil, ErrNotExist
+	}
+
+	return values.Val(), nil
+}
+
+// decodeAll décode chacune des valeurs JSON d'une liste Redis.
+func decodeAll[T any](values []string) ([]T, error) {
+	items := make([]T, 0, len(values))
+	for _, value := range values {
+		var item T
+		if err := json.Unmarshal([]byte(value), &item); err != nil {
+			return nil, fmt.Errorf("failed to decode json: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// enqueue ajoute à la file les envois d'un message du stream des événements et acquitte le message,
+// dans une même transaction : un message acquitté a toujours ses envois en file.
+func (s *RedisStore) enqueue(ctx context.Context, messageID string, tasks []Task, at time.Time) error {
+	members := make([]redis.Z, 0, len(tasks))
+	for _, task := range tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+		members = append(members, redis.Z{Score: float64(at.UnixMilli()), Member: string(data)})
+	}
+
+	_, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, queueKey, members...)
+		}
+		pipe.XAck(ctx, order.EventsStream, consumerGroup, messageID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue tasks: %w", err)
+	}
+
+	return nil
+}
+
+// claimScript réserve au plus ARGV[2] envois de la file KEYS[1] dont la date de tentative ne dépasse pas ARGV[1],
+// en leur donnant pour date ARGV[3], et retourne les envois réservés. Exécuté en une seule fois par Redis,
+// il ne peut pas réserver un envoi qu'un autre worker vient de réserver.
+var claimScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, member in ipairs(due) do
+	redis.call("ZADD", KEYS[1], "XX", ARGV[3], member)
+end
+return due
+`)
+
+// claim réserve jusqu'à limit envois dont la prochaine tentative est passée, en repoussant leur date de lease.
+// Un envoi réservé par un worker arrêté avant d'en enregistrer le résultat est ainsi repris après ce délai.
+// Retourne les envois réservés tels qu'ils sont stockés dans la file.
+func (s *RedisStore) claim(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]string, error) {
+	claimed, err := claimScript.Run(ctx, s.Client, []string{queueKey},
+		now.UnixMilli(), limit, now.Add(lease).UnixMilli()).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim tasks: %w", err)
+	}
+	return claimed, nil
+}
+
+// completeScript retire l'envoi ARGV[1] de la file KEYS[1] et, seulement s'il y était encore, enregistre la tentative
+// ARGV[2] dans le journal KEYS[2] tronqué à ARGV[3] entrées, remet en file l'envoi ARGV[4] à la date ARGV[5],
+// et ajoute la lettre morte ARGV[6] à la liste KEYS[3] tronquée à ARGV[7] entrées, ces deux dernières s'ils
+// ne sont pas vides. Retourne 0 si l'envoi n'était plus en file.
+var completeScript = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[4] ~= "" then
+	redis.call("ZADD", KEYS[1], ARGV[5], ARGV[4])
+end
+if ARGV[6] ~= "" then
+	redis.call("LPUSH", KEYS[3], ARGV[6])
+	redis.call("LTRIM", KEYS[3], 0, ARGV[7] - 1)
+end
+redis.call("LPUSH", KEYS[2], ARGV[2])
+redis.call("LTRIM", KEYS[2], 0, ARGV[3] - 1)
+return 1
+`)
+
+// errLeaseExpired est retournée lorsqu'un envoi réservé n'est plus en file à la fin de sa tentative :
+// un autre worker l'a repris après l'expiration du lease et en a déjà enregistré le résultat.
+var errLeaseExpired = errors.New("task lease expired")
+
+// complete retire un envoi réservé de la file et enregistre la tentative dans le journal de l'abonnement.
+// next, s'il est fourni, est remis en file pour une nouvelle tentative à la date at ;
+// dead, s'il est fourni, est ajouté aux lettres mortes de l'abonnement.
+// Si l'envoi n'est plus en file, rien n'est enregistré ni remis en file, et errLeaseExpired est retournée :
+// une tentative reprise par un autre worker ne produit ainsi qu'une seule nouvelle tentative.
+func (s *RedisStore) complete(ctx context.Context, member string, d Delivery, subscriptionID string,
+	next *Task, at time.Time, dead *DeadLetter) error {
+	delivery, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery: %w", err)
+	}
+
+	var retry, letter []byte
+	if next != nil {
+		if retry, err = json.Marshal(next); err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+	}
+	if dead != nil {
+		if letter, err = json.Marshal(dead); err != nil {
+			return fmt.Errorf("failed to marshal dead letter: %w", err)
+		}
+	}
+
+	removed, err := completeScript.Run(ctx, s.Client,
+		[]string{queueKey, deliveriesKey(subscriptionID), deadLettersKey(subscriptionID)},
+		member, delivery, maxDeliveries, retry, at.UnixMilli(), letter, maxDeadLetters).Int()
+	if err != nil {
+		return fmt.Errorf("failed to complete task: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("failed to complete task %s: %w", d.TaskID, errLeaseExpired)
+	}
+
+	return nil
+}
+
+// drop retire un envoi de la file sans l'enregistrer, lorsque son abonnement n'existe plus.
+func (s *RedisStore) drop(ctx context.Context, member string) error {
+	if err := s.Client.ZRem(ctx, queueKey, member).Err(); err != nil {
+		return fmt.Errorf("failed to drop task: %w", err)
+	}
+	return nil
+}
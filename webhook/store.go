@@ -0,0 +1,23 @@
+package webhook
+
+import "context"
+
+// Store enregistre les abonnements et le résultat de leurs envois.
+type Store interface {
+	// Create enregistre un nouvel abonnement.
+	Create(ctx context.Context, s Subscription) error
+	// List retourne tous les abonnements, du plus ancien au plus récent.
+	List(ctx context.Context) ([]Subscription, error)
+	// Get retourne un abonnement, ou ErrNotExist s'il n'existe pas.
+	Get(ctx context.Context, id string) (Subscription, error)
+	// Delete supprime un abonnement avec son journal et ses lettres mortes, ou retourne ErrNotExist.
+	// Les envois encore en attente sont abandonnés.
+	Delete(ctx context.Context, id string) error
+	// Deliveries retourne les dernières tentatives d'envoi d'un abonnement, de la plus récente à la plus ancienne.
+	Deliveries(ctx context.Context, id string) ([]Delivery, error)
+	// DeadLetters retourne les envois abandonnés d'un abonnement, du plus récent au plus ancien.
+	DeadLetters(ctx context.Context, id string) ([]DeadLetter, error)
+}
+
+// Vérifie à la compilation que les implémentations respectent l'interface.
+var _ Store = (*RedisStore)(nil)
@@ -0,0 +1,104 @@
+// Package webhook envoie les événements des commandes aux abonnés, par des requêtes HTTP signées.
+//
+// Chaque événement du stream Redis (voir le package event) est envoyé à tous les abonnements
+// dont le filtre l'accepte, dans une requête POST dont le corps est l'événement en JSON.
+// Les envois échoués sont retentés avec un délai croissant, puis mis de côté dans une liste
+// de lettres mortes après le nombre maximal de tentatives.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SamMebarek/orders-api/event"
+)
+
+// En-têtes des requêtes envoyées aux abonnés.
+const (
+	HeaderSubscription = "X-Webhook-Id"        // ID de l'abonnement.
+	HeaderEvent        = "X-Webhook-Event"     // Type de l'événement.
+	HeaderDelivery     = "X-Webhook-Delivery"  // ID de l'événement, identique pour toutes les tentatives.
+	HeaderTimestamp    = "X-Webhook-Timestamp" // Date de l'envoi, en secondes depuis l'epoch Unix.
+	HeaderSignature    = "X-Webhook-Signature" // Signature HMAC-SHA256 de l'envoi, voir Sign.
+)
+
+// Subscription est un abonnement aux événements des commandes.
+type Subscription struct {
+	ID        string       `json:"id"`               // ID de l'abonnement.
+	URL       string       `json:"url"`              // URL recevant les événements.
+	Events    []event.Type `json:"events,omitempty"` // Types d'événements envoyés, vide pour tous.
+	Secret    string       `json:"secret,omitempty"` // Clé de signature des envois, jamais renvoyée par l'API.
+	CreatedAt time.Time    `json:"created_at"`       // Date de création de l'abonnement.
+}
+
+// Matches indique si l'abonnement reçoit les événements du type t.
+func (s Subscription) Matches(t event.Type) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, accepted := range s.Events {
+		if accepted == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNotExist est une erreur retournée lorsqu'un abonnement n'existe pas.
+var ErrNotExist = errors.New("webhook subscription does not exist")
+
+// DeliveryStatus est le résultat d'une tentative d'envoi.
+type DeliveryStatus string
+
+// Résultats possibles d'une tentative d'envoi.
+const (
+	DeliverySucceeded DeliveryStatus = "succeeded" // L'abonné a répondu avec un statut 2xx.
+	DeliveryFailed    DeliveryStatus = "failed"    // Échec, l'envoi sera retenté.
+	DeliveryDead      DeliveryStatus = "dead"      // Échec de la dernière tentative, l'envoi est abandonné.
+)
+
+// Delivery est une tentative d'envoi d'un événement à un abonné, conservée dans son journal.
+type Delivery struct {
+	TaskID        string         `json:"task_id"`                   // ID de l'envoi, identique pour toutes ses tentatives.
+	EventID       string         `json:"event_id"`                  // ID de l'événement envoyé.
+	EventType     event.Type     `json:"event_type"`                // Type de l'événement envoyé.
+	Attempt       int            `json:"attempt"`                   // Numéro de la tentative, à partir de 1.
+	Status        DeliveryStatus `json:"status"`                    // Résultat de la tentative.
+	StatusCode    int            `json:"status_code,omitempty"`     // Statut HTTP répondu par l'abonné, absent sans réponse.
+	Error         string         `json:"error,omitempty"`           // Cause de l'échec.
+	DurationMS    int64          `json:"duration_ms"`               // Durée de la requête, en millisecondes.
+	At            time.Time      `json:"at"`                        // Date de la tentative.
+	NextAttemptAt *time.Time     `json:"next_attempt_at,omitempty"` // Date de la prochaine tentative, après un échec.
+}
+
+// Task est l'envoi d'un événement à un abonné, en attente dans la file du worker.
+type Task struct {
+	ID             string     `json:"id"`              // ID de l'envoi.
+	SubscriptionID string     `json:"subscription_id"` // ID de l'abonnement destinataire.
+	EventID        string     `json:"event_id"`        // ID de l'événement.
+	EventType      event.Type `json:"event_type"`      // Type de l'événement.
+	Attempt        int        `json:"attempt"`         // Numéro de la prochaine tentative, à partir de 1.
+	Payload        string     `json:"payload"`         // Événement en JSON, envoyé tel quel.
+}
+
+// DeadLetter est un envoi abandonné après le nombre maximal de tentatives.
+type DeadLetter struct {
+	Task     Task      `json:"task"`      // Envoi abandonné, avec l'événement.
+	Error    string    `json:"error"`     // Cause du dernier échec.
+	FailedAt time.Time `json:"failed_at"` // Date du dernier échec.
+}
+
+// Sign calcule la signature d'un envoi : l'empreinte HMAC-SHA256, en hexadécimal et préfixée de "sha256=",
+// de la date de l'envoi (secondes Unix), d'un point et du corps de la requête.
+// Inclure la date permet aux abonnés de rejeter les requêtes rejouées.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/SamMebarek/orders-api/event"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{
+			name:      "event",
+			secret:    "secret",
+			timestamp: 1700000000,
+			body:      `{"id":"1"}`,
+			want:      "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54",
+		},
+		{
+			name: "empty",
+			want: "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// La date, le corps et la clé changent chacun la signature.
+	base := Sign("secret", 1700000000, []byte(`{"id":"1"}`))
+	for _, other := range []string{
+		Sign("secret", 1700000001, []byte(`{"id":"1"}`)),
+		Sign("secret", 1700000000, []byte(`{"id":"2"}`)),
+		Sign("other", 1700000000, []byte(`{"id":"1"}`)),
+		Sign("secret", 170000000, []byte(`0.{"id":"1"}`)),
+	} {
+		if other == base {
+			t.Errorf("signature %s does not depend on every input", other)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		events []event.Type
+		t      event.Type
+		want   bool
+	}{
+		{nil, event.OrderCreated, true},
+		{[]event.Type{event.OrderCreated}, event.OrderCreated, true},
+		{[]event.Type{event.OrderCreated}, event.OrderCancelled, false},
+	}
+	for _, tt := range tests {
+		if got := (Subscription{Events: tt.events}).Matches(tt.t); got != tt.want {
+			t.Errorf("Subscription{Events: %v}.Matches(%s) = %v, want %v", tt.events, tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/SamMebarek/orders-api/repository/order"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Paramètres du worker d'envoi.
+const (
+	batchSize     = 100              // Nombre maximal de messages traités par passage.
+	maxInFlight   = 100              // Nombre maximal d'envois en cours à la fois.
+	readTimeout   = time.Second      // Attente maximale de nouveaux messages, qui rythme aussi les nouvelles tentatives.
+	claimLease    = time.Minute      // Délai minimal après lequel un envoi ou un message réservé par un worker arrêté est repris.
+	leaseMargin   = 10 * time.Second // Marge du lease d'un envoi sur le délai de sa requête, pour en enregistrer le résultat.
+	maxRetryDelay = time.Hour        // Délai maximal entre deux tentatives.
+	maxBodyRead   = 64 << 10         // Taille maximale lue de la réponse d'un abonné, pour réutiliser la connexion.
+	userAgent     = "orders-api-webhooks"
+)
+
+// Worker lit le stream des événements et envoie chaque événement aux abonnements qui l'acceptent.
+// Plusieurs workers, sur plusieurs instances, peuvent fonctionner en parallèle : chaque message du stream
+// n'est distribué qu'à l'un d'entre eux, et chaque envoi n'est tenté que par un worker à la fois.
+// Les envois sont garantis au moins une fois : les abonnés dédupliquent grâce à l'en-tête X-Webhook-Delivery.
+type Worker struct {
+	Store       *RedisStore   // Abonnements, file des envois et journaux.
+	HTTP        *http.Client  // Client des requêtes envoyées aux abonnés, avec son délai maximal.
+	Consumer    string        // Nom de ce worker dans le groupe de consommateurs du stream.
+	MaxAttempts int           // Nombre maximal de tentatives d'un envoi avant les lettres mortes.
+	RetryDelay  time.Duration // Délai avant la deuxième tentative, doublé à chaque échec suivant.
+}
+
+// Run traite les événements et les envois jusqu'à l'annulation du contexte.
+// Les envois sont effectués à part, afin qu'un abonné lent ne retarde ni la lecture du stream ni les autres envois.
+// Run attend la fin des envois en cours avant de retourner.
+func (w *Worker) Run(ctx context.Context) {
+	// Création du groupe de consommateurs, à partir des nouveaux événements. Il peut déjà exister.
+	err := w.Store.Client.XGroupCreateMkStream(ctx, order.EventsStream, consumerGroup, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		fmt.Println("failed to create webhook consumer group:", err)
+		return
+	}
+
+	var deliveries sync.WaitGroup
+	deliveries.Add(1)
+	go func() {
+		defer deliveries.Done()
+		w.deliverDue(ctx, &deliveries)
+	}()
+	defer deliveries.Wait()
+
+	for ctx.Err() == nil {
+		if err := w.dispatch(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("failed to dispatch webhook events:", err)
+
+			// Attente avant de réessayer, pour ne pas boucler sur une erreur Redis.
+			select {
+			case <-ctx.Done():
+			case <-time.After(readTimeout):
+			}
+		}
+	}
+}
+
+// dispatch met en file les envois des nouveaux messages du stream, ainsi que ceux des messages
+// lus par un worker arrêté avant de les acquitter.
+func (w *Worker) dispatch(ctx context.Context) error {
+	// Reprise des messages réservés depuis trop longtemps par un autre consommateur.
+	abandoned, _, err := w.Store.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   order.EventsStream,
+		Group:    consumerGroup,
+		Consumer: w.Consumer,
+		MinIdle:  claimLease,
+		Start:    "0-0",
+		Count:    batchSize,
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to claim events: %w", err)
+	}
+
+	// Lecture des nouveaux messages, en attendant au plus readTimeout.
+	messages := abandoned
+	streams, err := w.Store.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    consumerGroup,
+		Consumer: w.Consumer,
+		Streams:  []string{order.EventsStream, ">"},
+		Count:    batchSize,
+		Block:    readTimeout,
+	}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	subs, err := w.Store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, msg := range messages {
+		if err := w.Store.enqueue(ctx, msg.ID, tasksFor(msg, subs), now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tasksFor crée les envois d'un message du stream pour les abonnements qui acceptent son type.
+// Un message illisible est journalisé et ne produit aucun envoi.
+func tasksFor(msg redis.XMessage, subs []Subscription) []Task {
+	payload, _ := msg.Values["event"].(string)
+
+	var e struct {
+		ID   string     `json:"id"`
+		Type event.Type `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		fmt.Println("failed to decode event", msg.ID, err)
+		return nil
+	}
+
+	var tasks []Task
+	for _, sub := range subs {
+		if !sub.Matches(e.Type) {
+			continue
+		}
+		tasks = append(tasks, Task{
+			ID:             uuid.NewString(),
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Attempt:        1,
+			Payload:        payload,
+		})
+	}
+
+	return tasks
+}
+
+// deliverDue tente en parallèle les envois dont la date est passée, jusqu'à l'annulation du contexte.
+// Au plus maxInFlight envois sont en cours : seuls les envois qui peuvent commencer aussitôt sont réservés,
+// afin que leur lease ne s'écoule pas en attente d'une place. Chaque envoi est compté dans wg.
+func (w *Worker) deliverDue(ctx context.Context, wg *sync.WaitGroup) {
+	slots := make(chan struct{}, maxInFlight)
+	lease := w.lease()
+
+	for ctx.Err() == nil {
+		// Seule cette boucle occupe des places : celles comptées libres le restent jusqu'au lancement des envois.
+		free := cap(slots) - len(slots)
+		var members []string
+		if free > 0 {
+			var err error
+			members, err = w.Store.claim(ctx, time.Now(), lease, int64(free))
+			if err != nil && ctx.Err() == nil {
+				fmt.Println("failed to claim webhook tasks:", err)
+			}
+		}
+
+		for _, member := range members {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(member string) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				if err := w.deliver(ctx, member); err != nil {
+					fmt.Println("failed to deliver webhook:", err)
+				}
+			}(member)
+		}
+
+		// D'autres envois peuvent être dus si toutes les places libres ont été prises ; sinon, attente.
+		if free > 0 && len(members) == free {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(readTimeout):
+		}
+	}
+}
+
+// lease retourne le délai de réservation d'un envoi : le délai des requêtes aux abonnés et une marge,
+// sans descendre sous claimLease. Un envoi n'est ainsi jamais repris par un autre worker pendant sa requête.
+func (w *Worker) lease() time.Duration {
+	lease := w.HTTP.Timeout + leaseMargin
+	if w.HTTP.Timeout == 0 || lease < claimLease {
+		lease = claimLease
+	}
+	return lease
+}
+
+// deliver effectue une tentative d'envoi réservé et enregistre son résultat.
+func (w *Worker) deliver(ctx context.Context, member string) error {
+	var task Task
+	if err := json.Unmarshal([]byte(member), &task); err != nil {
+		fmt.Println("failed to decode webhook task:", err)
+		return w.Store.drop(ctx, member)
+	}
+
+	sub, err := w.Store.Get(ctx, task.SubscriptionID)
+	if errors.Is(err, ErrNotExist) {
+		// L'abonnement a été supprimé : l'envoi est abandonné.
+		return w.Store.drop(ctx, member)
+	} else if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	statusCode, err := w.send(ctx, sub, task)
+	if ctx.Err() != nil {
+		// Arrêt du worker pendant l'envoi : la tentative sera reprise après le délai de réservation.
+		return nil
+	}
+
+	d := Delivery{
+		TaskID:     task.ID,
+		EventID:    task.EventID,
+		EventType:  task.EventType,
+		Attempt:    task.Attempt,
+		Status:     DeliverySucceeded,
+		StatusCode: statusCode,
+		DurationMS: time.Since(start).Milliseconds(),
+		At:         start.UTC(),
+	}
+
+	switch {
+	case err == nil:
+		return w.Store.complete(ctx, member, d, sub.ID, nil, time.Time{}, nil)
+	case task.Attempt >= w.MaxAttempts:
+		// Dernière tentative échouée : l'envoi rejoint les lettres mortes de l'abonnement.
+		d.Status = DeliveryDead
+		d.Error = err.Error()
+		dead := DeadLetter{Task: task, Error: d.Error, FailedAt: d.At}
+		return w.Store.complete(ctx, member, d, sub.ID, nil, time.Time{}, &dead)
+	default:
+		// Nouvelle tentative après un délai croissant.
+		next := d.At.Add(w.backoff(task.Attempt))
+		d.Status = DeliveryFailed
+		d.Error = err.Error()
+		d.NextAttemptAt = &next
+		retry := task
+		retry.Attempt++
+		return w.Store.complete(ctx, member, d, sub.ID, &retry, next, nil)
+	}
+}
+
+// send envoie l'événement d'un envoi à l'abonné et retourne le statut HTTP de sa réponse.
+// Toute réponse hors 2xx est un échec.
+func (w *Worker) send(ctx context.Context, sub Subscription, task Task) (int, error) {
+	body := []byte(task.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderSubscription, sub.ID)
+	req.Header.Set(HeaderEvent, string(task.EventType))
+	req.Header.Set(HeaderDelivery, task.EventID)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(timestamp))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+
+	res, err := w.HTTP.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// Lecture d'une partie de la réponse pour permettre la réutilisation de la connexion.
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxBodyRead))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// backoff retourne le délai avant la tentative suivant la tentative attempt : RetryDelay après la première,
+// puis le double à chaque échec, sans dépasser maxRetryDelay.
+func (w *Worker) backoff(attempt int) time.Duration {
+	delay := w.RetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
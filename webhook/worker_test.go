@@ -0,0 +1,479 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SamMebarek/orders-api/event"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// received est une requête reçue par un abonné de test.
+type received struct {
+	header http.Header
+	body   string
+}
+
+// receiver démarre un abonné qui répond successivement les statuts statuses, puis 200,
+// et retourne les requêtes qu'il a reçues.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		status := http.StatusOK
+		if len(requests) < len(statuses) {
+			status = statuses[len(requests)]
+		}
+		requests = append(requests, received{header: r.Header.Clone(), body: string(body)})
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), requests...)
+	}
+}
+
+// testTask retourne un premier envoi d'un événement OrderCreated à l'abonnement sub.
+func testTask(sub Subscription) Task {
+	return Task{
+		ID:             "task-1",
+		SubscriptionID: sub.ID,
+		EventID:        "event-1",
+		EventType:      event.OrderCreated,
+		Attempt:        1,
+		Payload:        `{"id":"event-1","type":"OrderCreated"}`,
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"client error", http.StatusNotFound, true},
+		{"server error", http.StatusInternalServerError, true},
+		{"unavailable", http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := receiver(t, tt.status)
+			sub := Subscription{ID: "sub-1", URL: srv.URL, Secret: "secret"}
+			task := testTask(sub)
+			w := &Worker{HTTP: srv.Client()}
+
+			status, err := w.send(context.Background(), sub, task)
+			if status != tt.status || (err != nil) != tt.wantErr {
+				t.Fatalf("send() = %d, %v, want %d and error %v", status, err, tt.status, tt.wantErr)
+			}
+
+			got := requests()
+			if len(got) != 1 {
+				t.Fatalf("receiver got %d requests, want 1", len(got))
+			}
+			req := got[0]
+			if req.body != task.Payload {
+				t.Errorf("body = %s, want %s", req.body, task.Payload)
+			}
+			for header, want := range map[string]string{
+				"Content-Type":     "application/json",
+				"User-Agent":       userAgent,
+				HeaderSubscription: sub.ID,
+				HeaderEvent:        string(task.EventType),
+				HeaderDelivery:     task.EventID,
+			} {
+				if value := req.header.Get(header); value != want {
+					t.Errorf("%s = %q, want %q", header, value, want)
+				}
+			}
+
+			// La signature est l'HMAC-SHA256 de "date.corps", vérifié comme le ferait un abonné.
+			timestamp := req.header.Get(HeaderTimestamp)
+			if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+				t.Fatalf("%s = %q, want a Unix time", HeaderTimestamp, timestamp)
+			}
+			mac := hmac.New(sha256.New, []byte(sub.Secret))
+			mac.Write([]byte(timestamp + "." + req.body))
+			if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get(HeaderSignature) != want {
+				t.Errorf("%s = %s, want %s", HeaderSignature, req.header.Get(HeaderSignature), want)
+			}
+		})
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	w := &Worker{HTTP: srv.Client()}
+	sub := Subscription{ID: "sub-1", URL: srv.URL}
+	if status, err := w.send(context.Background(), sub, testTask(sub)); status != 0 || err == nil {
+		t.Errorf("send() = %d, %v, want 0 and an error", status, err)
+	}
+}
+
+// testStore retourne un RedisStore sur un serveur miniredis propre au test.
+func testStore(t *testing.T) *RedisStore {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return &RedisStore{Client: client}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []int // Réponses successives de l'abonné, puis 200.
+		maxAttempts int
+		want        []DeliveryStatus // Résultat de chaque tentative, de la première à la dernière.
+		wantDead    bool
+	}{
+		{"success", nil, 3, []DeliveryStatus{DeliverySucceeded}, false},
+		{"retry after 5xx", []int{http.StatusServiceUnavailable}, 3,
+			[]DeliveryStatus{DeliveryFailed, DeliverySucceeded}, false},
+		{"success on the last attempt", []int{http.StatusInternalServerError, http.StatusBadGateway}, 3,
+			[]DeliveryStatus{DeliveryFailed, DeliveryFailed, DeliverySucceeded}, false},
+		{"dead letter after the last attempt",
+			[]int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, 3,
+			[]DeliveryStatus{DeliveryFailed, DeliveryFailed, DeliveryDead}, true},
+		{"single attempt", []int{http.StatusInternalServerError}, 1, []DeliveryStatus{DeliveryDead}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv, requests := receiver(t, tt.statuses...)
+			store := testStore(t)
+			w := &Worker{Store: store, HTTP: srv.Client(), MaxAttempts: tt.maxAttempts, RetryDelay: time.Minute}
+
+			sub := Subscription{ID: "sub-1", URL: srv.URL, Secret: "secret", CreatedAt: time.Now().UTC()}
+			if err := store.Create(ctx, sub); err != nil {
+				t.Fatal(err)
+			}
+			task := testTask(sub)
+			data, _ := json.Marshal(task)
+			if err := store.Client.ZAdd(ctx, queueKey, redis.Z{Score: 0, Member: string(data)}).Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Chaque passage réserve l'envoi en attente, quelle que soit la date de sa nouvelle tentative.
+			for i := 0; i < tt.maxAttempts+1; i++ {
+				members, err := store.claim(ctx, time.Now().Add(24*time.Hour), claimLease, batchSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(members) == 0 {
+					break
+				}
+				if len(members) != 1 {
+					t.Fatalf("claimed %d tasks, want 1", len(members))
+				}
+				if err := w.deliver(ctx, members[0]); err != nil {
+					t.Fatalf("deliver() error = %v", err)
+				}
+			}
+
+			if queued := store.Client.ZCard(ctx, queueKey).Val(); queued != 0 {
+				t.Errorf("queue has %d tasks, want 0", queued)
+			}
+			if got := len(requests()); got != len(tt.want) {
+				t.Errorf("receiver got %d requests, want %d", got, len(tt.want))
+			}
+
+			deliveries, err := store.Deliveries(ctx, sub.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []DeliveryStatus
+			for i := len(deliveries) - 1; i >= 0; i-- {
+				d := deliveries[i]
+				got = append(got, d.Status)
+
+				attempt := len(deliveries) - i
+				if d.TaskID != task.ID || d.EventID != task.EventID || d.Attempt != attempt {
+					t.Errorf("delivery %d = %+v, want task %s, event %s", attempt, d, task.ID, task.EventID)
+				}
+				// Seul un échec suivi d'une nouvelle tentative en indique la date.
+				if d.Status == DeliveryFailed {
+					if d.NextAttemptAt == nil || !d.NextAttemptAt.Equal(d.At.Add(w.backoff(attempt))) {
+						t.Errorf("delivery %d next attempt = %v, want %v", attempt, d.NextAttemptAt, w.backoff(attempt))
+					}
+				} else if d.NextAttemptAt != nil {
+					t.Errorf("delivery %d next attempt = %v, want none", attempt, d.NextAttemptAt)
+				}
+				if (d.Status == DeliverySucceeded) != (d.Error == "") {
+					t.Errorf("delivery %d = %s with error %q", attempt, d.Status, d.Error)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deliveries = %v, want %v", got, tt.want)
+			}
+
+			dead, err := store.DeadLetters(ctx, sub.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantDead {
+				if len(dead) != 0 {
+					t.Errorf("dead letters = %+v, want none", dead)
+				}
+				return
+			}
+			if len(dead) != 1 {
+				t.Fatalf("got %d dead letters, want 1", len(dead))
+			}
+			last := task
+			last.Attempt = tt.maxAttempts
+			if dead[0].Task != last || dead[0].Error != "unexpected status 500" {
+				t.Errorf("dead letter = %+v, want task %+v failed with status 500", dead[0], last)
+			}
+		})
+	}
+}
+
+func TestDeliverDeletedSubscription(t *testing.T) {
+	ctx := context.Background()
+	srv, requests := receiver(t)
+	store := testStore(t)
+	w := &Worker{Store: store, HTTP: srv.Client(), MaxAttempts: 3, RetryDelay: time.Minute}
+
+	data, _ := json.Marshal(testTask(Subscription{ID: "deleted"}))
+	if err := store.Client.ZAdd(ctx, queueKey, redis.Z{Score: 0, Member: string(data)}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.deliver(ctx, string(data)); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+	if queued := store.Client.ZCard(ctx, queueKey).Val(); queued != 0 {
+		t.Errorf("queue has %d tasks, want 0", queued)
+	}
+	if got := len(requests()); got != 0 {
+		t.Errorf("receiver got %d requests, want 0", got)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+	now := time.Now()
+
+	// 20 envois dus et un envoi prévu plus tard.
+	for i := 0; i < 20; i++ {
+		err := store.Client.ZAdd(ctx, queueKey, redis.Z{Score: float64(now.UnixMilli() - 1), Member: strconv.Itoa(i)}).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Client.ZAdd(ctx, queueKey, redis.Z{Score: float64(now.Add(time.Hour).UnixMilli()), Member: "later"}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Des workers concurrents réservent chacun des envois différents, et tous les envois dus sont réservés.
+	var mu sync.Mutex
+	claimed := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				members, err := store.claim(ctx, now, claimLease, 3)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(members) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, m := range members {
+					claimed[m]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != 20 {
+		t.Errorf("claimed %d tasks, want 20", len(claimed))
+	}
+	for member, n := range claimed {
+		if n != 1 || member == "later" {
+			t.Errorf("task %s claimed %d times", member, n)
+		}
+	}
+
+	// Un envoi réservé n'est repris qu'à la fin du lease, avec les envois devenus dus entre-temps.
+	members, err := store.claim(ctx, now.Add(claimLease-time.Millisecond), claimLease, batchSize)
+	if err != nil || len(members) != 0 {
+		t.Errorf("claim() before the end of the lease = %v, %v, want none", members, err)
+	}
+	members, err = store.claim(ctx, now.Add(time.Hour), claimLease, batchSize)
+	if err != nil || len(members) != 21 {
+		t.Errorf("claim() after the lease = %d tasks, %v, want 21", len(members), err)
+	}
+}
+
+func TestCompleteExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+	sub := Subscription{ID: "sub-1", URL: "https://example.com/hooks", Secret: "secret"}
+	if err := store.Create(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	task := testTask(sub)
+	data, _ := json.Marshal(task)
+	if err := store.Client.ZAdd(ctx, queueKey, redis.Z{Score: 0, Member: string(data)}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deux workers terminent la même tentative, reprise par le second à l'expiration du lease du premier :
+	// seul le premier enregistre son résultat et remet l'envoi en file.
+	d := Delivery{TaskID: task.ID, Attempt: 1, Status: DeliveryFailed}
+	retry := task
+	retry.Attempt++
+	if err := store.complete(ctx, string(data), d, sub.ID, &retry, time.Now(), nil); err != nil {
+		t.Fatalf("complete() error = %v", err)
+	}
+	dead := DeadLetter{Task: task}
+	if err := store.complete(ctx, string(data), d, sub.ID, &retry, time.Now(), &dead); !errors.Is(err, errLeaseExpired) {
+		t.Errorf("second complete() error = %v, want %v", err, errLeaseExpired)
+	}
+
+	if queued := store.Client.ZCard(ctx, queueKey).Val(); queued != 1 {
+		t.Errorf("queue has %d tasks, want 1", queued)
+	}
+	if deliveries, err := store.Deliveries(ctx, sub.ID); err != nil || len(deliveries) != 1 {
+		t.Errorf("got %d deliveries (%v), want 1", len(deliveries), err)
+	}
+	if letters, err := store.DeadLetters(ctx, sub.ID); err != nil || len(letters) != 0 {
+		t.Errorf("got %d dead letters (%v), want 0", len(letters), err)
+	}
+}
+
+func TestLease(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{0, claimLease},
+		{10 * time.Second, claimLease},
+		{claimLease, claimLease + leaseMargin},
+		{5 * time.Minute, 5*time.Minute + leaseMargin},
+	}
+	for _, tt := range tests {
+		w := &Worker{HTTP: &http.Client{Timeout: tt.timeout}}
+		if got := w.lease(); got != tt.want {
+			t.Errorf("lease() with a %v timeout = %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverDueSlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := testStore(t)
+
+	// Un abonné ne répond qu'à la fin du test, l'autre aussitôt.
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	fast, requests := receiver(t)
+
+	w := &Worker{Store: store, HTTP: &http.Client{}, MaxAttempts: 3, RetryDelay: time.Minute}
+	slowSub := Subscription{ID: "slow", URL: slow.URL, Secret: "secret"}
+	fastSub := Subscription{ID: "fast", URL: fast.URL, Secret: "secret"}
+	for _, sub := range []Subscription{slowSub, fastSub} {
+		if err := store.Create(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue := func(sub Subscription, id string) {
+		t.Helper()
+		task := testTask(sub)
+		task.ID = id
+		data, _ := json.Marshal(task)
+		if err := store.Client.ZAdd(ctx, queueKey, redis.Z{Score: 0, Member: string(data)}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue(slowSub, "task-slow")
+	queue(fastSub, "task-1")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.deliverDue(ctx, &wg)
+	}()
+	t.Cleanup(func() {
+		close(release)
+		cancel()
+		wg.Wait()
+	})
+
+	// Les envois suivants à l'abonné rapide n'attendent pas la réponse de l'abonné lent.
+	waitRequests := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(requests()) < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("fast receiver got %d requests, want %d", len(requests()), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitRequests(1)
+	queue(fastSub, "task-2")
+	waitRequests(2)
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Worker{RetryDelay: time.Minute}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, maxRetryDelay},
+		{100, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := w.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}